@@ -1,8 +1,11 @@
 package entities
 
+import "vezhguesi/core/audit"
+
 type CreateEntityRequest struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
+	Name  string      `json:"name" validate:"required,max=255"`
+	Type  string      `json:"type" validate:"required,max=100"`
+	Actor audit.Actor `json:"-"`
 }
 
 type EntityResponse struct {
@@ -1,10 +1,13 @@
 package entities
 
 import (
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/middleware"
+
 	"github.com/gofiber/fiber/v2"
 )
 
-func RegisterRoutes(router fiber.Router, transport EntitiesHTTPTransport) {
-	router.Post("/entities", transport.Create)
+func RegisterRoutes(router fiber.Router, transport EntitiesHTTPTransport, authMiddleware func(c *fiber.Ctx) error) {
+	router.Post("/entities", middleware.RequireScope(session.ScopeEntitiesWrite), authMiddleware, transport.Create)
 	router.Get("/entities/:id", transport.GetEntity)
-}
\ No newline at end of file
+}
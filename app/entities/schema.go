@@ -6,8 +6,8 @@ import (
 
 type Entity struct {
 	ID             uint              `gorm:"primaryKey"`
-	Name           string            `gorm:"not null"`
-	Type           string
+	Name           string            `gorm:"not null" validate:"required,max=255"`
+	Type           string            `validate:"max=100"`
 	RelatedTopics  string            `gorm:"type:json"` // Serialize to JSON
 	SentimentLabel string
 	SentimentScore float32
@@ -1,20 +1,29 @@
 package entities
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
+	"vezhguesi/core/validation"
+
 	"github.com/gofiber/fiber/v2/log"
 	"gorm.io/gorm"
 )
 
+// ErrEntityNotFound is returned for entities that don't exist
+var ErrEntityNotFound = apperr.New(apperr.NotFound, "entity_not_found", "entity not found")
+
 type entitiesApi struct {
 	db *gorm.DB
 	logger log.AllLogger
 }
 
 type EntitiesAPI interface {
-	Create(req *CreateEntityRequest) (res *EntityResponse, err error)
-	GetEntity(req *GetEntityRequest) (res *EntityResponse, err error)
+	Create(ctx context.Context, req *CreateEntityRequest) (res *EntityResponse, err error)
+	GetEntity(ctx context.Context, req *GetEntityRequest) (res *EntityResponse, err error)
 }
 
 func NewEntitiesAPI(db *gorm.DB, logger log.AllLogger) EntitiesAPI {
@@ -32,23 +41,35 @@ func NewEntitiesAPI(db *gorm.DB, logger log.AllLogger) EntitiesAPI {
 // @Param			CreateEntityRequest	body		CreateEntityRequest	true	"CreateEntityRequest"
 // @Success			200					{object}	EntityResponse
 // @Router			/api/entities/	[POST]
-func (s *entitiesApi) Create(req *CreateEntityRequest) (res *EntityResponse, err error) {
-	if req.Name == "" {
-		return nil, fmt.Errorf("name is required")
-	}
+func (s *entitiesApi) Create(ctx context.Context, req *CreateEntityRequest) (res *EntityResponse, err error) {
+	db := s.db.WithContext(ctx)
 
-	if req.Type == "" {
-		return nil, fmt.Errorf("type is required")
+	if err := validation.Struct(req); err != nil {
+		return nil, err
 	}
 
 	entity := &Entity{
 		Name: req.Name,
 		Type: req.Type,
 	}
+	if err := validation.Struct(entity); err != nil {
+		return nil, err
+	}
 
-	result := s.db.Create(&entity)
+	result := db.Create(&entity)
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, fmt.Errorf("failed to create entity: %w", result.Error)
+	}
+
+	err = audit.Record(db, audit.Event{
+		Actor:      req.Actor,
+		Action:     audit.ActionEntityCreated,
+		TargetType: audit.TargetEntity,
+		TargetID:   entity.ID,
+		After:      map[string]string{"name": entity.Name, "type": entity.Type},
+	})
+	if err != nil {
+		s.logger.Errorf("func: Create, operation: audit.Record, err: %s", err.Error())
 	}
 
 	return &EntityResponse{
@@ -67,16 +88,19 @@ func (s *entitiesApi) Create(req *CreateEntityRequest) (res *EntityResponse, err
 // @Param			name	query		string	false	"Name"
 // @Success			200					{object}	EntityResponse
 // @Router			/api/entities/{id}	[GET]
-func (s *entitiesApi) GetEntity(req *GetEntityRequest) (res *EntityResponse, err error) {
+func (s *entitiesApi) GetEntity(ctx context.Context, req *GetEntityRequest) (res *EntityResponse, err error) {
 	if req.ID == 0 && req.Name == "" {
-		return nil, fmt.Errorf("id or name is required")
+		return nil, apperr.New(apperr.Invalid, "missing_id", "id or name is required")
 	}
 	
 	entity := &Entity{}
 
-	result := s.db.Where("id = ? OR name = ?", req.ID, req.Name).First(&entity)
+	result := s.db.WithContext(ctx).Where("id = ? OR name = ?", req.ID, req.Name).First(&entity)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrEntityNotFound
+	}
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, fmt.Errorf("failed to fetch entity: %w", result.Error)
 	}
 
 	return &EntityResponse{
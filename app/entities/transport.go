@@ -2,6 +2,8 @@ package entities
 
 import (
 	"strconv"
+	"vezhguesi/core/audit"
+	"vezhguesi/helper"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -22,16 +24,13 @@ func NewEntitiesHTTPTransport(entitiesAPI EntitiesAPI) EntitiesHTTPTransport {
 func (s *entitiesHttpTransport) Create(c *fiber.Ctx) error {
 	req := &CreateEntityRequest{}
 	if err := c.BodyParser(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": err.Error(),
-		})
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "CreateEntity.c.BodyParser")
 	}
+	req.Actor = audit.ActorFrom(c)
 
-	res, err := s.entitiesAPI.Create(req)
+	res, err := s.entitiesAPI.Create(c.UserContext(), req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": err.Error(),
-		})
+		return helper.HTTPError(c, err, "CreateEntity.entitiesAPI.Create")
 	}
 
 	return c.Status(fiber.StatusOK).JSON(res)
@@ -47,11 +46,9 @@ func (s *entitiesHttpTransport) GetEntity(c *fiber.Ctx) error {
 	name := c.Query("name")
 	req.Name = name
 
-	res, err := s.entitiesAPI.GetEntity(req)
+	res, err := s.entitiesAPI.GetEntity(c.UserContext(), req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": err.Error(),
-		})
+		return helper.HTTPError(c, err, "GetEntity.entitiesAPI.GetEntity")
 	}
 
 	return c.Status(fiber.StatusOK).JSON(res)
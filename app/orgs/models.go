@@ -1,15 +1,44 @@
 package orgs
 
+import "vezhguesi/core/audit"
+
 type AddOrgRequest struct {
-	UserID int    `json:"-"`
-	Name   string `json:"name"`
-	Size   string `json:"size"`
+	UserID int         `json:"-" validate:"required"`
+	Name   string      `json:"name" validate:"required,max=100"`
+	Size   string      `json:"size" validate:"required,max=50"`
+	Actor  audit.Actor `json:"-"`
 }
 
 type OrgResponse struct {
-	ID      int    `json:"id"`
-	Name    string `json:"name"`
-	OrgSlug string `json:"orgSlug"`
+	ID               int    `json:"id"`
+	Name             string `json:"name"`
+	OrgSlug          string `json:"orgSlug"`
+	RequireTwoFactor bool   `json:"requireTwoFactor"`
+}
+
+type TwoFactorPolicyRequest struct {
+	UserID  int         `json:"-" validate:"required"`
+	OrgID   int         `json:"-" validate:"required"`
+	Require bool        `json:"require"`
+	Actor   audit.Actor `json:"-"`
+}
+
+type InviteRequest struct {
+	UserID int         `json:"-" validate:"required"`
+	OrgID  int         `json:"-" validate:"required"`
+	Email  string      `json:"email" validate:"required,email,max=255"`
+	RoleID int         `json:"roleId" validate:"required"`
+	Actor  audit.Actor `json:"-"`
+}
+
+type AcceptInviteRequest struct {
+	UserID int         `json:"-"`
+	Token  string      `json:"-"`
+	Actor  audit.Actor `json:"-"`
+}
+
+type StatusResponse struct {
+	Status bool `json:"status"`
 }
 
 type FindOrgRequest struct {
@@ -5,4 +5,7 @@ import "github.com/gofiber/fiber/v2"
 func RegisterRoutes(router fiber.Router, orgHttpApi OrgHTTPTransport, authMiddleware func(c *fiber.Ctx) error) {
 	orgRoutes := router.Group("/orgs")
 	orgRoutes.Post("/", authMiddleware, orgHttpApi.Add)
+	orgRoutes.Put("/:orgId/two-factor-policy", authMiddleware, orgHttpApi.SetTwoFactorPolicy)
+	orgRoutes.Post("/:orgId/invites", authMiddleware, orgHttpApi.Invite)
+	orgRoutes.Post("/invites/:token/accept", authMiddleware, orgHttpApi.AcceptInvite)
 }
@@ -12,26 +12,26 @@ const (
 )
 
 type Org struct {
-	ID        int    `gorm:"primaryKey"`
-	Name      string `gorm:"not null"`
-	Slug      string `gorm:"unique;not null"`
-	Size      string `gorm:"not null"`
-	UserOrgRole []UserOrgRole `gorm:"foreignKey:OrgID"`
-	SubscriptionID int
-	Subscription Subscription `gorm:"foreignKey:SubscriptionID"`
-	CreatedAt time.Time
-	UpdatedAt *time.Time
-	DeletedAt *time.Time
+	ID               int           `gorm:"primaryKey"`
+	Name             string        `gorm:"not null" validate:"required,max=100"`
+	Slug             string        `gorm:"unique;not null" validate:"required,slug,max=100"`
+	Size             string        `gorm:"not null" validate:"required,max=50"`
+	RequireTwoFactor bool          `gorm:"not null;default:false"`
+	UserOrgRole      []UserOrgRole `gorm:"foreignKey:OrgID"`
+	SubscriptionID   int
+	Subscription     Subscription `gorm:"foreignKey:SubscriptionID"`
+	CreatedAt        time.Time
+	UpdatedAt        *time.Time
+	DeletedAt        *time.Time
 }
 
-
 type UserOrgRole struct {
-	UserID int `gorm:"foreignKey:ID"`
-	User User
-	OrgID  int
-	RoleID int `gorm:"foreignKey:ID"`
-	Role Role
-	Status string
+	UserID    int `gorm:"foreignKey:ID"`
+	User      User
+	OrgID     int
+	RoleID    int `gorm:"foreignKey:ID"`
+	Role      Role
+	Status    string
 	CreatedAt time.Time
 	UpdatedAt *time.Time
 	DeletedAt *time.Time
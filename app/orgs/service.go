@@ -1,30 +1,61 @@
 package orgs
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"math/rand/v2"
 	"regexp"
 	"strings"
+	"time"
+	"unicode"
 
 	subscriptionsvc "vezhguesi/app/subscriptions"
+	usagesvc "vezhguesi/app/usage"
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/mailer"
+	"vezhguesi/core/validation"
 	helper "vezhguesi/helper"
 
 	"github.com/gofiber/fiber/v2/log"
+	"golang.org/x/text/unicode/norm"
 	"gorm.io/gorm"
 )
 
+const inviteTokenTTL = 7 * 24 * time.Hour
+
+var slugSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugLetters spells out the letters that don't decompose into a latin letter
+// and accents.
+var slugLetters = strings.NewReplacer("ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "đ", "d", "ł", "l", "þ", "th", "ı", "i")
+
 type orgApi struct {
 	db *gorm.DB
 	logger log.AllLogger
+	uiAppUrl string
+}
+
+// inviteData is stored with the invite token
+type inviteData struct {
+	OrgID  int `json:"orgId"`
+	RoleID int `json:"roleId"`
 }
 
 type OrgAPI interface{
-	Add(req *AddOrgRequest) (res *OrgResponse, err error)
+	Add(ctx context.Context, req *AddOrgRequest) (res *OrgResponse, err error)
+	SetTwoFactorPolicy(ctx context.Context, req *TwoFactorPolicyRequest) (res *OrgResponse, err error)
+	Invite(ctx context.Context, req *InviteRequest) (res *StatusResponse, err error)
+	AcceptInvite(ctx context.Context, req *AcceptInviteRequest) (res *OrgResponse, err error)
 }
 
-func NewOrgAPI(db *gorm.DB, logger log.AllLogger) OrgAPI {
+func NewOrgAPI(db *gorm.DB, logger log.AllLogger, uiAppUrl string) OrgAPI {
 	return &orgApi{
 		db: db,
 		logger: logger,
+		uiAppUrl: uiAppUrl,
 	}
 }
 
@@ -37,30 +68,28 @@ func NewOrgAPI(db *gorm.DB, logger log.AllLogger) OrgAPI {
 // @Param			AddOrgRequest					body		AddOrgRequest	true	"AddOrgRequest"
 // @Success			200								{object}	OrgResponse
 // @Router			/api/orgs	[POST]
-func (s *orgApi) Add(req *AddOrgRequest) (res *OrgResponse, err error) {
-	if req.UserID == 0 {
-		return nil, fmt.Errorf("user id is required")
-	}
+func (s *orgApi) Add(ctx context.Context, req *AddOrgRequest) (res *OrgResponse, err error) {
+	db := s.db.WithContext(ctx)
+
 	req.Name = strings.TrimSpace(req.Name)
 	req.Size = strings.TrimSpace(req.Size)
-	if req.Name == "" {
-		return nil, fmt.Errorf("name is required")
-	}
-	if req.Size == "" {
-		return nil, fmt.Errorf("size is required")
+	if err := validation.Struct(req); err != nil {
+		return nil, err
 	}
 
 	var user User
-	s.db.Where("id = ?", req.UserID).First(&user)
+	db.Where("id = ?", req.UserID).First(&user)
 	if user.ID == 0 {
 		return nil, helper.ErrNotFound
 	}
 	var org Org 
-	orgSlug := regexp.MustCompile(`[^a-zA-Z0-9]+`).ReplaceAllString(req.Name, "")
-	orgSlug = strings.ReplaceAll(strings.TrimSpace(orgSlug), " ", "-")
-	s.db.Where("slug = ?", orgSlug).First(&org)
+	orgSlug := slugify(req.Name)
+	if err := validation.Struct(&Org{Name: req.Name, Slug: orgSlug, Size: req.Size}); err != nil {
+		return nil, err
+	}
+	db.Where("slug = ?", orgSlug).First(&org)
 	if org.ID != 0 {
-		return nil, fmt.Errorf("org slug already exists")
+		return nil, apperr.New(apperr.Conflict, "org_slug_taken", "org slug already exists")
 	}
 
 	var trialSubscription subscriptionsvc.Subscription
@@ -81,9 +110,17 @@ func (s *orgApi) Add(req *AddOrgRequest) (res *OrgResponse, err error) {
 		subscriptionsvc.Feature{
 			Key: "UserCreateLimit",
 			Value: "20",
+		},
+		subscriptionsvc.Feature{
+			Key: usagesvc.DailyTokenLimitFeature,
+			Value: "50000",
+		},
+		subscriptionsvc.Feature{
+			Key: usagesvc.DailyAnalysisLimitFeature,
+			Value: "100",
 		})
 
-	result := s.db.Omit("UpdatedAt").Create(&trialSubscription)
+	result := db.Omit("UpdatedAt").Create(&trialSubscription)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -93,7 +130,7 @@ func (s *orgApi) Add(req *AddOrgRequest) (res *OrgResponse, err error) {
 	org.Slug = orgSlug
 	org.SubscriptionID = trialSubscription.ID
 
-	result = s.db.Omit("UpdatedAt").Create(&org)
+	result = db.Omit("UpdatedAt").Create(&org)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -105,7 +142,7 @@ func (s *orgApi) Add(req *AddOrgRequest) (res *OrgResponse, err error) {
 
 	// Find owner role
 	var ownerRole Role
-	result = s.db.Where("name = ?", helper.OwnerRoleName).First(&ownerRole)
+	result = db.Where("name = ?", helper.OwnerRoleName).First(&ownerRole)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -117,19 +154,289 @@ func (s *orgApi) Add(req *AddOrgRequest) (res *OrgResponse, err error) {
 	usrOrgRole.RoleID = int(ownerRole.ID)
 	usrOrgRole.Status = "active"
 
-	result = s.db.Omit("UpdatedAt").Create(&usrOrgRole)
+	result = db.Omit("UpdatedAt").Create(&usrOrgRole)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
-	result = s.db.Save(&org)
+	result = db.Save(&org)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
+	orgID := org.ID
+	s.record(ctx, audit.Event{
+		Actor:      req.Actor,
+		OrgID:      &orgID,
+		Action:     audit.ActionOrgCreated,
+		TargetType: audit.TargetOrg,
+		TargetID:   org.ID,
+		After:      map[string]interface{}{"name": org.Name, "slug": org.Slug, "size": org.Size},
+	})
+
+	return &OrgResponse{
+		ID: org.ID,
+		Name: org.Name,
+		OrgSlug: org.Slug,
+	}, nil
+}
+
+// @Summary      	SetTwoFactorPolicy
+// @Description		Validates that the user is the owner of the org and sets whether owners and admins of the org must use two-factor authentication.
+// @Tags			Orgs
+// @Accept			json
+// @Produce			json
+// @Param			Authorization					header		string			true	"Authorization Key(e.g Bearer key)"
+// @Param			orgId							path		int				true	"Org ID"
+// @Param			TwoFactorPolicyRequest			body		TwoFactorPolicyRequest	true	"TwoFactorPolicyRequest"
+// @Success			200								{object}	OrgResponse
+// @Router			/api/orgs/{orgId}/two-factor-policy	[PUT]
+func (s *orgApi) SetTwoFactorPolicy(ctx context.Context, req *TwoFactorPolicyRequest) (res *OrgResponse, err error) {
+	db := s.db.WithContext(ctx)
+
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+
+	var org Org
+	db.Where("id = ?", req.OrgID).First(&org)
+	if org.ID == 0 {
+		return nil, helper.ErrNotFound
+	}
+
+	var count int64
+	db.Model(&UserOrgRole{}).
+		Joins("JOIN roles ON roles.id = user_org_roles.role_id").
+		Where("user_org_roles.org_id = ? AND user_org_roles.user_id = ? AND user_org_roles.deleted_at IS NULL", org.ID, req.UserID).
+		Where("roles.name = ?", helper.OwnerRoleName).
+		Count(&count)
+	if count == 0 {
+		return nil, apperr.New(apperr.Forbidden, "org_owner_required", "only the org owner can change the two-factor policy")
+	}
+
+	before := org.RequireTwoFactor
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&org).Update("require_two_factor", req.Require).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			OrgID:      &req.OrgID,
+			Action:     audit.ActionOrgTwoFactorPolicy,
+			TargetType: audit.TargetOrg,
+			TargetID:   org.ID,
+			Before:     map[string]bool{"requireTwoFactor": before},
+			After:      map[string]bool{"requireTwoFactor": req.Require},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	org.RequireTwoFactor = req.Require
+
+	return &OrgResponse{
+		ID: org.ID,
+		Name: org.Name,
+		OrgSlug: org.Slug,
+		RequireTwoFactor: org.RequireTwoFactor,
+	}, nil
+}
+
+// @Summary      	Invite
+// @Description		Validates that the user is owner or admin of the org and emails a single-use invite link for the given role to the address. The link expires after 7 days and sending a new invite to the same address does not revoke earlier ones.
+// @Tags			Orgs
+// @Accept			json
+// @Produce			json
+// @Param			Authorization					header		string			true	"Authorization Key(e.g Bearer key)"
+// @Param			orgId							path		int				true	"Org ID"
+// @Param			InviteRequest					body		InviteRequest	true	"InviteRequest"
+// @Success			200								{object}	StatusResponse
+// @Router			/api/orgs/{orgId}/invites	[POST]
+func (s *orgApi) Invite(ctx context.Context, req *InviteRequest) (res *StatusResponse, err error) {
+	db := s.db.WithContext(ctx)
+
+	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+
+	var org Org
+	db.Where("id = ?", req.OrgID).First(&org)
+	if org.ID == 0 {
+		return nil, helper.ErrNotFound
+	}
+
+	var count int64
+	db.Model(&UserOrgRole{}).
+		Joins("JOIN roles ON roles.id = user_org_roles.role_id").
+		Where("user_org_roles.org_id = ? AND user_org_roles.user_id = ? AND user_org_roles.deleted_at IS NULL", org.ID, req.UserID).
+		Where("roles.name IN ?", []string{helper.OwnerRoleName, helper.AdminRoleName}).
+		Count(&count)
+	if count == 0 {
+		return nil, apperr.New(apperr.Forbidden, "org_admin_required", "only org owners and admins can invite users")
+	}
+
+	var roleName string
+	db.Table("roles").Select("name").Where("id = ? AND deleted_at IS NULL", req.RoleID).Scan(&roleName)
+	if roleName == "" {
+		return nil, helper.ErrNotFound
+	}
+	if roleName == helper.OwnerRoleName {
+		return nil, apperr.New(apperr.Forbidden, "owner_invite_forbidden", "users can not be invited as owner")
+	}
+
+	data, err := json.Marshal(inviteData{OrgID: org.ID, RoleID: req.RoleID})
+	if err != nil {
+		return nil, err
+	}
+	// Invitees may not have an account yet, so the inviter's language is used
+	var locale string
+	db.Table("users").Select("locale").Where("id = ?", req.UserID).Scan(&locale)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		t, err := session.IssueOneTimeToken(tx, session.PurposeOrgInvite, 0, req.Email, string(data), inviteTokenTTL)
+		if err != nil {
+			s.logger.Errorf("func: Invite, operation: session.IssueOneTimeToken, err: %s", err.Error())
+			return fmt.Errorf("failed to generate token")
+		}
+
+		err = audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			OrgID:      &req.OrgID,
+			Action:     audit.ActionOrgInviteSent,
+			TargetType: audit.TargetOrg,
+			TargetID:   org.ID,
+			After:      map[string]interface{}{"email": req.Email, "roleId": req.RoleID},
+		})
+		if err != nil {
+			return err
+		}
+
+		return mailer.Enqueue(tx, mailer.Message{
+			To:       req.Email,
+			Template: mailer.TemplateOrgInvite,
+			Locale:   locale,
+			Data:     map[string]string{"OrgName": org.Name, "Link": s.uiAppUrl + "/invites/" + t},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatusResponse{
+		Status: true,
+	}, nil
+}
+
+// @Summary      	AcceptInvite
+// @Description		Redeems the single-use invite token for the logged in user, whose email must be the invited address, and adds the user to the org with the invited role.
+// @Tags			Orgs
+// @Produce			json
+// @Param			Authorization					header		string			true	"Authorization Key(e.g Bearer key)"
+// @Param			token							path		string			true	"Invite Token"
+// @Success			200								{object}	OrgResponse
+// @Router			/api/orgs/invites/{token}/accept	[POST]
+func (s *orgApi) AcceptInvite(ctx context.Context, req *AcceptInviteRequest) (res *OrgResponse, err error) {
+	db := s.db.WithContext(ctx)
+
+	if req.UserID == 0 {
+		return nil, apperr.New(apperr.Invalid, "missing_user_id", "user id is required")
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" {
+		return nil, apperr.New(apperr.Invalid, "missing_token", "token is required")
+	}
+
+	var email string
+	db.Table("users").Select("email").Where("id = ? AND deleted_at IS NULL", req.UserID).Scan(&email)
+	if email == "" {
+		return nil, helper.ErrNotFound
+	}
+
+	var org Org
+	err = db.Transaction(func(tx *gorm.DB) error {
+		token, err := session.ConsumeOneTimeToken(tx, session.PurposeOrgInvite, req.Token)
+		if err != nil {
+			return err
+		}
+		// Rolling back keeps the token usable by the invited user
+		if !strings.EqualFold(token.Email, email) {
+			return apperr.New(apperr.Forbidden, "invite_email_mismatch", "this invite was sent to a different email")
+		}
+
+		var data inviteData
+		if err := json.Unmarshal([]byte(token.Data), &data); err != nil {
+			return session.ErrInvalidOneTimeToken
+		}
+
+		tx.Where("id = ? AND deleted_at IS NULL", data.OrgID).First(&org)
+		if org.ID == 0 {
+			return helper.ErrNotFound
+		}
+
+		var count int64
+		tx.Model(&UserOrgRole{}).
+			Where("org_id = ? AND user_id = ? AND deleted_at IS NULL", org.ID, req.UserID).
+			Count(&count)
+		if count > 0 {
+			return apperr.New(apperr.Conflict, "already_member", "user is already a member of the org")
+		}
+
+		usrOrgRole := UserOrgRole{
+			OrgID:  org.ID,
+			UserID: req.UserID,
+			RoleID: data.RoleID,
+			Status: "active",
+		}
+		if err := tx.Omit("UpdatedAt").Create(&usrOrgRole).Error; err != nil {
+			return err
+		}
+
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			OrgID:      &org.ID,
+			Action:     audit.ActionOrgInviteAccepted,
+			TargetType: audit.TargetOrg,
+			TargetID:   org.ID,
+			After:      map[string]interface{}{"userId": req.UserID, "roleId": data.RoleID},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	session.ForgetUser(req.UserID)
+
 	return &OrgResponse{
 		ID: org.ID,
 		Name: org.Name,
 		OrgSlug: org.Slug,
+		RequireTwoFactor: org.RequireTwoFactor,
 	}, nil
 }
+
+// slugify turns an org name into its slug, e.g. "Acme Media, Inc." into
+// "acme-media-inc". Accents are dropped, so "Shqipëri Çka" becomes
+// "shqiperi-cka". Names without any latin letter or digit, e.g. in Cyrillic
+// or Greek, get a random slug.
+func slugify(name string) string {
+	var latin strings.Builder
+	for _, r := range norm.NFD.String(slugLetters.Replace(strings.ToLower(name))) {
+		if !unicode.Is(unicode.Mn, r) {
+			latin.WriteRune(r)
+		}
+	}
+
+	slug := strings.Trim(slugSeparators.ReplaceAllString(latin.String(), "-"), "-")
+	if slug == "" {
+		return fmt.Sprintf("org-%08x", rand.Uint32())
+	}
+	return slug
+}
+
+// record writes an audit event that isn't part of a transaction. A failure is
+// logged rather than failing the already completed action.
+func (s *orgApi) record(ctx context.Context, event audit.Event) {
+	if err := audit.Record(s.db.WithContext(ctx), event); err != nil {
+		s.logger.Errorf("func: record, operation: audit.Record, action: %s, err: %s", event.Action, err.Error())
+	}
+}
@@ -0,0 +1,32 @@
+package orgs
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Acme Media, Inc.":      "acme-media-inc",
+		"Shqipëri Çka":          "shqiperi-cka",
+		"Tiranë Post":           "tirane-post",
+		"  --Radio  Kosova-- ":  "radio-kosova",
+		"Straße Œuvre Łódź":     "strasse-oeuvre-lodz",
+		"Радио Ελλάδα TV 24":    "tv-24",
+		"Café Zürich São Paulo": "cafe-zurich-sao-paulo",
+	}
+	for name, want := range tests {
+		if got := slugify(name); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSlugifyWithoutLatinLetters(t *testing.T) {
+	random := regexp.MustCompile(`^org-[0-9a-f]{8}$`)
+	for _, name := range []string{"Радио Слобода", "Ελληνική Ραδιοφωνία", "!!!"} {
+		if got := slugify(name); !random.MatchString(got) {
+			t.Errorf("slugify(%q) = %q, want a random org- slug", name, got)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package orgs
 
 import (
+	"strconv"
+	"vezhguesi/core/audit"
 	"vezhguesi/core/middleware"
 	"vezhguesi/helper"
 
@@ -10,6 +12,9 @@ import (
 
 type OrgHTTPTransport interface {
 	Add(c *fiber.Ctx) error
+	SetTwoFactorPolicy(c *fiber.Ctx) error
+	Invite(c *fiber.Ctx) error
+	AcceptInvite(c *fiber.Ctx) error
 }
 
 type orgHttpTransport struct {
@@ -32,13 +37,82 @@ func (s *orgHttpTransport) Add(c *fiber.Ctx) error {
 	}
 	req.UserID = userId
 	if err := c.BodyParser(req); err != nil {
-		return helper.HTTPError(c, err, "OrgHTTPTransport.BodyParser")
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "OrgHTTPTransport.BodyParser")
 	}
+	req.Actor = audit.ActorFrom(c)
 
-	resp, err := s.orgApi.Add(req)
+	resp, err := s.orgApi.Add(c.UserContext(), req)
 	if err != nil {
 		return helper.HTTPError(c, err, "OrgHTTPTransport.Add")
 	}
 
 	return c.JSON(resp)
 }
+
+func (s *orgHttpTransport) SetTwoFactorPolicy(c *fiber.Ctx) error {
+	req := &TwoFactorPolicyRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "OrgHTTPTransport.CtxUserID")
+	}
+	req.UserID = userId
+	orgId, err := strconv.Atoi(c.Params("orgId"))
+	if err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidArgument, "OrgHTTPTransport.strconv.Atoi")
+	}
+	req.OrgID = orgId
+	if err := c.BodyParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "OrgHTTPTransport.BodyParser")
+	}
+	req.Actor = audit.ActorFrom(c)
+
+	resp, err := s.orgApi.SetTwoFactorPolicy(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "OrgHTTPTransport.SetTwoFactorPolicy")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *orgHttpTransport) Invite(c *fiber.Ctx) error {
+	req := &InviteRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "OrgHTTPTransport.CtxUserID")
+	}
+	req.UserID = userId
+	orgId, err := strconv.Atoi(c.Params("orgId"))
+	if err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidArgument, "OrgHTTPTransport.strconv.Atoi")
+	}
+	req.OrgID = orgId
+	if err := c.BodyParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "OrgHTTPTransport.BodyParser")
+	}
+	req.Actor = audit.ActorFrom(c)
+
+	resp, err := s.orgApi.Invite(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "OrgHTTPTransport.Invite")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *orgHttpTransport) AcceptInvite(c *fiber.Ctx) error {
+	req := &AcceptInviteRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "OrgHTTPTransport.CtxUserID")
+	}
+	req.UserID = userId
+	req.Token = c.Params("token")
+	req.Actor = audit.ActorFrom(c)
+
+	resp, err := s.orgApi.AcceptInvite(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "OrgHTTPTransport.AcceptInvite")
+	}
+
+	return c.JSON(resp)
+}
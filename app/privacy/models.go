@@ -0,0 +1,118 @@
+package privacy
+
+import (
+	"time"
+	"vezhguesi/core/audit"
+)
+
+type ExportRequest struct {
+	UserID int         `json:"-"`
+	Actor  audit.Actor `json:"-"`
+}
+
+type ExportResponse struct {
+	FileName string
+	Data     []byte
+}
+
+type OwnershipTransfer struct {
+	OrgID      int `json:"orgId"`
+	NewOwnerID int `json:"newOwnerId"`
+}
+
+type DeleteAccountRequest struct {
+	UserID int `json:"-"`
+	// Password confirms the deletion. Accounts without a password, e.g. only
+	// signed in with an external provider, confirm with their email instead.
+	Password     string `json:"password"`
+	ConfirmEmail string `json:"confirmEmail"`
+	// Transfers picks the new owner of orgs the user owns. Orgs left out go
+	// to their oldest admin, else their oldest member, and orgs without other
+	// members are deleted.
+	Transfers []OwnershipTransfer `json:"transfers"`
+	Actor     audit.Actor         `json:"-"`
+}
+
+type DeleteAccountResponse struct {
+	Status          bool                `json:"status"`
+	TransferredOrgs []OwnershipTransfer `json:"transferredOrgs"`
+	DeletedOrgIDs   []int               `json:"deletedOrgIds"`
+}
+
+// Export file contents
+
+type profileExport struct {
+	ID            int        `json:"id"`
+	Email         string     `json:"email"`
+	Username      string     `json:"username"`
+	FirstName     string     `json:"firstName"`
+	LastName      string     `json:"lastName"`
+	Phone         string     `json:"phone"`
+	Status        string     `json:"status"`
+	Active        bool       `json:"active"`
+	VerifiedEmail bool       `json:"verifiedEmail"`
+	Role          string     `json:"role"`
+	Locale        string     `json:"locale"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     *time.Time `json:"updatedAt"`
+}
+
+type sessionExport struct {
+	ID         uint       `json:"id"`
+	DeviceName string     `json:"deviceName"`
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+}
+
+type identityExport struct {
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"lastLoginAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+type membershipExport struct {
+	OrgID     int       `json:"orgId"`
+	OrgName   string    `json:"orgName"`
+	Role      string    `json:"role"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"joinedAt"`
+}
+
+type reportExport struct {
+	ID         uint      `json:"id"`
+	Title      string    `json:"title"`
+	Subject    string    `json:"subject"`
+	ReportText string    `json:"reportText"`
+	Entities   []string  `json:"entities"`
+	Findings   string    `json:"findings"`
+	Sentiment  int       `json:"sentiment"`
+	StartDate  time.Time `json:"startDate"`
+	EndDate    time.Time `json:"endDate"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type entityReportExport struct {
+	EntityReportID uint      `json:"entityReportId"`
+	EntityName     string    `json:"entityName"`
+	Summary        string    `json:"summary"`
+	LastAnalyzed   time.Time `json:"lastAnalyzed"`
+	CreatedAt      time.Time `json:"followedAt"`
+}
+
+type apiKeyExport struct {
+	ID         uint       `json:"id"`
+	OrgID      *int       `json:"orgId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     string     `json:"scopes"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
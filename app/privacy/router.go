@@ -0,0 +1,13 @@
+package privacy
+
+import (
+	"vezhguesi/core/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterRoutes(router fiber.Router, privacyHttpApi PrivacyHTTPTransport, authMiddleware func(c *fiber.Ctx) error) {
+	meRoutes := router.Group("/users/me")
+	meRoutes.Post("/export", authMiddleware, privacyHttpApi.Export)
+	meRoutes.Delete("", authMiddleware, middleware.DenyImpersonation, privacyHttpApi.DeleteAccount)
+}
@@ -0,0 +1,581 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	entity_reportsvc "vezhguesi/app/entity_reports"
+	orgsvc "vezhguesi/app/orgs"
+	reportsvc "vezhguesi/app/reports"
+	usagesvc "vezhguesi/app/usage"
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
+	session "vezhguesi/core/authentication"
+	rolesvc "vezhguesi/core/authorization/role"
+	"vezhguesi/core/mailer"
+	"vezhguesi/core/storage"
+	"vezhguesi/core/users"
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2/log"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const exportReadme = `This archive holds the personal data stored about your account.
+
+profile.json          your account details
+sessions.json         devices signed in to your account
+identities.json       external sign-in providers linked to your account
+orgs.json             organizations you are a member of
+reports.json          reports you created
+entity_reports.json   entity reports you follow
+api_keys.json         API keys you created (the keys themselves are never stored)
+usage.json            billable actions performed by you
+audit_log.json        security and change events performed by or on your account
+avatar.jpg            your avatar, if you uploaded one
+`
+
+type privacyApi struct {
+	db     *gorm.DB
+	logger log.AllLogger
+	blobs  storage.BlobStore
+}
+
+type PrivacyAPI interface {
+	Export(ctx context.Context, req *ExportRequest) (res *ExportResponse, err error)
+	DeleteAccount(ctx context.Context, req *DeleteAccountRequest) (res *DeleteAccountResponse, err error)
+}
+
+func NewPrivacyAPI(db *gorm.DB, logger log.AllLogger, blobs storage.BlobStore) PrivacyAPI {
+	return &privacyApi{db: db, logger: logger, blobs: blobs}
+}
+
+// @Summary      	Export My Data
+// @Description		Assembles a ZIP archive of the personal data stored about the user: profile, sessions, linked identities, org memberships, reports, followed entity reports, API keys, usage, audit entries and avatar.
+// @Tags			Privacy
+// @Produce			application/zip
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Success			200					{file}	file
+// @Router			/api/users/me/export	[POST]
+func (s *privacyApi) Export(ctx context.Context, req *ExportRequest) (res *ExportResponse, err error) {
+	if req.UserID == 0 {
+		return nil, apperr.New(apperr.Invalid, "missing_user_id", "user id is required")
+	}
+
+	var user users.User
+	if err := s.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", req.UserID).First(&user).Error; err != nil {
+		return nil, helper.ErrNotFound
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := []struct {
+		name  string
+		fetch func(ctx context.Context, userID int) (interface{}, error)
+	}{
+		{"profile.json", func(context.Context, int) (interface{}, error) { return profileOf(&user), nil }},
+		{"sessions.json", s.exportSessions},
+		{"identities.json", s.exportIdentities},
+		{"orgs.json", s.exportMemberships},
+		{"reports.json", s.exportReports},
+		{"entity_reports.json", s.exportEntityReports},
+		{"api_keys.json", s.exportAPIKeys},
+		{"usage.json", s.exportUsage},
+		{"audit_log.json", s.exportAuditLog},
+	}
+
+	if err := writeFile(archive, "README.txt", []byte(exportReadme)); err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := file.fetch(ctx, user.ID)
+		if err != nil {
+			s.logger.Errorf("func: Export, operation: %s, err: %s", file.name, err.Error())
+			return nil, fmt.Errorf("failed to export %s", file.name)
+		}
+		b, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFile(archive, file.name, b); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.exportAvatar(ctx, archive, user.AvatarImgKey); err != nil {
+		s.logger.Errorf("func: Export, operation: s.exportAvatar, err: %s", err.Error())
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	err = audit.Record(s.db.WithContext(ctx), audit.Event{
+		Actor:      req.Actor,
+		Action:     audit.ActionDataExported,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+	})
+	if err != nil {
+		s.logger.Errorf("func: Export, operation: audit.Record, err: %s", err.Error())
+	}
+
+	return &ExportResponse{
+		FileName: fmt.Sprintf("vezhguesi-export-%d-%s.zip", user.ID, time.Now().Format("20060102")),
+		Data:     buf.Bytes(),
+	}, nil
+}
+
+func writeFile(archive *zip.Writer, name string, data []byte) error {
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func profileOf(user *users.User) profileExport {
+	profile := profileExport{
+		ID:            user.ID,
+		Email:         user.Email,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Phone:         user.Phone,
+		Status:        user.Status,
+		Active:        user.Active,
+		VerifiedEmail: user.VerifiedEmail,
+		Role:          user.Role,
+		Locale:        user.Locale,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+	if user.Username != nil {
+		profile.Username = *user.Username
+	}
+	return profile
+}
+
+func (s *privacyApi) exportSessions(ctx context.Context, userID int) (interface{}, error) {
+	var sessions []session.Session
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]sessionExport, 0, len(sessions))
+	for _, sess := range sessions {
+		res = append(res, sessionExport{
+			ID:         sess.ID,
+			DeviceName: sess.DeviceName,
+			UserAgent:  sess.UserAgent,
+			IP:         sess.IP,
+			LastUsedAt: sess.LastUsedAt,
+			RevokedAt:  sess.RevokedAt,
+			CreatedAt:  sess.CreatedAt,
+			ExpiresAt:  sess.ExpiresAt,
+		})
+	}
+	return res, nil
+}
+
+func (s *privacyApi) exportIdentities(ctx context.Context, userID int) (interface{}, error) {
+	var identities []session.ProviderIdentity
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]identityExport, 0, len(identities))
+	for _, identity := range identities {
+		res = append(res, identityExport{
+			Provider:    identity.Provider,
+			Email:       identity.Email,
+			LastLoginAt: identity.LastLoginAt,
+			CreatedAt:   identity.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+func (s *privacyApi) exportMemberships(ctx context.Context, userID int) (interface{}, error) {
+	res := []membershipExport{}
+	err := s.db.WithContext(ctx).Table("user_org_roles").
+		Select("orgs.id AS org_id, orgs.name AS org_name, roles.name AS role, user_org_roles.status, user_org_roles.created_at").
+		Joins("JOIN orgs ON orgs.id = user_org_roles.org_id").
+		Joins("JOIN roles ON roles.id = user_org_roles.role_id").
+		Where("user_org_roles.user_id = ? AND user_org_roles.deleted_at IS NULL", userID).
+		Order("user_org_roles.created_at").
+		Scan(&res).Error
+	return res, err
+}
+
+func (s *privacyApi) exportReports(ctx context.Context, userID int) (interface{}, error) {
+	var reports []reportsvc.Report
+	if err := s.db.WithContext(ctx).Preload("Entities").Where("user_id = ?", userID).Order("created_at").Find(&reports).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]reportExport, 0, len(reports))
+	for _, report := range reports {
+		entityNames := make([]string, 0, len(report.Entities))
+		for _, entity := range report.Entities {
+			entityNames = append(entityNames, entity.Name)
+		}
+		res = append(res, reportExport{
+			ID:         report.ID,
+			Title:      report.Title,
+			Subject:    report.Subject,
+			ReportText: report.ReportText,
+			Entities:   entityNames,
+			Findings:   report.Findings,
+			Sentiment:  report.Sentiment,
+			StartDate:  report.StartDate,
+			EndDate:    report.EndDate,
+			CreatedAt:  report.CreatedAt,
+			UpdatedAt:  report.UpdatedAt,
+		})
+	}
+	return res, nil
+}
+
+func (s *privacyApi) exportEntityReports(ctx context.Context, userID int) (interface{}, error) {
+	res := []entityReportExport{}
+	err := s.db.WithContext(ctx).Table("user_entity_reports").
+		Select("entity_reports.id AS entity_report_id, entities.name AS entity_name, entity_reports.summary, entity_reports.last_analyzed, user_entity_reports.created_at").
+		Joins("JOIN entity_reports ON entity_reports.id = user_entity_reports.entity_report_id").
+		Joins("JOIN entities ON entities.id = entity_reports.entity_id").
+		Where("user_entity_reports.user_id = ?", userID).
+		Order("user_entity_reports.created_at").
+		Scan(&res).Error
+	return res, err
+}
+
+func (s *privacyApi) exportAPIKeys(ctx context.Context, userID int) (interface{}, error) {
+	var keys []session.APIKey
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]apiKeyExport, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, apiKeyExport{
+			ID:         key.ID,
+			OrgID:      key.OrgID,
+			Name:       key.Name,
+			Prefix:     key.Prefix,
+			Scopes:     key.Scopes,
+			LastUsedAt: key.LastUsedAt,
+			ExpiresAt:  key.ExpiresAt,
+			RevokedAt:  key.RevokedAt,
+			CreatedAt:  key.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+func (s *privacyApi) exportUsage(ctx context.Context, userID int) (interface{}, error) {
+	var events []usagesvc.UsageEvent
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (s *privacyApi) exportAuditLog(ctx context.Context, userID int) (interface{}, error) {
+	var logs []audit.Log
+	err := s.db.WithContext(ctx).
+		Where("actor_user_id = ? OR (target_type = ? AND target_id = ?)", userID, audit.TargetUser, strconv.Itoa(userID)).
+		Order("created_at").
+		Find(&logs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]audit.LogResponse, 0, len(logs))
+	for i := range logs {
+		res = append(res, audit.NewLogResponse(&logs[i]))
+	}
+	return res, nil
+}
+
+func (s *privacyApi) exportAvatar(ctx context.Context, archive *zip.Writer, key string) error {
+	if key == "" {
+		return nil
+	}
+	largest := users.AvatarSizes[len(users.AvatarSizes)-1]
+
+	r, err := s.blobs.Get(ctx, fmt.Sprintf("%s/%d.jpg", key, largest))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := archive.Create("avatar.jpg")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// @Summary      	Delete My Account
+// @Description		Confirmed with the password, or the account email for accounts without one. Hands orgs the user owns to a new owner, anonymizes the account, unfollows entity reports and removes sessions, linked identities, two-factor settings, API keys and queued emails. Audit entries and usage are kept without the personal data.
+// @Tags			Privacy
+// @Accept			json
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			DeleteAccountRequest	body		DeleteAccountRequest	true	"DeleteAccountRequest"
+// @Success			200					{object}	DeleteAccountResponse
+// @Router			/api/users/me	[DELETE]
+func (s *privacyApi) DeleteAccount(ctx context.Context, req *DeleteAccountRequest) (res *DeleteAccountResponse, err error) {
+	if req.UserID == 0 {
+		return nil, apperr.New(apperr.Invalid, "missing_user_id", "user id is required")
+	}
+
+	var user users.User
+	if err := s.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", req.UserID).First(&user).Error; err != nil {
+		return nil, helper.ErrNotFound
+	}
+	if user.Password != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			return nil, apperr.New(apperr.Invalid, "invalid_password", "invalid password")
+		}
+	} else if !strings.EqualFold(strings.TrimSpace(req.ConfirmEmail), user.Email) {
+		return nil, apperr.New(apperr.Invalid, "invalid_confirmation", "invalid confirmation, confirmEmail must match the account email")
+	}
+
+	// Keep what the cleanup needs before the account is anonymized
+	email, avatarKey := user.Email, user.AvatarImgKey
+
+	res = &DeleteAccountResponse{Status: true, TransferredOrgs: []OwnershipTransfer{}, DeletedOrgIDs: []int{}}
+	now := time.Now()
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.handOverOrgs(tx, &user, req, res); err != nil {
+			return err
+		}
+
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"email":          fmt.Sprintf("deleted-%d@deleted.invalid", user.ID),
+			"username":       nil,
+			"password":       "",
+			"first_name":     "Deleted",
+			"last_name":      "User",
+			"phone":          "",
+			"avatar_img_key": "",
+			"status":         "deleted",
+			"active":         false,
+			"verified_email": false,
+			"deleted_at":     now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&entity_reportsvc.UserEntityReport{}).Error; err != nil {
+			return err
+		}
+
+		// Emails the account was reached at: its own and those of pending
+		// email changes
+		emails := []string{email}
+		var tokenEmails []string
+		if err := tx.Model(&session.OneTimeToken{}).Where("user_id = ? AND email <> ''", user.ID).Distinct().Pluck("email", &tokenEmails).Error; err != nil {
+			return err
+		}
+		for _, tokenEmail := range tokenEmails {
+			if !strings.EqualFold(tokenEmail, email) {
+				emails = append(emails, tokenEmail)
+			}
+		}
+		if err := tx.Where(`"to" IN ?`, emails).Delete(&mailer.OutboxEmail{}).Error; err != nil {
+			return err
+		}
+		if err := audit.ScrubUser(tx, user.ID, emails...); err != nil {
+			return err
+		}
+		// Usage stays counted for the org, without the user
+		if err := tx.Model(&usagesvc.UsageEvent{}).Where("user_id = ?", user.ID).Update("user_id", 0).Error; err != nil {
+			return err
+		}
+		sessionIDs := tx.Model(&session.Session{}).Select("id").Where("user_id = ?", user.ID)
+		if err := tx.Where("session_id IN (?)", sessionIDs).Delete(&session.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{
+			&session.Session{},
+			&session.TwoFactor{},
+			&session.RecoveryCode{},
+			&session.ProviderIdentity{},
+			&session.OneTimeToken{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		for _, model := range []interface{}{&session.LoginAttempt{}, &session.AccountLockout{}} {
+			if err := tx.Where("email = ?", email).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		err = tx.Model(&session.APIKey{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&orgsvc.UserOrgRole{}).
+			Where("user_id = ? AND deleted_at IS NULL", user.ID).
+			Update("deleted_at", now).Error
+		if err != nil {
+			return err
+		}
+
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionAccountDeleted,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+		})
+	})
+	if err != nil {
+		s.logger.Errorf("func: DeleteAccount, operation: s.db.Transaction, err: %s", err.Error())
+		return nil, err
+	}
+
+	session.ForgetUser(user.ID)
+	for _, transfer := range res.TransferredOrgs {
+		session.ForgetUser(transfer.NewOwnerID)
+	}
+	// The account is gone already, its avatar is removed even when the client
+	// hangs up
+	if avatarKey != "" {
+		if err := s.blobs.DeletePrefix(context.WithoutCancel(ctx), avatarKey+"/"); err != nil {
+			s.logger.Errorf("func: DeleteAccount, operation: s.blobs.DeletePrefix, err: %s", err.Error())
+		}
+	}
+
+	return res, nil
+}
+
+// handOverOrgs gives every org the user is the only owner of to a new owner:
+// the one picked in the request, else the oldest admin, else the oldest
+// member. Orgs without other members are deleted.
+func (s *privacyApi) handOverOrgs(tx *gorm.DB, user *users.User, req *DeleteAccountRequest, res *DeleteAccountResponse) error {
+	var ownedOrgIDs []int
+	err := tx.Table("user_org_roles").
+		Joins("JOIN roles ON roles.id = user_org_roles.role_id").
+		Joins("JOIN orgs ON orgs.id = user_org_roles.org_id AND orgs.deleted_at IS NULL").
+		Where("user_org_roles.user_id = ? AND user_org_roles.deleted_at IS NULL AND roles.name = ?", user.ID, helper.OwnerRoleName).
+		Pluck("user_org_roles.org_id", &ownedOrgIDs).Error
+	if err != nil {
+		return err
+	}
+
+	picked := make(map[int]int, len(req.Transfers))
+	for _, transfer := range req.Transfers {
+		if !containsInt(ownedOrgIDs, transfer.OrgID) {
+			return apperr.Newf(apperr.Invalid, "invalid_transfer", "invalid transfer, you don't own org %d", transfer.OrgID)
+		}
+		picked[transfer.OrgID] = transfer.NewOwnerID
+	}
+
+	var ownerRole rolesvc.Role
+	if len(ownedOrgIDs) > 0 {
+		if err := tx.Where("name = ? AND deleted_at IS NULL", helper.OwnerRoleName).First(&ownerRole).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, orgID := range ownedOrgIDs {
+		orgID := orgID
+		members := tx.Table("user_org_roles").
+			Joins("JOIN roles ON roles.id = user_org_roles.role_id").
+			Joins("JOIN users ON users.id = user_org_roles.user_id AND users.deleted_at IS NULL").
+			Where("user_org_roles.org_id = ? AND user_org_roles.user_id <> ? AND user_org_roles.deleted_at IS NULL", orgID, user.ID).
+			Session(&gorm.Session{})
+
+		var otherOwners int64
+		if err := members.Where("roles.name = ?", helper.OwnerRoleName).Count(&otherOwners).Error; err != nil {
+			return err
+		}
+		if otherOwners > 0 {
+			continue
+		}
+
+		var newOwnerIDs []int
+		if id, ok := picked[orgID]; ok {
+			err = members.Where("user_org_roles.user_id = ?", id).Pluck("user_org_roles.user_id", &newOwnerIDs).Error
+			if err == nil && len(newOwnerIDs) == 0 {
+				return apperr.Newf(apperr.Invalid, "invalid_transfer", "invalid transfer, user %d is not a member of org %d", id, orgID)
+			}
+		} else {
+			err = members.
+				Order(clause.OrderBy{Expression: clause.Expr{
+					SQL:                "roles.name = ? DESC, user_org_roles.created_at",
+					Vars:               []interface{}{helper.AdminRoleName},
+					WithoutParentheses: true,
+				}}).
+				Limit(1).
+				Pluck("user_org_roles.user_id", &newOwnerIDs).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(newOwnerIDs) == 0 {
+			if err := tx.Model(&orgsvc.Org{}).Where("id = ?", orgID).Update("deleted_at", time.Now()).Error; err != nil {
+				return err
+			}
+			err := audit.Record(tx, audit.Event{
+				Actor:      req.Actor,
+				OrgID:      &orgID,
+				Action:     audit.ActionOrgDeleted,
+				TargetType: audit.TargetOrg,
+				TargetID:   orgID,
+			})
+			if err != nil {
+				return err
+			}
+			res.DeletedOrgIDs = append(res.DeletedOrgIDs, orgID)
+			continue
+		}
+
+		newOwnerID := newOwnerIDs[0]
+		err = tx.Table("user_org_roles").
+			Where("org_id = ? AND user_id = ? AND deleted_at IS NULL", orgID, newOwnerID).
+			Update("role_id", ownerRole.ID).Error
+		if err != nil {
+			return err
+		}
+		err = audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			OrgID:      &orgID,
+			Action:     audit.ActionOrgOwnershipTransferred,
+			TargetType: audit.TargetOrg,
+			TargetID:   orgID,
+			Before:     map[string]int{"ownerId": user.ID},
+			After:      map[string]int{"ownerId": newOwnerID},
+		})
+		if err != nil {
+			return err
+		}
+		res.TransferredOrgs = append(res.TransferredOrgs, OwnershipTransfer{OrgID: orgID, NewOwnerID: newOwnerID})
+	}
+
+	return nil
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package privacy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	usagesvc "vezhguesi/app/usage"
+	"vezhguesi/core/audit"
+	"vezhguesi/core/db/dbtest"
+	"vezhguesi/core/logging"
+	"vezhguesi/core/mailer"
+	"vezhguesi/core/storage"
+	"vezhguesi/core/users"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDeleteAccountScrubsPersonalData(t *testing.T) {
+	conn := dbtest.Open(t)
+	api := NewPrivacyAPI(conn, logging.Logger("privacy"), storage.NewLocalStore(t.TempDir(), "http://localhost/api/files", dbtest.SecretKey))
+
+	email := fmt.Sprintf("delete-%d@example.com", time.Now().UnixNano())
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := users.User{Email: email, Password: string(hash), FirstName: "Ana", LastName: "Hoxha", Role: "user", Active: true, VerifiedEmail: true}
+	if err := conn.Omit("UpdatedAt").Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Where("target_type = ? AND target_id = ?", audit.TargetUser, fmt.Sprint(user.ID)).Delete(&audit.Log{})
+		conn.Where(`"to" = ?`, email).Delete(&mailer.OutboxEmail{})
+		conn.Delete(&users.User{}, user.ID)
+	})
+
+	err = audit.Record(conn, audit.Event{
+		Action:     audit.ActionSignup,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		After:      map[string]interface{}{"email": email, "firstName": "Ana", "lastName": "Hoxha", "role": "user"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mailer.Enqueue(conn, mailer.Message{To: email, Template: mailer.TemplatePasswordChanged, Data: map[string]string{"Name": "Ana"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := usagesvc.UsageEvent{UserID: user.ID, Kind: "test"}
+	if err := conn.Create(&usage).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Delete(&usagesvc.UsageEvent{}, usage.ID) })
+
+	if _, err := api.DeleteAccount(context.Background(), &DeleteAccountRequest{UserID: user.ID, Password: "password"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs []audit.Log
+	conn.Where("target_type = ? AND target_id = ?", audit.TargetUser, fmt.Sprint(user.ID)).Find(&logs)
+	if len(logs) == 0 {
+		t.Fatal("the audit entries of the user were deleted")
+	}
+	for _, entry := range logs {
+		for _, personal := range []string{email, "Ana", "Hoxha"} {
+			if strings.Contains(entry.Changes, personal) {
+				t.Errorf("audit entry %s still holds %q: %s", entry.Action, personal, entry.Changes)
+			}
+		}
+	}
+
+	var queued int64
+	conn.Model(&mailer.OutboxEmail{}).Where(`"to" = ?`, email).Count(&queued)
+	if queued != 0 {
+		t.Errorf("%d emails to the account are still queued", queued)
+	}
+
+	if err := conn.First(&usage, usage.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if usage.UserID != 0 {
+		t.Errorf("usage event still belongs to user %d", usage.UserID)
+	}
+}
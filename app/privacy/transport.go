@@ -0,0 +1,62 @@
+package privacy
+
+import (
+	"fmt"
+	"vezhguesi/core/audit"
+	"vezhguesi/core/middleware"
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PrivacyHTTPTransport interface {
+	Export(c *fiber.Ctx) error
+	DeleteAccount(c *fiber.Ctx) error
+}
+
+type privacyHttpTransport struct {
+	privacyAPI PrivacyAPI
+}
+
+func NewPrivacyHTTPTransport(privacyAPI PrivacyAPI) PrivacyHTTPTransport {
+	return &privacyHttpTransport{privacyAPI: privacyAPI}
+}
+
+func (s *privacyHttpTransport) Export(c *fiber.Ctx) error {
+	req := &ExportRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "ExportData.middleware.CtxUserID")
+	}
+	req.UserID = userId
+	req.Actor = audit.ActorFrom(c)
+
+	resp, err := s.privacyAPI.Export(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "ExportData.privacyAPI.Export")
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, resp.FileName))
+	return c.Send(resp.Data)
+}
+
+func (s *privacyHttpTransport) DeleteAccount(c *fiber.Ctx) error {
+	req := &DeleteAccountRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "DeleteAccount.middleware.CtxUserID")
+	}
+	if err := c.BodyParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "DeleteAccount.c.BodyParser")
+	}
+	req.UserID = userId
+	req.Actor = audit.ActorFrom(c)
+
+	resp, err := s.privacyAPI.DeleteAccount(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "DeleteAccount.privacyAPI.DeleteAccount")
+	}
+
+	return c.JSON(resp)
+}
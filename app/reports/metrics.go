@@ -0,0 +1,34 @@
+package reports
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sashabaranov/go-openai"
+)
+
+var (
+	llmRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vezhguesi_llm_request_duration_seconds",
+		Help:    "Time of chat completion requests to OpenAI, by model and outcome: ok or error.",
+		Buckets: []float64{.25, .5, 1, 2.5, 5, 10, 20, 30, 60},
+	}, []string{"model", "outcome"})
+	llmTokens = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vezhguesi_llm_tokens",
+		Help:    "Tokens per successful chat completion, by model and kind: prompt or completion.",
+		Buckets: []float64{100, 250, 500, 1000, 2000, 4000, 8000, 16000, 32000},
+	}, []string{"model", "kind"})
+)
+
+// observeLLMRequest records a chat completion, its tokens only when it
+// succeeded.
+func observeLLMRequest(model string, took time.Duration, usage openai.Usage, err error) {
+	if err != nil {
+		llmRequestDuration.WithLabelValues(model, "error").Observe(took.Seconds())
+		return
+	}
+	llmRequestDuration.WithLabelValues(model, "ok").Observe(took.Seconds())
+	llmTokens.WithLabelValues(model, "prompt").Observe(float64(usage.PromptTokens))
+	llmTokens.WithLabelValues(model, "completion").Observe(float64(usage.CompletionTokens))
+}
@@ -2,13 +2,15 @@ package reports
 
 import (
 	"time"
+	"vezhguesi/core/audit"
 )
 
 type CreateReportRequest struct {
-	UserID    int       `json:"-"`
-	Subject   string    `json:"subject"`
-	StartDate time.Time `json:"startDate"`
-	EndDate   time.Time `json:"endDate"`
+	UserID    int         `json:"-" validate:"required"`
+	Subject   string      `json:"subject" validate:"required,max=500"`
+	StartDate time.Time   `json:"startDate" validate:"required"`
+	EndDate   time.Time   `json:"endDate" validate:"required,gtefield=StartDate"`
+	Actor     audit.Actor `json:"-"`
 }
 
 type ReportResponse struct {
@@ -27,27 +29,28 @@ type GetReportsRequest struct {
 }
 
 type IDRequest struct {
-	ID     int `json:"-"`
-	UserID int `json:"-"`
+	ID     int `json:"-" validate:"required"`
+	UserID int `json:"-" validate:"required"`
 }
 
 type UpdateReportRequest struct {
-	ID         int           `json:"-"`
-	UserID     int           `json:"-"`
-	Title      string        `json:"title"`
-	Subject    string        `json:"subject"`
-	ReportText string        `json:"reportText"`
+	ID         int            `json:"-" validate:"required"`
+	UserID     int            `json:"-" validate:"required"`
+	Title      string         `json:"title" validate:"max=255"`
+	Subject    string         `json:"subject" validate:"max=500"`
+	ReportText string         `json:"reportText"`
 	Entities   []ReportEntity `json:"entities"`
-	SourceID   int           `json:"sourceId"`
-	Findings   string        `json:"findings"`
-	Sentiment  int           `json:"sentiment"`
-	StartDate  time.Time     `json:"startDate"`
-	EndDate    time.Time     `json:"endDate"`
+	SourceID   int            `json:"sourceId" validate:"min=0"`
+	Findings   string         `json:"findings"`
+	Sentiment  int            `json:"sentiment"`
+	StartDate  time.Time      `json:"startDate"`
+	EndDate    time.Time      `json:"endDate" validate:"omitempty,gtefield=StartDate"`
+	Actor      audit.Actor    `json:"-"`
 }
 
 type ReportEntity struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
+	Name string `json:"name" validate:"max=255"`
+	Type string `json:"type" validate:"max=100"`
 }
 
 type AnalysisEntity struct {
@@ -1,12 +1,19 @@
 package reports
 
-import "github.com/gofiber/fiber/v2"
+import (
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 func RegisterRoutes(router fiber.Router, reportsHttpApi ReportsHTTPTransport, authMiddleware func(c *fiber.Ctx) error) {
+	readScope := middleware.RequireScope(session.ScopeReportsRead)
+
 	reportsRoutes := router.Group("/reports")
 	reportsRoutes.Post("", authMiddleware, reportsHttpApi.Create)
-	reportsRoutes.Get("", authMiddleware, reportsHttpApi.GetReports)
-	reportsRoutes.Get("/my-reports", authMiddleware, reportsHttpApi.GetMyReports)
-	reportsRoutes.Get("/:id", authMiddleware, reportsHttpApi.GetReportByID)
+	reportsRoutes.Get("", readScope, authMiddleware, reportsHttpApi.GetReports)
+	reportsRoutes.Get("/my-reports", readScope, authMiddleware, reportsHttpApi.GetMyReports)
+	reportsRoutes.Get("/:id", readScope, authMiddleware, reportsHttpApi.GetReportByID)
 	reportsRoutes.Put("/:id", authMiddleware, reportsHttpApi.UpdateReport)
 }
@@ -1,6 +1,8 @@
 package reports
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"math"
 	"sort"
@@ -9,37 +11,44 @@ import (
 	articlesvc "vezhguesi/app/articles"
 	"vezhguesi/app/entities"
 	entity_reportsvc "vezhguesi/app/entity_reports"
+	usagesvc "vezhguesi/app/usage"
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
+	"vezhguesi/core/validation"
 	"vezhguesi/helper"
 	server "vezhguesi/sentiment-communication"
 
 	"context"
-	"os"
 
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/sashabaranov/go-openai"
-	"gopkg.in/gomail.v2"
 	"gorm.io/gorm"
 )
 
+// ErrReportNotFound is returned for report IDs that don't exist
+var ErrReportNotFound = apperr.New(apperr.NotFound, "report_not_found", "report does not exist")
+
 type reportsApi struct {
 	db *gorm.DB
-	mailDialer *gomail.Dialer
 	uiAppUrl string
 	logger log.AllLogger
 	entitiesApi entities.EntitiesAPI
 	sentiment server.ServerAPI
+	usageApi usagesvc.UsageAPI
+	openAIKey string
 }
 
 type ReportsAPI interface {
-	Create(req *CreateReportRequest) (res *ReportResponse, err error)
-	GetReports(req *GetReportsRequest) (res *GetReportsResponse, err error)
-	GetReportByID(req *IDRequest) (res *ReportResponse, err error)
-	UpdateReport(req *UpdateReportRequest) (res *ReportResponse, err error)
-	GetMyReports(req *GetReportsRequest) (res *GetMyReportsResponse, err error)
+	Create(ctx context.Context, req *CreateReportRequest) (res *ReportResponse, err error)
+	GetReports(ctx context.Context, req *GetReportsRequest) (res *GetReportsResponse, err error)
+	GetReportByID(ctx context.Context, req *IDRequest) (res *ReportResponse, err error)
+	UpdateReport(ctx context.Context, req *UpdateReportRequest) (res *ReportResponse, err error)
+	GetMyReports(ctx context.Context, req *GetReportsRequest) (res *GetMyReportsResponse, err error)
+	RegenerateEntityReport(ctx context.Context, entityName string) (*EntityReport, error)
 }
 
-func NewReportsAPI(db *gorm.DB, mailDialer *gomail.Dialer, uiAppUrl string, logger log.AllLogger, entitiesApi entities.EntitiesAPI, serverApi server.ServerAPI) ReportsAPI {
-	return &reportsApi{db: db, mailDialer: mailDialer, uiAppUrl: uiAppUrl, logger: logger, entitiesApi: entitiesApi, sentiment: serverApi}
+func NewReportsAPI(db *gorm.DB, uiAppUrl string, logger log.AllLogger, entitiesApi entities.EntitiesAPI, serverApi server.ServerAPI, usageApi usagesvc.UsageAPI, openAIKey string) ReportsAPI {
+	return &reportsApi{db: db, uiAppUrl: uiAppUrl, logger: logger, entitiesApi: entitiesApi, sentiment: serverApi, usageApi: usageApi, openAIKey: openAIKey}
 }
 
 // @Summary      	Create Report
@@ -51,8 +60,10 @@ func NewReportsAPI(db *gorm.DB, mailDialer *gomail.Dialer, uiAppUrl string, logg
 // @Param			CreateReportRequest	body		CreateReportRequest	true	"CreateReportRequest"
 // @Success			200					{object}	ReportResponse
 // @Router			/api/reports/	[POST]
-func (s *reportsApi) Create(req *CreateReportRequest) (res *ReportResponse, err error) {
-	if err := s.validateCreateRequest(req); err != nil {
+func (s *reportsApi) Create(ctx context.Context, req *CreateReportRequest) (res *ReportResponse, err error) {
+	db := s.db.WithContext(ctx)
+
+	if err := validation.Struct(req); err != nil {
 		return nil, err
 	}
 
@@ -60,7 +71,7 @@ func (s *reportsApi) Create(req *CreateReportRequest) (res *ReportResponse, err
 
 	// First try with article_entities join from local database
 	var articles []articlesvc.Article
-	err = s.db.
+	err = db.
 		Joins("JOIN article_entities ON articles.id = article_entities.article_id").
 		Where("article_entities.entity_name IN ?", subjectList).
 		Preload("EntityRelations").
@@ -68,9 +79,9 @@ func (s *reportsApi) Create(req *CreateReportRequest) (res *ReportResponse, err
 
 	// If no articles found in local DB, try fetching from server
 	if err != nil || len(articles) == 0 {
-		serverArticles, err := s.sentiment.FetchArticlesByEntity(subjectList)
+		serverArticles, err := s.sentiment.FetchArticlesByEntity(ctx, subjectList)
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch articles by entity: %v", err)
+			return nil, fmt.Errorf("failed to fetch articles by entity: %w", err)
 		}
 		articles = serverArticles
 
@@ -84,7 +95,7 @@ func (s *reportsApi) Create(req *CreateReportRequest) (res *ReportResponse, err
 					SentimentLabel: "neutral",
 				}
 				
-				if err := s.db.Save(&relation).Error; err != nil {
+				if err := db.Save(&relation).Error; err != nil {
 					s.logger.Errorf("Failed to save article-entity relation: %v", err)
 				}
 			}
@@ -98,8 +109,20 @@ func (s *reportsApi) Create(req *CreateReportRequest) (res *ReportResponse, err
 		EndDate:    req.EndDate,
 	}
 
-	if err := s.db.Create(&report).Error; err != nil {
-		return nil, fmt.Errorf("failed to create report: %v", err)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&report).Error; err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionReportCreated,
+			TargetType: audit.TargetReport,
+			TargetID:   report.ID,
+			After:      reportAuditFields(report),
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Convert to response format
@@ -120,22 +143,6 @@ func (s *reportsApi) Create(req *CreateReportRequest) (res *ReportResponse, err
 	return resp, nil
 }
 
-func (s *reportsApi) validateCreateRequest(req *CreateReportRequest) error {
-	if req.UserID == 0 {
-		return fmt.Errorf("user id is required")
-	}
-	if req.Subject == "" {
-		return fmt.Errorf("subject is required")
-	}
-	if req.StartDate.IsZero() {
-		return fmt.Errorf("start date is required")
-	}
-	if req.EndDate.IsZero() {
-		return fmt.Errorf("end date is required")
-	}
-	return nil
-}
-
 // @Summary      	Get Reports
 // @Description	Validates user id. Gets all reports
 // @Tags			Reports
@@ -145,11 +152,11 @@ func (s *reportsApi) validateCreateRequest(req *CreateReportRequest) error {
 // @Param			terms			query		string	true	"terms"
 // @Success			200					{object}	GetReportsResponse
 // @Router			/api/reports/	[GET]
-func (s *reportsApi) GetReports(req *GetReportsRequest) (res *GetReportsResponse, err error) {
+func (s *reportsApi) GetReports(ctx context.Context, req *GetReportsRequest) (res *GetReportsResponse, err error) {
 	// Call the GetAnalyzes function
-	analyzeResponse, err := s.sentiment.GetAnalyzes(req.Terms)
+	analyzeResponse, err := s.sentiment.GetAnalyzes(ctx, usagesvc.Payer{UserID: req.UserID}, req.Terms)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch analyzed reports: %v", err)
+		return nil, fmt.Errorf("failed to fetch analyzed reports: %w", err)
 	}
 
 	// Transform the analyzeResponse into GetReportsResponse
@@ -202,19 +209,18 @@ func (s *reportsApi) GetReports(req *GetReportsRequest) (res *GetReportsResponse
 // @Param			id				path		int		true	"Report ID"
 // @Success			200					{object}	ReportResponse
 // @Router			/api/reports/{id}	[GET]
-func (s *reportsApi) GetReportByID(req *IDRequest) (res *ReportResponse, err error) {
-	if req.UserID == 0 {
-		return nil, fmt.Errorf("user id is required")
-	}
-
-	if req.ID == 0 {
-		return nil, fmt.Errorf("id is required")
+func (s *reportsApi) GetReportByID(ctx context.Context, req *IDRequest) (res *ReportResponse, err error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
 	}
 
 	var report Report
-	result := s.db.Where("id = ?", req.ID).First(&report)
+	result := s.db.WithContext(ctx).Where("id = ?", req.ID).First(&report)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrReportNotFound
+	}
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, fmt.Errorf("failed to fetch report: %w", result.Error)
 	}
 
 	resp := &ReportResponse{
@@ -235,21 +241,23 @@ func (s *reportsApi) GetReportByID(req *IDRequest) (res *ReportResponse, err err
 // @Param			UpdateReportRequest	body		UpdateReportRequest	true	"UpdateReportRequest"
 // @Success			200					{object}	ReportResponse
 // @Router			/api/reports/{id}	[PUT]
-func (s *reportsApi) UpdateReport(req *UpdateReportRequest) (res *ReportResponse, err error) {
-	if req.UserID == 0 {
-		return nil, fmt.Errorf("user id is required")
-	}
+func (s *reportsApi) UpdateReport(ctx context.Context, req *UpdateReportRequest) (res *ReportResponse, err error) {
+	db := s.db.WithContext(ctx)
 
-	if req.ID == 0 {
-		return nil, fmt.Errorf("id is required")
+	if err := validation.Struct(req); err != nil {
+		return nil, err
 	}
 
 	var report Report 
 
-	result := s.db.Where("id = ?", req.ID).First(&report)
+	result := db.Where("id = ?", req.ID).First(&report)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrReportNotFound
+	}
 	if result.Error != nil {
-		return nil, fmt.Errorf("report does not exist")
+		return nil, fmt.Errorf("failed to fetch report: %w", result.Error)
 	}
+	before := reportAuditFields(&report)
 
 	if req.Title != "" {
 		report.Title = req.Title
@@ -278,19 +286,20 @@ func (s *reportsApi) UpdateReport(req *UpdateReportRequest) (res *ReportResponse
 				Name: entity.Name,
 			}
 			
-			resp, err := s.entitiesApi.GetEntity(&requestForEntity)
+			resp, err := s.entitiesApi.GetEntity(ctx, &requestForEntity)
 			if err != nil {
-				 fmt.Println("Entity not found")
+				 s.logger.Debugf("func: UpdateReport, operation: s.entitiesApi.GetEntity, entity: %s, err: %s", entity.Name, err.Error())
 			}
 			if entity.Name != "" {
 				newEntity := entities.CreateEntityRequest{
 					Name: entity.Name,
 					Type: entity.Type,
+					Actor: req.Actor,
 				}
 
-				resp, err := s.entitiesApi.Create(&newEntity)
+				resp, err := s.entitiesApi.Create(ctx, &newEntity)
 				if err != nil {
-					return nil, fmt.Errorf("error creating entity: %v", err)
+					return nil, err
 				}
 				createdEntity := entities.Entity{
 					ID: resp.ID,
@@ -311,9 +320,21 @@ func (s *reportsApi) UpdateReport(req *UpdateReportRequest) (res *ReportResponse
 
 	report.Sentiment = req.Sentiment
 
-	result = s.db.Save(&report)
-	if result.Error != nil {
-		return nil, fmt.Errorf("error updating report: %v", result.Error)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&report).Error; err != nil {
+			return fmt.Errorf("error updating report: %w", err)
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionReportUpdated,
+			TargetType: audit.TargetReport,
+			TargetID:   report.ID,
+			Before:     before,
+			After:      reportAuditFields(&report),
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	resp := ReportResponse{
@@ -332,22 +353,23 @@ func (s *reportsApi) UpdateReport(req *UpdateReportRequest) (res *ReportResponse
 // @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
 // @Success			200					{object}	GetMyReportsResponse
 // @Router			/api/reports/my-reports	[GET]
-func (s *reportsApi) GetMyReports(req *GetReportsRequest) (res *GetMyReportsResponse, err error) {
+func (s *reportsApi) GetMyReports(ctx context.Context, req *GetReportsRequest) (res *GetMyReportsResponse, err error) {
+	logger := s.logger.WithContext(ctx)
 	// Log the request
-	s.logger.Infof("Getting reports for user ID: %d", req.UserID)
+	logger.Infof("Getting reports for user ID: %d", req.UserID)
 
 	var reports []Report
-	result := s.db.Where("user_id = ?", req.UserID).
+	result := s.db.WithContext(ctx).Where("user_id = ?", req.UserID).
 		Order("id DESC").
 		Find(&reports)
 	if result.Error != nil {
-		return nil, fmt.Errorf("error fetching reports: %v", result.Error)
+		return nil, fmt.Errorf("error fetching reports: %w", result.Error)
 	}
 
 	// Log the found reports
-	s.logger.Infof("Found %d reports", len(reports))
+	logger.Infof("Found %d reports", len(reports))
 	for _, r := range reports {
-		s.logger.Infof("Report subject: %s", r.Subject)
+		logger.Debugf("Report subject: %s", r.Subject)
 	}
 
 	var terms []string
@@ -356,15 +378,15 @@ func (s *reportsApi) GetMyReports(req *GetReportsRequest) (res *GetMyReportsResp
 	}
 
 	// Log the terms we're searching for
-	s.logger.Infof("Searching for terms: %v", terms)
+	logger.Infof("Searching for terms: %v", terms)
 
-	response, err := s.sentiment.GetAnalyzes(terms)
+	response, err := s.sentiment.GetAnalyzes(ctx, usagesvc.Payer{UserID: req.UserID}, terms)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch analyzed reports: %v", err)
+		return nil, fmt.Errorf("failed to fetch analyzed reports: %w", err)
 	}
 
 	// Log the analysis response
-	s.logger.Infof("Got analysis response with %d articles", len(response.Results.Articles))
+	logger.Infof("Got analysis response with %d articles", len(response.Results.Articles))
 
 	// Create a map of entities from reports for quick lookup
 	requestedEntities := make(map[string]bool)
@@ -373,7 +395,7 @@ func (s *reportsApi) GetMyReports(req *GetReportsRequest) (res *GetMyReportsResp
 		entities := strings.Split(report.Subject, ",")
 		for _, entity := range entities {
 			entityName := strings.TrimSpace(entity)
-			s.logger.Infof("Processing requested entity: %s", entityName)
+			logger.Debugf("Processing requested entity: %s", entityName)
 			requestedEntities[strings.ToLower(entityName)] = true
 			requestedEntityNames = append(requestedEntityNames, entityName)
 		}
@@ -394,7 +416,7 @@ func (s *reportsApi) GetMyReports(req *GetReportsRequest) (res *GetMyReportsResp
 		analysis := createAnalysisFromArticle(article)
 
 		for entityName, entity := range article.Entities {
-			s.logger.Infof("Checking entity: %s", entityName)
+			logger.Debugf("Checking entity: %s", entityName)
 			
 			// Check if this entity matches any of the requested entities
 			var matchedRequestedEntity string
@@ -440,9 +462,9 @@ func (s *reportsApi) GetMyReports(req *GetReportsRequest) (res *GetMyReportsResp
 	}
 
 	// Log the results before returning
-	s.logger.Infof("Found %d matching entities", len(entityMap))
+	logger.Infof("Found %d matching entities", len(entityMap))
 	for entityName := range entityMap {
-		s.logger.Infof("Matched entity: %s", entityName)
+		logger.Debugf("Matched entity: %s", entityName)
 	}
 
 	var entitiesReportsResponse []EntityReport
@@ -450,20 +472,20 @@ func (s *reportsApi) GetMyReports(req *GetReportsRequest) (res *GetMyReportsResp
 	// Before processing entities, ensure they exist in the database
 	for _, fullName := range entityFullNames {
 		var existingEntity entities.Entity
-		if err := s.db.Where("name = ?", fullName).First(&existingEntity).Error; err != nil {
+		if err := s.db.WithContext(ctx).Where("name = ?", fullName).First(&existingEntity).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				// Create the entity if it doesn't exist
 				newEntity := entities.CreateEntityRequest{
 					Name: fullName,
 					Type: "PERSON", // or appropriate type
 				}
-				_, err = s.entitiesApi.Create(&newEntity)
+				_, err = s.entitiesApi.Create(ctx, &newEntity)
 				if err != nil {
-					s.logger.Errorf("Failed to create entity %s: %v", fullName, err)
+					logger.Errorf("Failed to create entity %s: %v", fullName, err)
 					continue
 				}
 			} else {
-				s.logger.Errorf("Error checking entity %s: %v", fullName, err)
+				logger.Errorf("Error checking entity %s: %v", fullName, err)
 				continue
 			}
 		}
@@ -491,9 +513,12 @@ func (s *reportsApi) GetMyReports(req *GetReportsRequest) (res *GetMyReportsResp
 		}
 
 		// Generate entity summary
-		entityReport, err := s.GenerateEntityReport(articles, entityKey, req.UserID)
+		entityReport, err := s.GenerateEntityReport(ctx, articles, entityKey, req.UserID)
+		if errors.Is(err, usagesvc.ErrQuotaExceeded) {
+			return nil, err
+		}
 		if err != nil {
-			s.logger.Errorf("Failed to generate summary for entity %s: %v", entityKey, err)
+			logger.Errorf("Failed to generate summary for entity %s: %v", entityKey, err)
 			continue
 		}
 
@@ -595,18 +620,52 @@ func createAnalysisFromArticle(article server.ArticleData) Analysis {
 	}
 }
 
-func (s *reportsApi) GenerateEntityReport(articles []server.ArticleData, entityName string, userID int) (*EntityReport, error) {
-    // Get the full entity name from database
+func (s *reportsApi) GenerateEntityReport(ctx context.Context, articles []server.ArticleData, entityName string, userID int) (*EntityReport, error) {
+    entity, err := s.findEntity(ctx, entityName)
+    if err != nil {
+        return nil, err
+    }
+
+    return s.generateEntityReport(ctx, articles, entity, usagesvc.Payer{UserID: userID}, true)
+}
+
+// RegenerateEntityReport generates a new report for the entity from its
+// current analyses, even when a recent one exists. The users of its earlier
+// reports are linked to the new one.
+func (s *reportsApi) RegenerateEntityReport(ctx context.Context, entityName string) (*EntityReport, error) {
+    entity, err := s.findEntity(ctx, entityName)
+    if err != nil {
+        return nil, err
+    }
+
+    response, err := s.sentiment.GetAnalyzes(ctx, usagesvc.JobPayer, []string{entity.Name})
+    if err != nil {
+        return nil, err
+    }
+
+    return s.generateEntityReport(ctx, response.Results.Articles, entity, usagesvc.JobPayer, false)
+}
+
+// findEntity returns the entity with the given name, ignoring case.
+func (s *reportsApi) findEntity(ctx context.Context, name string) (entities.Entity, error) {
     var entity entities.Entity
-    if err := s.db.Where("name ILIKE ?", "%"+entityName+"%").First(&entity).Error; err != nil {
-        return nil, fmt.Errorf("entity not found: %v", err)
+    if err := s.db.WithContext(ctx).Where("LOWER(name) = LOWER(?)", strings.TrimSpace(name)).First(&entity).Error; err != nil {
+        return entity, apperr.Wrap(err, apperr.NotFound, "entity_not_found", "entity not found")
     }
+    return entity, nil
+}
+
+// generateEntityReport summarizes the articles mentioning the entity, billing
+// the summary to payer. With reuseRecent a report less than a day old is
+// returned instead of a new one.
+func (s *reportsApi) generateEntityReport(ctx context.Context, articles []server.ArticleData, entity entities.Entity, payer usagesvc.Payer, reuseRecent bool) (*EntityReport, error) {
+    db := s.db.WithContext(ctx)
 
     // Get article IDs and convert server.ArticleData to []articles.Article
     var articleIDs []int
     var relevantArticles []string
     for _, article := range articles {
-        if _, exists := article.Entities[entityName]; exists {
+        if _, exists := article.Entities[entity.Name]; exists {
             articleIDs = append(articleIDs, article.ArticleID)
             relevantArticles = append(relevantArticles, article.URL)
         }
@@ -617,15 +676,15 @@ func (s *reportsApi) GenerateEntityReport(articles []server.ArticleData, entityN
 
     // Check if we have a recent entity report with the same articles
     var existingReport entity_reportsvc.EntityReport
-    err := s.db.Preload("Articles").
+    err := db.Preload("Articles").
         Where("entity_reports.entity_id = ?", entity.ID).
         Where("last_analyzed > ?", time.Now().Add(-24*time.Hour)).
         First(&existingReport).Error
 
     // If we found a recent report (less than 24 hours old)
-    if err == nil {
+    if err == nil && reuseRecent {
         // Associate report with current user if not already associated
-        s.associateReportWithUser(existingReport.ID, userID)
+        s.associateReportWithUser(db, existingReport.ID, payer.UserID)
 
         return &EntityReport{
             EntityName:    entity.Name,
@@ -646,11 +705,11 @@ func (s *reportsApi) GenerateEntityReport(articles []server.ArticleData, entityN
     }
 
     if len(summaries) == 0 {
-        return nil, fmt.Errorf("no summaries found for entity %s", entity.Name)
+        return nil, apperr.Newf(apperr.NotFound, "no_entity_summaries", "no summaries found for entity %s", entity.Name)
     }
 
     // Generate new summary using OpenAI
-    summary, err := s.generateOpenAISummary(summaries, entity.Name)
+    summary, err := s.generateOpenAISummary(ctx, summaries, entity.Name, payer)
     if err != nil {
         return nil, err
     }
@@ -664,10 +723,10 @@ func (s *reportsApi) GenerateEntityReport(articles []server.ArticleData, entityN
     }
 
     // Start a transaction
-    tx := s.db.Begin()
+    tx := db.Begin()
     if err := tx.Create(&newReport).Error; err != nil {
         tx.Rollback()
-        return nil, fmt.Errorf("failed to create entity report: %v", err)
+        return nil, fmt.Errorf("failed to create entity report: %w", err)
     }
 
     // Associate articles with the report
@@ -677,21 +736,37 @@ func (s *reportsApi) GenerateEntityReport(articles []server.ArticleData, entityN
             ArticleID:      articleID,
         }).Error; err != nil {
             tx.Rollback()
-            return nil, fmt.Errorf("failed to associate article: %v", err)
+            return nil, fmt.Errorf("failed to associate article: %w", err)
         }
     }
 
     // Associate with current user
-    if err := tx.Create(&entity_reportsvc.UserEntityReport{
-        EntityReportID: newReport.ID,
-        UserID:         userID,
-    }).Error; err != nil {
-        tx.Rollback()
-        return nil, fmt.Errorf("failed to associate user: %v", err)
+    if !payer.Job {
+        if err := tx.Create(&entity_reportsvc.UserEntityReport{
+            EntityReportID: newReport.ID,
+            UserID:         payer.UserID,
+        }).Error; err != nil {
+            tx.Rollback()
+            return nil, fmt.Errorf("failed to associate user: %w", err)
+        }
+    }
+
+    // A regenerated report replaces the earlier ones for their users
+    if !reuseRecent {
+        err := tx.Exec(`INSERT INTO user_entity_reports (user_id, entity_report_id, created_at)
+            SELECT DISTINCT user_entity_reports.user_id, ?, NOW()
+            FROM user_entity_reports
+            JOIN entity_reports ON entity_reports.id = user_entity_reports.entity_report_id
+            WHERE entity_reports.entity_id = ? AND entity_reports.id <> ?
+            ON CONFLICT DO NOTHING`, newReport.ID, entity.ID, newReport.ID).Error
+        if err != nil {
+            tx.Rollback()
+            return nil, fmt.Errorf("failed to associate users: %w", err)
+        }
     }
 
     if err := tx.Commit().Error; err != nil {
-        return nil, fmt.Errorf("failed to commit transaction: %v", err)
+        return nil, fmt.Errorf("failed to commit transaction: %w", err)
     }
 
     return &EntityReport{
@@ -703,7 +778,12 @@ func (s *reportsApi) GenerateEntityReport(articles []server.ArticleData, entityN
 }
 
 // Helper function to generate summary using OpenAI
-func (s *reportsApi) generateOpenAISummary(summaries []string, entityName string) (string, error) {
+func (s *reportsApi) generateOpenAISummary(ctx context.Context, summaries []string, entityName string, payer usagesvc.Payer) (string, error) {
+    logger := s.logger.WithContext(ctx)
+    if err := s.usageApi.CheckQuota(ctx, &usagesvc.CheckQuotaRequest{Payer: payer, Kind: usagesvc.KindLLMCompletion}); err != nil {
+        return "", err
+    }
+
     prompt := fmt.Sprintf(`Bazuar në këto %d përmbledhje artikujsh për %s, krijoni një raport të shkurtër dhe të qartë.
 
     Përmbledhjet e artikujve:
@@ -722,11 +802,14 @@ func (s *reportsApi) generateOpenAISummary(summaries []string, entityName string
     E rëndësishme: Përdorni pika të shkurtra dhe mos përdorni asnjë formatim të veçantë.`, 
     len(summaries), entityName, strings.Join(summaries, "\n\n"))
 
-    client := openai.NewClient(os.Getenv("OPENAI_API_KEY"))
+    model := "gpt-4o-mini"
+    client := openai.NewClient(s.openAIKey)
+    ctx, span := startLLMSpan(ctx, model)
+    start := time.Now()
     resp, err := client.CreateChatCompletion(
-        context.Background(),
+        ctx,
         openai.ChatCompletionRequest{
-            Model: "gpt-4o-mini",
+            Model: model,
             Messages: []openai.ChatCompletionMessage{
                 {
                     Role:    "user",
@@ -737,13 +820,24 @@ func (s *reportsApi) generateOpenAISummary(summaries []string, entityName string
             Temperature: 0.2,
         },
     )
+    observeLLMRequest(model, time.Since(start), resp.Usage, err)
+    endLLMSpan(span, resp.Usage, err)
 
     if err != nil {
-        return "", fmt.Errorf("failed to generate report: %w", err)
+        return "", apperr.Wrap(err, apperr.Unavailable, "llm_unavailable", "failed to generate report")
+    }
+
+    if err := s.usageApi.RecordLLMUsage(ctx, &usagesvc.RecordLLMUsageRequest{
+        UserID:           payer.UserID,
+        Model:            model,
+        PromptTokens:     resp.Usage.PromptTokens,
+        CompletionTokens: resp.Usage.CompletionTokens,
+    }); err != nil {
+        logger.Errorf("Failed to record LLM usage: %v", err)
     }
 
     if len(resp.Choices) == 0 {
-        return "", fmt.Errorf("no response generated from OpenAI")
+        return "", apperr.New(apperr.Unavailable, "llm_unavailable", "no response generated from OpenAI")
     }
 
     // Clean up the response
@@ -763,15 +857,15 @@ func (s *reportsApi) generateOpenAISummary(summaries []string, entityName string
 }
 
 // Helper function to associate report with user
-func (s *reportsApi) associateReportWithUser(reportID uint, userID int) error {
+func (s *reportsApi) associateReportWithUser(db *gorm.DB, reportID uint, userID int) error {
     // Check if association already exists
     var existing entity_reportsvc.UserEntityReport
-    err := s.db.Where("entity_report_id = ? AND user_id = ?", reportID, userID).
+    err := db.Where("entity_report_id = ? AND user_id = ?", reportID, userID).
         First(&existing).Error
 
     if err == gorm.ErrRecordNotFound {
         // Create new association
-        return s.db.Create(&entity_reportsvc.UserEntityReport{
+        return db.Create(&entity_reportsvc.UserEntityReport{
             EntityReportID: reportID,
             UserID:         userID,
         }).Error
@@ -820,3 +914,26 @@ func mapToSlice(entityMap map[string]Entity) []Entity {
     }
     return entities
 }
+
+// reportAuditFields are the report fields tracked in the audit log. The text
+// is kept as its hash and length, enough to tell that it changed without
+// copying every version of it into the log.
+func reportAuditFields(report *Report) map[string]interface{} {
+	entityNames := make([]string, 0, len(report.Entities))
+	for _, entity := range report.Entities {
+		entityNames = append(entityNames, entity.Name)
+	}
+
+	return map[string]interface{}{
+		"title":            report.Title,
+		"subject":          report.Subject,
+		"reportTextSha256": fmt.Sprintf("%x", sha256.Sum256([]byte(report.ReportText))),
+		"reportTextLength": len(report.ReportText),
+		"entities":         entityNames,
+		"sourceId":         report.SourceID,
+		"findings":         report.Findings,
+		"sentiment":        report.Sentiment,
+		"startDate":        report.StartDate,
+		"endDate":          report.EndDate,
+	}
+}
@@ -0,0 +1,36 @@
+package reports
+
+import (
+	"context"
+
+	"vezhguesi/core/tracing"
+
+	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startLLMSpan starts the span of a chat completion with model, following
+// the OpenTelemetry conventions for generative AI.
+func startLLMSpan(ctx context.Context, model string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "chat "+model,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("gen_ai.system", "openai"),
+			attribute.String("gen_ai.operation.name", "chat"),
+			attribute.String("gen_ai.request.model", model),
+		),
+	)
+}
+
+// endLLMSpan records the token usage of a successful completion and ends
+// span.
+func endLLMSpan(span trace.Span, usage openai.Usage, err error) {
+	if err == nil {
+		span.SetAttributes(
+			attribute.Int("gen_ai.usage.input_tokens", usage.PromptTokens),
+			attribute.Int("gen_ai.usage.output_tokens", usage.CompletionTokens),
+		)
+	}
+	tracing.End(span, err)
+}
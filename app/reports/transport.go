@@ -1,9 +1,10 @@
 package reports
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
 	"vezhguesi/core/middleware"
 	"vezhguesi/helper"
 
@@ -36,10 +37,11 @@ func (s *reportsHttpTransport) Create(c *fiber.Ctx) error {
 	req.UserID = userId
 
 	if err := c.BodyParser(req); err != nil {
-		return helper.HTTPError(c, err, "CreateReport.c.BodyParser")
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "CreateReport.c.BodyParser")
 	}
+	req.Actor = audit.ActorFrom(c)
 
-	resp, err := s.reportsAPI.Create(req)
+	resp, err := s.reportsAPI.Create(c.UserContext(), req)
 	if err != nil {
 		return helper.HTTPError(c, err, "CreateReport.reportsAPI.Create")
 	}
@@ -55,11 +57,9 @@ func (s *reportsHttpTransport) GetReports(c *fiber.Ctx) error {
 	if err != nil {
 		return helper.HTTPError(c, err, "GetReports.middleware.CtxUserID")
 	}
-	fmt.Println("terms inside reports transport", terms)
-	fmt.Println("termsArray inside reports transport", termsArray)
 	req.UserID = userId
 	req.Terms = termsArray
-	resp, err := s.reportsAPI.GetReports(req)
+	resp, err := s.reportsAPI.GetReports(c.UserContext(), req)
 	if err != nil {
 		return helper.HTTPError(c, err, "GetReports.reportsAPI.GetReports")
 	}
@@ -76,7 +76,7 @@ func (s *reportsHttpTransport) GetReportByID(c *fiber.Ctx) error {
 	req.UserID = userId
 	reportIdStr := c.Params("id")
 	if reportIdStr == "" {
-		return helper.HTTPError(c, fmt.Errorf("id is required"), "GetReportByID.c.Params")
+		return helper.HTTPError(c, apperr.New(apperr.Invalid, "missing_id", "id is required"), "GetReportByID.c.Params")
 	}
 	reportId, err := strconv.Atoi(reportIdStr)
 	if err != nil {
@@ -84,7 +84,7 @@ func (s *reportsHttpTransport) GetReportByID(c *fiber.Ctx) error {
 	}
 	req.ID = reportId
 
-	resp, err := s.reportsAPI.GetReportByID(req)
+	resp, err := s.reportsAPI.GetReportByID(c.UserContext(), req)
 	if err != nil {
 		return helper.HTTPError(c, err, "GetReportByID.reportsAPI.GetReportByID")
 	}
@@ -101,7 +101,7 @@ func (s *reportsHttpTransport) UpdateReport(c *fiber.Ctx) error {
 	req.UserID = userId
 	reportIdStr := c.Params("id")
 	if reportIdStr == "" {
-		return helper.HTTPError(c, fmt.Errorf("id is required"), "UpdateReport.c.Params")
+		return helper.HTTPError(c, apperr.New(apperr.Invalid, "missing_id", "id is required"), "UpdateReport.c.Params")
 	}
 	reportId, err := strconv.Atoi(reportIdStr)
 	if err != nil {
@@ -110,10 +110,11 @@ func (s *reportsHttpTransport) UpdateReport(c *fiber.Ctx) error {
 	req.ID = reportId
 
 	if err := c.BodyParser(req); err != nil {
-		return helper.HTTPError(c, err, "UpdateReport.c.BodyParser")
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "UpdateReport.c.BodyParser")
 	}
+	req.Actor = audit.ActorFrom(c)
 
-	resp, err := s.reportsAPI.UpdateReport(req)
+	resp, err := s.reportsAPI.UpdateReport(c.UserContext(), req)
 	if err != nil {
 		return helper.HTTPError(c, err, "UpdateReport.reportsAPI.UpdateReport")
 	}
@@ -130,7 +131,7 @@ func (s *reportsHttpTransport) GetMyReports(c *fiber.Ctx) error {
 	}
 	req.UserID = userId
 
-	resp, err := s.reportsAPI.GetMyReports(req)
+	resp, err := s.reportsAPI.GetMyReports(c.UserContext(), req)
 	if err != nil {
 		return helper.HTTPError(c, err, "GetMyReports.reportsAPI.GetMyReports")
 	}
@@ -0,0 +1,56 @@
+package reports_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"vezhguesi/app/reports"
+	"vezhguesi/core/apperr"
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/db/dbtest"
+	"vezhguesi/core/logging"
+	"vezhguesi/core/middleware"
+	"vezhguesi/core/users"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestGetReportByIDNotFound(t *testing.T) {
+	conn := dbtest.Open(t)
+
+	user := users.User{
+		Email:         fmt.Sprintf("reports-%d@example.com", time.Now().UnixNano()),
+		Role:          "user",
+		Active:        true,
+		VerifiedEmail: true,
+	}
+	if err := conn.Omit("UpdatedAt").Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Where("user_id = ?", user.ID).Delete(&session.Session{})
+		conn.Delete(&users.User{}, user.ID)
+	})
+
+	tokens, err := session.NewTokenIssuer(conn, dbtest.SecretKey).StartSession(user.ID, session.DeviceInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: apperr.Handler(logging.Logger("http"))})
+	api := reports.NewReportsAPI(conn, "", logging.Logger("reports"), nil, nil, nil, "")
+	reports.RegisterRoutes(app, reports.NewReportsHTTPTransport(api), middleware.Authentication(conn, dbtest.SecretKey))
+
+	req := httptest.NewRequest(http.MethodGet, "/reports/2147483647", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
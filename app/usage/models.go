@@ -0,0 +1,62 @@
+package usage
+
+type RecordLLMUsageRequest struct {
+	UserID           int
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+type RecordAnalysisUsageRequest struct {
+	UserID           int
+	ArticlesAnalyzed int
+}
+
+// Payer is whom usage is checked against: a user, or a job run by the
+// scheduler or from the command line, which has no user and no quota.
+type Payer struct {
+	UserID int
+	Job    bool
+}
+
+// JobPayer pays for the work of jobs.
+var JobPayer = Payer{Job: true}
+
+type CheckQuotaRequest struct {
+	Payer
+	Kind string
+}
+
+type GetUsageRequest struct {
+	UserID int    `json:"-"`
+	From   string `query:"from"`
+	To     string `query:"to"`
+}
+
+type DailyUsage struct {
+	Day              string `json:"day"`
+	OrgID            *int   `json:"orgId"`
+	LLMCalls         int    `json:"llmCalls"`
+	PromptTokens     int    `json:"promptTokens"`
+	CompletionTokens int    `json:"completionTokens"`
+	TotalTokens      int    `json:"totalTokens"`
+	AnalysisCalls    int    `json:"analysisCalls"`
+	ArticlesAnalyzed int    `json:"articlesAnalyzed"`
+}
+
+type GetUsageResponse struct {
+	OrgID *int         `json:"orgId"`
+	Days  []DailyUsage `json:"days"`
+}
+
+type QuotaRequest struct {
+	UserID int `json:"-"`
+}
+
+type QuotaResponse struct {
+	OrgID              *int `json:"orgId"`
+	DailyTokenLimit    int  `json:"dailyTokenLimit"`
+	DailyTokensUsed    int  `json:"dailyTokensUsed"`
+	DailyAnalysisLimit int  `json:"dailyAnalysisLimit"`
+	DailyAnalysisUsed  int  `json:"dailyAnalysisUsed"`
+}
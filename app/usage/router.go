@@ -0,0 +1,9 @@
+package usage
+
+import "github.com/gofiber/fiber/v2"
+
+func RegisterRoutes(router fiber.Router, usageHttpApi UsageHTTPTransport, authMiddleware func(c *fiber.Ctx) error) {
+	usageRoutes := router.Group("/usage")
+	usageRoutes.Get("", authMiddleware, usageHttpApi.GetUsage)
+	usageRoutes.Get("/quota", authMiddleware, usageHttpApi.GetQuota)
+}
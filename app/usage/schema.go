@@ -0,0 +1,23 @@
+package usage
+
+import "time"
+
+const UsageEventTableName = "usage_events"
+
+const (
+	// Billable event kinds
+	KindLLMCompletion = "llm_completion"
+	KindAnalysisCall  = "analysis_call"
+)
+
+type UsageEvent struct {
+	ID               uint   `gorm:"primaryKey"`
+	OrgID            *int   `gorm:"index:idx_usage_org_created"`
+	UserID           int    `gorm:"index"`
+	Kind             string `gorm:"not null"`
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	ArticlesAnalyzed int
+	CreatedAt        time.Time `gorm:"index:idx_usage_org_created"`
+}
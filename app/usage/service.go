@@ -0,0 +1,276 @@
+package usage
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"vezhguesi/core/apperr"
+
+	"github.com/gofiber/fiber/v2/log"
+	"gorm.io/gorm"
+)
+
+const (
+	// Subscription feature keys holding the daily quotas
+	DailyTokenLimitFeature    = "DailyTokenLimit"
+	DailyAnalysisLimitFeature = "DailyAnalysisLimit"
+
+	// Quotas applied when the user has no org or the subscription does not define them
+	DefaultDailyTokenLimit    = 50000
+	DefaultDailyAnalysisLimit = 100
+)
+
+var (
+	ErrQuotaExceeded = apperr.New(apperr.TooManyRequests, "quota_exceeded", "usage quota exceeded")
+	ErrNoPayer       = apperr.New(apperr.Internal, "usage_without_payer", "usage has neither a user nor a job to check the quota of")
+)
+
+type usageApi struct {
+	db     *gorm.DB
+	logger log.AllLogger
+}
+
+type UsageAPI interface {
+	RecordLLMUsage(ctx context.Context, req *RecordLLMUsageRequest) error
+	RecordAnalysisUsage(ctx context.Context, req *RecordAnalysisUsageRequest) error
+	CheckQuota(ctx context.Context, req *CheckQuotaRequest) error
+	GetUsage(ctx context.Context, req *GetUsageRequest) (res *GetUsageResponse, err error)
+	GetQuota(ctx context.Context, req *QuotaRequest) (res *QuotaResponse, err error)
+}
+
+func NewUsageAPI(db *gorm.DB, logger log.AllLogger) UsageAPI {
+	return &usageApi{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (s *usageApi) RecordLLMUsage(ctx context.Context, req *RecordLLMUsageRequest) error {
+	event := UsageEvent{
+		OrgID:            s.orgIDForUser(ctx, req.UserID),
+		UserID:           req.UserID,
+		Kind:             KindLLMCompletion,
+		Model:            req.Model,
+		PromptTokens:     req.PromptTokens,
+		CompletionTokens: req.CompletionTokens,
+	}
+	if err := s.db.WithContext(ctx).Create(&event).Error; err != nil {
+		s.logger.Errorf("func: RecordLLMUsage, operation: s.db.Create(&event), err: %s", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (s *usageApi) RecordAnalysisUsage(ctx context.Context, req *RecordAnalysisUsageRequest) error {
+	event := UsageEvent{
+		OrgID:            s.orgIDForUser(ctx, req.UserID),
+		UserID:           req.UserID,
+		Kind:             KindAnalysisCall,
+		ArticlesAnalyzed: req.ArticlesAnalyzed,
+	}
+	if err := s.db.WithContext(ctx).Create(&event).Error; err != nil {
+		s.logger.Errorf("func: RecordAnalysisUsage, operation: s.db.Create(&event), err: %s", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// CheckQuota returns ErrQuotaExceeded when today's usage of the given kind
+// has reached the limit of the user's org subscription. Jobs are not
+// limited; usage with neither a user nor a job is refused with ErrNoPayer.
+func (s *usageApi) CheckQuota(ctx context.Context, req *CheckQuotaRequest) error {
+	if req.Job {
+		return nil
+	}
+	if req.UserID == 0 {
+		return ErrNoPayer
+	}
+
+	quota, err := s.GetQuota(ctx, &QuotaRequest{UserID: req.UserID})
+	if err != nil {
+		return err
+	}
+
+	switch req.Kind {
+	case KindLLMCompletion:
+		if quota.DailyTokensUsed >= quota.DailyTokenLimit {
+			return ErrQuotaExceeded
+		}
+	case KindAnalysisCall:
+		if quota.DailyAnalysisUsed >= quota.DailyAnalysisLimit {
+			return ErrQuotaExceeded
+		}
+	default:
+		return apperr.Newf(apperr.Invalid, "invalid_usage_kind", "invalid usage kind %q", req.Kind)
+	}
+
+	return nil
+}
+
+// @Summary      	Get Usage
+// @Description		Returns billable usage of the user's org aggregated per UTC day, from and to included. Defaults to the last 30 days.
+// @Tags			Usage
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			from			query		string	false	"From (YYYY-MM-DD or RFC3339)"
+// @Param			to				query		string	false	"To (YYYY-MM-DD or RFC3339)"
+// @Success			200					{object}	GetUsageResponse
+// @Router			/api/usage	[GET]
+func (s *usageApi) GetUsage(ctx context.Context, req *GetUsageRequest) (res *GetUsageResponse, err error) {
+	if req.UserID == 0 {
+		return nil, apperr.New(apperr.Invalid, "missing_user_id", "user id is required")
+	}
+	to := utcDay(time.Now())
+	if req.To != "" {
+		if to, err = parseDate(req.To); err != nil {
+			return nil, apperr.New(apperr.Invalid, "invalid_date", "invalid to date")
+		}
+		to = utcDay(to)
+	}
+	from := to.AddDate(0, 0, -29)
+	if req.From != "" {
+		if from, err = parseDate(req.From); err != nil {
+			return nil, apperr.New(apperr.Invalid, "invalid_date", "invalid from date")
+		}
+		from = utcDay(from)
+	}
+	if from.After(to) {
+		return nil, apperr.New(apperr.Invalid, "invalid_date_range", "invalid date range")
+	}
+
+	orgID := s.orgIDForUser(ctx, req.UserID)
+
+	days := make([]DailyUsage, 0)
+	result := s.scope(ctx, orgID, req.UserID).
+		Model(&UsageEvent{}).
+		Select(`to_char(date_trunc('day', created_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD') AS day,
+			SUM(CASE WHEN kind = ? THEN 1 ELSE 0 END) AS llm_calls,
+			SUM(prompt_tokens) AS prompt_tokens,
+			SUM(completion_tokens) AS completion_tokens,
+			SUM(prompt_tokens + completion_tokens) AS total_tokens,
+			SUM(CASE WHEN kind = ? THEN 1 ELSE 0 END) AS analysis_calls,
+			SUM(articles_analyzed) AS articles_analyzed`, KindLLMCompletion, KindAnalysisCall).
+		Where("created_at >= ? AND created_at < ?", from, to.AddDate(0, 0, 1)).
+		Group("day").
+		Order("day").
+		Scan(&days)
+	if result.Error != nil {
+		s.logger.Errorf("func: GetUsage, operation: Scan(&days), err: %s", result.Error.Error())
+		return nil, result.Error
+	}
+	for i := range days {
+		days[i].OrgID = orgID
+	}
+
+	return &GetUsageResponse{
+		OrgID: orgID,
+		Days:  days,
+	}, nil
+}
+
+// @Summary      	Get Quota
+// @Description		Returns the daily quotas of the user's org and how much of them is used today.
+// @Tags			Usage
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Success			200					{object}	QuotaResponse
+// @Router			/api/usage/quota	[GET]
+func (s *usageApi) GetQuota(ctx context.Context, req *QuotaRequest) (res *QuotaResponse, err error) {
+	if req.UserID == 0 {
+		return nil, apperr.New(apperr.Invalid, "missing_user_id", "user id is required")
+	}
+
+	orgID := s.orgIDForUser(ctx, req.UserID)
+	dayStart := utcDay(time.Now())
+
+	var used struct {
+		Tokens        int
+		AnalysisCalls int
+	}
+	result := s.scope(ctx, orgID, req.UserID).
+		Model(&UsageEvent{}).
+		Select(`COALESCE(SUM(prompt_tokens + completion_tokens), 0) AS tokens,
+			COALESCE(SUM(CASE WHEN kind = ? THEN 1 ELSE 0 END), 0) AS analysis_calls`, KindAnalysisCall).
+		Where("created_at >= ?", dayStart).
+		Scan(&used)
+	if result.Error != nil {
+		s.logger.Errorf("func: GetQuota, operation: Scan(&used), err: %s", result.Error.Error())
+		return nil, result.Error
+	}
+
+	return &QuotaResponse{
+		OrgID:              orgID,
+		DailyTokenLimit:    s.featureLimit(ctx, orgID, DailyTokenLimitFeature, DefaultDailyTokenLimit),
+		DailyTokensUsed:    used.Tokens,
+		DailyAnalysisLimit: s.featureLimit(ctx, orgID, DailyAnalysisLimitFeature, DefaultDailyAnalysisLimit),
+		DailyAnalysisUsed:  used.AnalysisCalls,
+	}, nil
+}
+
+// scope limits usage queries to the org, or to the user alone when they have no org.
+func (s *usageApi) scope(ctx context.Context, orgID *int, userID int) *gorm.DB {
+	db := s.db.WithContext(ctx)
+
+	if orgID != nil {
+		return db.Where("org_id = ?", *orgID)
+	}
+	return db.Where("org_id IS NULL AND user_id = ?", userID)
+}
+
+func (s *usageApi) orgIDForUser(ctx context.Context, userID int) *int {
+	if userID == 0 {
+		return nil
+	}
+
+	var orgIDs []int
+	s.db.WithContext(ctx).Table("user_org_roles").
+		Where("user_id = ? AND deleted_at IS NULL", userID).
+		Order("created_at").
+		Limit(1).
+		Pluck("org_id", &orgIDs)
+	if len(orgIDs) == 0 {
+		return nil
+	}
+
+	return &orgIDs[0]
+}
+
+func (s *usageApi) featureLimit(ctx context.Context, orgID *int, key string, defaultVal int) int {
+	if orgID == nil {
+		return defaultVal
+	}
+
+	var values []string
+	s.db.WithContext(ctx).Table("features").
+		Joins("JOIN orgs ON orgs.subscription_id = features.subscription_id").
+		Where("orgs.id = ? AND features.key = ?", *orgID, key).
+		Limit(1).
+		Pluck("features.value", &values)
+	if len(values) == 0 {
+		return defaultVal
+	}
+
+	limit, err := strconv.Atoi(values[0])
+	if err != nil {
+		s.logger.Errorf("func: featureLimit, operation: strconv.Atoi, key: %s, err: %s", key, err.Error())
+		return defaultVal
+	}
+
+	return limit
+}
+
+// utcDay is the start of the UTC day of t. Quotas and usage are counted per
+// UTC day.
+func utcDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,21 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gofiber/fiber/v2/log"
+)
+
+func TestCheckQuotaPayer(t *testing.T) {
+	// Neither case reaches the database
+	s := NewUsageAPI(nil, log.DefaultLogger())
+
+	if err := s.CheckQuota(context.Background(), &CheckQuotaRequest{Payer: JobPayer, Kind: KindAnalysisCall}); err != nil {
+		t.Errorf("CheckQuota(job) = %v, want nil", err)
+	}
+	if err := s.CheckQuota(context.Background(), &CheckQuotaRequest{Kind: KindAnalysisCall}); !errors.Is(err, ErrNoPayer) {
+		t.Errorf("CheckQuota(no payer) = %v, want ErrNoPayer", err)
+	}
+}
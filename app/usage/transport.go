@@ -0,0 +1,56 @@
+package usage
+
+import (
+	"vezhguesi/core/middleware"
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type UsageHTTPTransport interface {
+	GetUsage(c *fiber.Ctx) error
+	GetQuota(c *fiber.Ctx) error
+}
+
+type usageHttpTransport struct {
+	usageAPI UsageAPI
+}
+
+func NewUsageHTTPTransport(usageAPI UsageAPI) UsageHTTPTransport {
+	return &usageHttpTransport{usageAPI: usageAPI}
+}
+
+func (s *usageHttpTransport) GetUsage(c *fiber.Ctx) error {
+	req := &GetUsageRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "GetUsage.middleware.CtxUserID")
+	}
+	if err := c.QueryParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidQuery.Wrap(err), "GetUsage.c.QueryParser")
+	}
+	req.UserID = userId
+
+	resp, err := s.usageAPI.GetUsage(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "GetUsage.usageAPI.GetUsage")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *usageHttpTransport) GetQuota(c *fiber.Ctx) error {
+	req := &QuotaRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "GetQuota.middleware.CtxUserID")
+	}
+	req.UserID = userId
+
+	resp, err := s.usageAPI.GetQuota(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "GetQuota.usageAPI.GetQuota")
+	}
+
+	return c.JSON(resp)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	entitysvc "vezhguesi/app/entities"
+	reportsvc "vezhguesi/app/reports"
+	usagesvc "vezhguesi/app/usage"
+	"vezhguesi/core/audit"
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/config"
+	dbseeds "vezhguesi/core/db/seeds"
+	usersvc "vezhguesi/core/users"
+	server "vezhguesi/sentiment-communication"
+
+	"github.com/gofiber/fiber/v2/log"
+	"gopkg.in/gomail.v2"
+	"gorm.io/gorm"
+)
+
+// cliActor is recorded in the audit log for changes made by commands.
+var cliActor = audit.Actor{UserAgent: "cli"}
+
+// environment is what every command runs with, db is nil for noDB
+// commands.
+type environment struct {
+	cfg    *config.Config
+	db     *gorm.DB
+	logger log.AllLogger
+}
+
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(env *environment, args []string) error
+	// noDB commands run without connecting to the database
+	noDB bool
+}
+
+// commands lists the subcommands of the binary, "serve" runs when none is
+// given. It is filled in init since the commands print their usage from it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{
+			name:    "serve",
+			usage:   "serve [--port N] [--migrate=false]",
+			summary: "Run the HTTP API",
+			run:     runServe,
+		},
+		{
+			name:    "migrate",
+			usage:   "migrate up | down [steps] | status",
+			summary: "Apply, roll back or list schema migrations",
+			run:     runMigrate,
+		},
+		{
+			name:    "seed",
+			usage:   "seed",
+			summary: "Create the default roles and permissions",
+			run:     runSeed,
+		},
+		{
+			name:    "sync-articles",
+			usage:   "sync-articles",
+			summary: "Fetch new articles from the article server",
+			run:     runSyncArticles,
+		},
+		{
+			name:    "reanalyze",
+			usage:   "reanalyze --since DATE",
+			summary: "Analyze again the articles scraped since DATE (YYYY-MM-DD or RFC3339)",
+			run:     runReanalyze,
+		},
+		{
+			name:    "regenerate-entity-reports",
+			usage:   "regenerate-entity-reports --entity NAME",
+			summary: "Generate a new report for an entity",
+			run:     runRegenerateEntityReports,
+		},
+		{
+			name:    "create-admin",
+			usage:   "create-admin --email EMAIL [--password P] [--username U]",
+			summary: "Create an admin, or promote an existing user",
+			run:     runCreateAdmin,
+		},
+		{
+			name:    "retag-entities",
+			usage:   "retag-entities",
+			summary: "Link stored articles to the entities they mention",
+			run:     runRetagEntities,
+		},
+		{
+			name:    "config",
+			usage:   "config",
+			summary: "Print the configuration with secrets redacted",
+			run:     runConfig,
+			noDB:    true,
+		},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: vezhguesi <command> [flags]")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.usage, cmd.summary)
+	}
+	tw.Flush()
+}
+
+// newFlagSet returns the flags of a command, printing its usage line on
+// -h or a parse error.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		cmd, _ := findCommand(name)
+		fmt.Fprintf(fs.Output(), "usage: %s\n", cmd.usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func runSeed(env *environment, args []string) error {
+	if err := newFlagSet("seed").Parse(args); err != nil {
+		return err
+	}
+	dbseeds.SeedDefaultRolesAndPermissions(env.db)
+	return nil
+}
+
+func runSyncArticles(env *environment, args []string) error {
+	if err := newFlagSet("sync-articles").Parse(args); err != nil {
+		return err
+	}
+	return newServerAPI(env).SyncArticles(context.Background(), cliActor)
+}
+
+func runReanalyze(env *environment, args []string) error {
+	fs := newFlagSet("reanalyze")
+	since := fs.String("since", "", "analyze articles scraped since this date")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *since == "" {
+		fs.Usage()
+		return fmt.Errorf("--since is required")
+	}
+	from, err := parseDate(*since)
+	if err != nil {
+		return err
+	}
+
+	analyzed, err := newServerAPI(env).ReanalyzeArticles(context.Background(), from)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("reanalyzed %d articles\n", analyzed)
+	return nil
+}
+
+func runRegenerateEntityReports(env *environment, args []string) error {
+	fs := newFlagSet("regenerate-entity-reports")
+	entity := fs.String("entity", "", "name of the entity")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*entity) == "" {
+		fs.Usage()
+		return fmt.Errorf("--entity is required")
+	}
+
+	usageApi := usagesvc.NewUsageAPI(env.db, env.logger)
+	reportsApi := reportsvc.NewReportsAPI(
+		env.db,
+		env.cfg.HTTP.UIAppURL,
+		env.logger,
+		entitysvc.NewEntitiesAPI(env.db, env.logger),
+		server.NewServerAPI(env.db, env.logger, usageApi, env.cfg.Analysis),
+		usageApi,
+		env.cfg.OpenAI.APIKey.Value(),
+	)
+	report, err := reportsApi.RegenerateEntityReport(context.Background(), strings.TrimSpace(*entity))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("regenerated the report of %s from %d articles\n", report.EntityName, report.ArticleCount)
+	return nil
+}
+
+func runCreateAdmin(env *environment, args []string) error {
+	fs := newFlagSet("create-admin")
+	req := usersvc.CreateAdminRequest{Actor: cliActor}
+	fs.StringVar(&req.Email, "email", "", "email of the admin")
+	fs.StringVar(&req.Password, "password", "", "password of a new admin, defaults to $ADMIN_PASSWORD")
+	fs.StringVar(&req.Username, "username", "", "username of a new admin")
+	fs.StringVar(&req.FirstName, "first-name", "", "first name of a new admin")
+	fs.StringVar(&req.LastName, "last-name", "", "last name of a new admin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if req.Password == "" {
+		// Keeps the password out of the shell history
+		req.Password = os.Getenv("ADMIN_PASSWORD")
+	}
+
+	secretKey := env.cfg.Auth.JWTSecretKey.Value()
+	userApi := usersvc.NewUserAPI(env.db, secretKey, env.cfg.HTTP.UIAppURL, env.logger, session.NewTokenIssuer(env.db, secretKey), nil)
+	res, err := userApi.CreateAdmin(context.Background(), &req)
+	if err != nil {
+		return err
+	}
+	if res.Created {
+		fmt.Printf("created admin %d\n", res.ID)
+	} else {
+		fmt.Printf("user %d is an admin\n", res.ID)
+	}
+	return nil
+}
+
+func runRetagEntities(env *environment, args []string) error {
+	if err := newFlagSet("retag-entities").Parse(args); err != nil {
+		return err
+	}
+	// Ctrl-C stops between batches, keeping the links made so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	created, err := newServerAPI(env).TagArticleEntities(ctx)
+	fmt.Printf("linked %d article entities\n", created)
+	return err
+}
+
+func runConfig(env *environment, args []string) error {
+	if err := newFlagSet("config").Parse(args); err != nil {
+		return err
+	}
+	fmt.Print(env.cfg)
+	return nil
+}
+
+func newServerAPI(env *environment) server.ServerAPI {
+	return server.NewServerAPI(env.db, env.logger, usagesvc.NewUsageAPI(env.db, env.logger), env.cfg.Analysis)
+}
+
+func newDialer(cfg config.Mail) *gomail.Dialer {
+	return gomail.NewDialer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword.Value())
+}
+
+// parseDate accepts a day as YYYY-MM-DD or a time as RFC3339.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD or RFC3339", value)
+	}
+	return t, nil
+}
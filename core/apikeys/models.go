@@ -0,0 +1,51 @@
+package apikeys
+
+import (
+	"time"
+	"vezhguesi/core/audit"
+)
+
+type CreateAPIKeyRequest struct {
+	UserID        int         `json:"-"`
+	OrgID         *int        `json:"orgId"`
+	Name          string      `json:"name"`
+	Scopes        []string    `json:"scopes"`
+	ExpiresInDays int         `json:"expiresInDays"`
+	Actor         audit.Actor `json:"-"`
+}
+
+type APIKeyResponse struct {
+	ID         uint       `json:"id"`
+	OrgID      *int       `json:"orgId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	// Key is only returned once, at creation
+	Key string `json:"key"`
+}
+
+type FindAPIKeysRequest struct {
+	UserID int `json:"-"`
+}
+
+type FindAPIKeysResponse struct {
+	APIKeys []APIKeyResponse `json:"apiKeys"`
+}
+
+type IDRequest struct {
+	ID     uint        `json:"-"`
+	UserID int         `json:"-"`
+	Actor  audit.Actor `json:"-"`
+}
+
+type StatusResponse struct {
+	Status bool `json:"status"`
+}
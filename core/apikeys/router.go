@@ -0,0 +1,14 @@
+package apikeys
+
+import (
+	"vezhguesi/core/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterRoutes(router fiber.Router, apiKeysHttpApi APIKeysHTTPTransport, authMiddleware func(c *fiber.Ctx) error) {
+	apiKeysRoutes := router.Group("/api-keys")
+	apiKeysRoutes.Post("", authMiddleware, middleware.DenyImpersonation, apiKeysHttpApi.Create)
+	apiKeysRoutes.Get("", authMiddleware, apiKeysHttpApi.Find)
+	apiKeysRoutes.Delete("/:id", authMiddleware, apiKeysHttpApi.Revoke)
+}
@@ -0,0 +1,208 @@
+package apikeys
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
+	session "vezhguesi/core/authentication"
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2/log"
+	"gorm.io/gorm"
+)
+
+const maxAPIKeyLifetimeDays = 365
+
+type apiKeysApi struct {
+	db     *gorm.DB
+	logger log.AllLogger
+}
+
+type APIKeysAPI interface {
+	Create(ctx context.Context, req *CreateAPIKeyRequest) (res *CreateAPIKeyResponse, err error)
+	Find(ctx context.Context, req *FindAPIKeysRequest) (res *FindAPIKeysResponse, err error)
+	Revoke(ctx context.Context, req *IDRequest) (res *StatusResponse, err error)
+}
+
+func NewAPIKeysAPI(db *gorm.DB, logger log.AllLogger) APIKeysAPI {
+	return &apiKeysApi{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// @Summary      	Create API Key
+// @Description		Validates name, scopes and org. Creates an API key for the user, or for the org when orgId is set (owners and admins only). The key is only returned in this response.
+// @Tags			API Keys
+// @Accept			json
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			CreateAPIKeyRequest	body		CreateAPIKeyRequest	true	"CreateAPIKeyRequest"
+// @Success			200					{object}	CreateAPIKeyResponse
+// @Router			/api/api-keys	[POST]
+func (s *apiKeysApi) Create(ctx context.Context, req *CreateAPIKeyRequest) (res *CreateAPIKeyResponse, err error) {
+	db := s.db.WithContext(ctx)
+
+	if req.UserID == 0 {
+		return nil, apperr.New(apperr.Invalid, "missing_user_id", "user id is required")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, apperr.New(apperr.Invalid, "missing_name", "name is required")
+	}
+	if len(req.Scopes) == 0 {
+		return nil, apperr.New(apperr.Invalid, "missing_scope", "at least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if !session.ValidScope(scope) {
+			return nil, apperr.Newf(apperr.Invalid, "invalid_scope", "invalid scope %q", scope)
+		}
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAPIKeyLifetimeDays {
+		return nil, apperr.Newf(apperr.Invalid, "invalid_expiry", "invalid expiresInDays, must be between 0 and %d", maxAPIKeyLifetimeDays)
+	}
+	if req.OrgID != nil && !s.isOrgManager(ctx, req.UserID, *req.OrgID) {
+		return nil, apperr.New(apperr.Forbidden, "org_admin_required", "only org owners and admins can create org api keys")
+	}
+
+	raw, prefix, err := session.GenerateAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate api key")
+	}
+
+	key := session.APIKey{
+		UserID:  req.UserID,
+		OrgID:   req.OrgID,
+		Name:    req.Name,
+		Prefix:  prefix,
+		KeyHash: session.HashToken(raw),
+		Scopes:  strings.Join(req.Scopes, ","),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&key).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			OrgID:      req.OrgID,
+			Action:     audit.ActionAPIKeyCreated,
+			TargetType: audit.TargetAPIKey,
+			TargetID:   key.ID,
+			After:      map[string]interface{}{"name": key.Name, "prefix": key.Prefix, "scopes": req.Scopes, "expiresAt": key.ExpiresAt},
+		})
+	})
+	if err != nil {
+		s.logger.Errorf("func: Create, operation: s.db.Create(&key), err: %s", err.Error())
+		return nil, err
+	}
+
+	return &CreateAPIKeyResponse{
+		APIKeyResponse: toResponse(&key),
+		Key:            raw,
+	}, nil
+}
+
+// @Summary      	Find API Keys
+// @Description		Lists the API keys created by the user, including revoked and expired ones.
+// @Tags			API Keys
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Success			200					{object}	FindAPIKeysResponse
+// @Router			/api/api-keys	[GET]
+func (s *apiKeysApi) Find(ctx context.Context, req *FindAPIKeysRequest) (res *FindAPIKeysResponse, err error) {
+	if req.UserID == 0 {
+		return nil, apperr.New(apperr.Invalid, "missing_user_id", "user id is required")
+	}
+
+	var keys []session.APIKey
+	if err := s.db.WithContext(ctx).Where("user_id = ?", req.UserID).Order("id DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+
+	res = &FindAPIKeysResponse{APIKeys: make([]APIKeyResponse, 0, len(keys))}
+	for i := range keys {
+		res.APIKeys = append(res.APIKeys, toResponse(&keys[i]))
+	}
+
+	return res, nil
+}
+
+// @Summary      	Revoke API Key
+// @Description		Revokes an API key of the user, or an org key when the user is owner or admin of the org.
+// @Tags			API Keys
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			id				path		int		true	"API Key ID"
+// @Success			200					{object}	StatusResponse
+// @Router			/api/api-keys/{id}	[DELETE]
+func (s *apiKeysApi) Revoke(ctx context.Context, req *IDRequest) (res *StatusResponse, err error) {
+	db := s.db.WithContext(ctx)
+
+	if req.UserID == 0 {
+		return nil, apperr.New(apperr.Invalid, "missing_user_id", "user id is required")
+	}
+	if req.ID == 0 {
+		return nil, helper.ErrMissingId
+	}
+
+	var key session.APIKey
+	if err := db.First(&key, req.ID).Error; err != nil {
+		return nil, helper.ErrNotFound
+	}
+	if key.UserID != req.UserID && (key.OrgID == nil || !s.isOrgManager(ctx, req.UserID, *key.OrgID)) {
+		return nil, helper.ErrNotFound
+	}
+
+	if key.RevokedAt == nil {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&key).Update("revoked_at", time.Now()).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, audit.Event{
+				Actor:      req.Actor,
+				OrgID:      key.OrgID,
+				Action:     audit.ActionAPIKeyRevoked,
+				TargetType: audit.TargetAPIKey,
+				TargetID:   key.ID,
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &StatusResponse{Status: true}, nil
+}
+
+func (s *apiKeysApi) isOrgManager(ctx context.Context, userID, orgID int) bool {
+	var count int64
+	s.db.WithContext(ctx).Table("user_org_roles").
+		Joins("JOIN roles ON roles.id = user_org_roles.role_id").
+		Where("user_org_roles.user_id = ? AND user_org_roles.org_id = ? AND user_org_roles.deleted_at IS NULL", userID, orgID).
+		Where("roles.name IN ?", []string{helper.OwnerRoleName, helper.AdminRoleName}).
+		Count(&count)
+	return count > 0
+}
+
+func toResponse(key *session.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		OrgID:      key.OrgID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		LastUsedAt: key.LastUsedAt,
+		ExpiresAt:  key.ExpiresAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
@@ -0,0 +1,82 @@
+package apikeys
+
+import (
+	"strconv"
+	"vezhguesi/core/audit"
+	"vezhguesi/core/middleware"
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type APIKeysHTTPTransport interface {
+	Create(c *fiber.Ctx) error
+	Find(c *fiber.Ctx) error
+	Revoke(c *fiber.Ctx) error
+}
+
+type apiKeysHttpTransport struct {
+	apiKeysAPI APIKeysAPI
+}
+
+func NewAPIKeysHTTPTransport(apiKeysAPI APIKeysAPI) APIKeysHTTPTransport {
+	return &apiKeysHttpTransport{apiKeysAPI: apiKeysAPI}
+}
+
+func (s *apiKeysHttpTransport) Create(c *fiber.Ctx) error {
+	req := &CreateAPIKeyRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "CreateAPIKey.middleware.CtxUserID")
+	}
+	if err := c.BodyParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "CreateAPIKey.c.BodyParser")
+	}
+	req.UserID = userId
+	req.Actor = audit.ActorFrom(c)
+
+	resp, err := s.apiKeysAPI.Create(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "CreateAPIKey.apiKeysAPI.Create")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *apiKeysHttpTransport) Find(c *fiber.Ctx) error {
+	req := &FindAPIKeysRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "FindAPIKeys.middleware.CtxUserID")
+	}
+	req.UserID = userId
+
+	resp, err := s.apiKeysAPI.Find(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "FindAPIKeys.apiKeysAPI.Find")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *apiKeysHttpTransport) Revoke(c *fiber.Ctx) error {
+	req := &IDRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "RevokeAPIKey.middleware.CtxUserID")
+	}
+	req.UserID = userId
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidArgument, "RevokeAPIKey.strconv.Atoi")
+	}
+	req.ID = uint(id)
+	req.Actor = audit.ActorFrom(c)
+
+	resp, err := s.apiKeysAPI.Revoke(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "RevokeAPIKey.apiKeysAPI.Revoke")
+	}
+
+	return c.JSON(resp)
+}
@@ -0,0 +1,77 @@
+package apperr
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+const (
+	// ProblemContentType is the media type of RFC 7807 bodies
+	ProblemContentType = "application/problem+json"
+	// problemTypePrefix namespaces the problem type URI of every error code
+	problemTypePrefix = "urn:vezhguesi:problem:"
+	// LocationKey is the request local holding where an error was handled,
+	// logged along with server errors
+	LocationKey = "errLocation"
+)
+
+// Problem is an RFC 7807 problem details body. Code repeats the last segment
+// of Type for clients that switch on it.
+type Problem struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Detail   string         `json:"detail,omitempty"`
+	Instance string         `json:"instance,omitempty"`
+	Code     string         `json:"code"`
+	Details  map[string]any `json:"details,omitempty"`
+}
+
+// NewProblem describes err for clients. Server errors only expose their
+// message, never the wrapped cause.
+func NewProblem(err error, instance string) Problem {
+	var e *Error
+	var fe *fiber.Error
+	status := 0
+	switch {
+	case errors.As(err, &e):
+		status = e.Kind.Status()
+	case errors.As(err, &fe):
+		// Raised by fiber itself, e.g. unknown routes or oversized bodies
+		code := strings.ReplaceAll(strings.ToLower(http.StatusText(fe.Code)), " ", "_")
+		e, status = New(KindOfStatus(fe.Code), code, fe.Message), fe.Code
+	default:
+		e = From(err)
+		status = e.Kind.Status()
+	}
+
+	return Problem{
+		Type:     problemTypePrefix + e.Code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   e.Message,
+		Instance: instance,
+		Code:     e.Code,
+		Details:  e.Details,
+	}
+}
+
+// Handler is the fiber.Config ErrorHandler of the app. It writes every error
+// returned by a handler as problem+json and logs server errors with their
+// cause, along with the IDs of the request.
+func Handler(logger log.AllLogger) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		problem := NewProblem(err, c.OriginalURL())
+		if problem.Status >= http.StatusInternalServerError {
+			location, _ := c.Locals(LocationKey).(string)
+			logger.WithContext(c.UserContext()).Errorw("request failed",
+				"method", c.Method(), "path", c.Path(), "location", location, "err", err.Error())
+		}
+
+		return c.Status(problem.Status).JSON(problem, ProblemContentType)
+	}
+}
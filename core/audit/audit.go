@@ -0,0 +1,253 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"vezhguesi/core/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionSignup                   = "auth.signup"
+	ActionVerifyEmail              = "auth.verify_email"
+	ActionLogin                    = "auth.login"
+	ActionLoginFailed              = "auth.login_failed"
+	ActionAccountLocked            = "auth.account_locked"
+	ActionLogout                   = "auth.logout"
+	ActionSessionRevoked           = "auth.session_revoked"
+	ActionPasswordResetRequested   = "auth.password_reset_requested"
+	ActionPasswordReset            = "auth.password_reset"
+	ActionTwoFactorEnabled         = "auth.two_factor_enabled"
+	ActionTwoFactorDisabled        = "auth.two_factor_disabled"
+	ActionRecoveryCodesRegenerated = "auth.recovery_codes_regenerated"
+	ActionUserUpdated              = "user.updated"
+	ActionPasswordChanged          = "user.password_changed"
+	ActionEmailChangeRequested     = "user.email_change_requested"
+	ActionEmailChanged             = "user.email_changed"
+	ActionUserActivated            = "user.activated"
+	ActionUserDeactivated          = "user.deactivated"
+	ActionUserDeleted              = "user.deleted"
+	ActionUserRestored             = "user.restored"
+	ActionUserRoleChanged          = "user.role_changed"
+	ActionPasswordResetForced      = "user.password_reset_forced"
+	ActionImpersonationStarted     = "user.impersonation_started"
+	ActionAdminCreated             = "user.admin_created"
+	ActionAvatarUpdated            = "user.avatar_updated"
+	ActionAvatarDeleted            = "user.avatar_deleted"
+	ActionDataExported             = "user.data_exported"
+	ActionAccountDeleted           = "user.account_deleted"
+	ActionAPIKeyCreated            = "api_key.created"
+	ActionAPIKeyRevoked            = "api_key.revoked"
+	ActionOrgCreated               = "org.created"
+	ActionOrgTwoFactorPolicy       = "org.two_factor_policy_changed"
+	ActionOrgInviteSent            = "org.invite_sent"
+	ActionOrgInviteAccepted        = "org.invite_accepted"
+	ActionOrgOwnershipTransferred  = "org.ownership_transferred"
+	ActionOrgDeleted               = "org.deleted"
+	ActionReportCreated            = "report.created"
+	ActionReportUpdated            = "report.updated"
+	ActionEntityCreated            = "entity.created"
+	ActionArticlesSynced           = "articles.synced"
+	ActionJobTriggered             = "job.triggered"
+)
+
+// Target types
+const (
+	TargetUser    = "user"
+	TargetSession = "session"
+	TargetAPIKey  = "api_key"
+	TargetOrg     = "org"
+	TargetReport  = "report"
+	TargetEntity  = "entity"
+	TargetJob     = "job"
+)
+
+const redacted = "[REDACTED]"
+
+// Actor is who performed an action and from where. Request structs carry it
+// as a `json:"-"` field filled by the HTTP transport. ImpersonatorID is set
+// when an admin acts as UserID.
+type Actor struct {
+	UserID         int
+	APIKeyID       uint
+	ImpersonatorID int
+	IP             string
+	UserAgent      string
+}
+
+// ActorFrom builds the Actor of the request from the authenticated principal,
+// if any, and the client IP and user agent.
+func ActorFrom(c *fiber.Ctx) Actor {
+	actor := Actor{
+		IP:        c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+	}
+	if principal, err := middleware.CtxPrincipal(c); err == nil {
+		actor.UserID = principal.UserID
+		actor.APIKeyID = principal.APIKeyID
+		actor.ImpersonatorID = principal.ImpersonatorID
+	}
+	return actor
+}
+
+// Event describes an audited action. Before and After are the target before
+// and after the change, nil when created or deleted; any struct or map that
+// marshals to a JSON object works.
+type Event struct {
+	Actor      Actor
+	OrgID      *int
+	Action     string
+	TargetType string
+	TargetID   interface{}
+	Before     interface{}
+	After      interface{}
+}
+
+// Record writes the event to the audit log using db, which should be the
+// transaction of the change when there is one.
+func Record(db *gorm.DB, event Event) error {
+	changes, err := Diff(event.Before, event.After)
+	if err != nil {
+		return err
+	}
+
+	entry := Log{
+		OrgID:      event.OrgID,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		IP:         event.Actor.IP,
+		UserAgent:  event.Actor.UserAgent,
+	}
+	if event.Actor.UserID != 0 {
+		userID := event.Actor.UserID
+		entry.ActorUserID = &userID
+	}
+	if event.Actor.ImpersonatorID != 0 {
+		impersonatorID := event.Actor.ImpersonatorID
+		entry.ImpersonatorUserID = &impersonatorID
+	}
+	if event.Actor.APIKeyID != 0 {
+		apiKeyID := event.Actor.APIKeyID
+		entry.APIKeyID = &apiKeyID
+	}
+	if event.TargetID != nil {
+		entry.TargetID = fmt.Sprint(event.TargetID)
+	}
+	if len(changes) > 0 {
+		b, err := json.Marshal(changes)
+		if err != nil {
+			return err
+		}
+		entry.Changes = string(b)
+	}
+
+	return db.Create(&entry).Error
+}
+
+// Change is the before and after value of one field.
+type Change struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// Diff returns the top-level fields that differ between before and after.
+// Values of fields that look like secrets are replaced with a placeholder.
+func Diff(before, after interface{}) (map[string]Change, error) {
+	b, err := toMap(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := toMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]Change{}
+	for key, bv := range b {
+		av, ok := a[key]
+		if ok && reflect.DeepEqual(av, bv) {
+			continue
+		}
+		change := Change{Before: bv}
+		if ok {
+			change.After = av
+		}
+		changes[key] = redact(key, change)
+	}
+	for key, av := range a {
+		if _, ok := b[key]; !ok {
+			changes[key] = redact(key, Change{After: av})
+		}
+	}
+
+	return changes, nil
+}
+
+func toMap(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return map[string]interface{}{}, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("audit: target must marshal to a JSON object: %w", err)
+	}
+	return m, nil
+}
+
+func redact(key string, change Change) Change {
+	k := strings.ToLower(key)
+	for _, secret := range []string{"password", "secret", "token", "hash"} {
+		if strings.Contains(k, secret) {
+			if change.Before != nil {
+				change.Before = redacted
+			}
+			if change.After != nil {
+				change.After = redacted
+			}
+			break
+		}
+	}
+	return change
+}
+
+// personalFields are the keys of changes holding a user's personal data.
+var personalFields = []string{"email", "newEmail", "username", "firstName", "lastName", "phone", "avatarImgKey", "avatarImgUrl"}
+
+// ScrubUser removes the personal data of a deleted account from the log: the
+// personal fields of the entries targeting the user, and the email field of
+// other entries holding the account's email, e.g. org invites. The entries
+// themselves are kept.
+func ScrubUser(db *gorm.DB, userID int, emails ...string) error {
+	fields := "{" + strings.Join(personalFields, ",") + "}"
+	err := db.Model(&Log{}).
+		Where("target_type = ? AND target_id = ? AND changes <> ''", TargetUser, strconv.Itoa(userID)).
+		Update("changes", gorm.Expr("(changes::jsonb - ?::text[])::text", fields)).Error
+	if err != nil {
+		return err
+	}
+
+	for _, email := range emails {
+		quoted, err := json.Marshal(email)
+		if err != nil {
+			return err
+		}
+		err = db.Model(&Log{}).
+			Where("strpos(changes, ?) > 0", string(quoted)).
+			Update("changes", gorm.Expr("(changes::jsonb - 'email')::text")).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
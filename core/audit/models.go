@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type FindLogsRequest struct {
+	ActorUserID int    `query:"actorUserId"`
+	OrgID       int    `query:"orgId"`
+	Action      string `query:"action"`
+	TargetType  string `query:"targetType"`
+	TargetID    string `query:"targetId"`
+	From        string `query:"from"`
+	To          string `query:"to"`
+	Page        int    `query:"page"`
+	PageSize    int    `query:"pageSize"`
+}
+
+type LogResponse struct {
+	ID                 uint            `json:"id"`
+	ActorUserID        *int            `json:"actorUserId"`
+	APIKeyID           *uint           `json:"apiKeyId"`
+	ImpersonatorUserID *int            `json:"impersonatorUserId,omitempty"`
+	OrgID              *int            `json:"orgId"`
+	Action             string          `json:"action"`
+	TargetType         string          `json:"targetType"`
+	TargetID           string          `json:"targetId"`
+	Changes            json.RawMessage `json:"changes,omitempty"`
+	IP                 string          `json:"ip"`
+	UserAgent          string          `json:"userAgent"`
+	CreatedAt          time.Time       `json:"createdAt"`
+}
+
+type FindLogsResponse struct {
+	Logs     []LogResponse `json:"logs"`
+	Total    int64         `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"pageSize"`
+}
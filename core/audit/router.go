@@ -0,0 +1,13 @@
+package audit
+
+import (
+	"vezhguesi/core/middleware"
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterRoutes(router fiber.Router, auditHttpApi AuditHTTPTransport, authMiddleware func(c *fiber.Ctx) error) {
+	adminRoutes := router.Group("/admin", authMiddleware, middleware.RequireRole(helper.AdminRoleName))
+	adminRoutes.Get("/audit-logs", auditHttpApi.FindLogs)
+}
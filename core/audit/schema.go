@@ -0,0 +1,25 @@
+package audit
+
+import "time"
+
+// Log is one audited action. Changes holds the JSON diff of the target,
+// {"field": {"before": ..., "after": ...}}, with secrets redacted.
+type Log struct {
+	ID          uint `gorm:"primaryKey"`
+	ActorUserID *int `gorm:"index"`
+	APIKeyID    *uint
+	// ImpersonatorUserID is the admin who acted as ActorUserID, if any
+	ImpersonatorUserID *int   `gorm:"index"`
+	OrgID              *int   `gorm:"index"`
+	Action             string `gorm:"not null;index"`
+	TargetType         string `gorm:"index"`
+	TargetID           string `gorm:"index"`
+	Changes            string `gorm:"type:text"`
+	IP                 string
+	UserAgent          string
+	CreatedAt          time.Time `gorm:"index"`
+}
+
+func (Log) TableName() string {
+	return "audit_logs"
+}
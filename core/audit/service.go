@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"vezhguesi/core/apperr"
+
+	"github.com/gofiber/fiber/v2/log"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+type auditApi struct {
+	db     *gorm.DB
+	logger log.AllLogger
+}
+
+type AuditAPI interface {
+	FindLogs(ctx context.Context, req *FindLogsRequest) (res *FindLogsResponse, err error)
+}
+
+func NewAuditAPI(db *gorm.DB, logger log.AllLogger) AuditAPI {
+	return &auditApi{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// @Summary      	Find Audit Logs
+// @Description		Admins only. Lists audit log entries, newest first, filtered by actor, org, action, target and date range (from/to as YYYY-MM-DD, both inclusive).
+// @Tags			Audit
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			actorUserId		query		int		false	"Actor user ID"
+// @Param			orgId			query		int		false	"Org ID"
+// @Param			action			query		string	false	"Action, e.g. report.updated"
+// @Param			targetType		query		string	false	"Target type"
+// @Param			targetId		query		string	false	"Target ID"
+// @Param			from			query		string	false	"From date (YYYY-MM-DD)"
+// @Param			to				query		string	false	"To date (YYYY-MM-DD)"
+// @Param			page			query		int		false	"Page, starting at 1"
+// @Param			pageSize		query		int		false	"Page size, max 200"
+// @Success			200					{object}	FindLogsResponse
+// @Router			/api/admin/audit-logs	[GET]
+func (s *auditApi) FindLogs(ctx context.Context, req *FindLogsRequest) (res *FindLogsResponse, err error) {
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.PageSize < 1 {
+		req.PageSize = defaultPageSize
+	}
+	if req.PageSize > maxPageSize {
+		req.PageSize = maxPageSize
+	}
+
+	query := s.db.WithContext(ctx).Model(&Log{})
+	if req.ActorUserID != 0 {
+		query = query.Where("actor_user_id = ?", req.ActorUserID)
+	}
+	if req.OrgID != 0 {
+		query = query.Where("org_id = ?", req.OrgID)
+	}
+	if req.Action != "" {
+		query = query.Where("action = ?", req.Action)
+	}
+	if req.TargetType != "" {
+		query = query.Where("target_type = ?", req.TargetType)
+	}
+	if req.TargetID != "" {
+		query = query.Where("target_id = ?", req.TargetID)
+	}
+	if req.From != "" {
+		from, err := time.Parse("2006-01-02", req.From)
+		if err != nil {
+			return nil, apperr.New(apperr.Invalid, "invalid_date", "invalid from date, expected YYYY-MM-DD")
+		}
+		query = query.Where("created_at >= ?", from)
+	}
+	if req.To != "" {
+		to, err := time.Parse("2006-01-02", req.To)
+		if err != nil {
+			return nil, apperr.New(apperr.Invalid, "invalid_date", "invalid to date, expected YYYY-MM-DD")
+		}
+		query = query.Where("created_at < ?", to.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var logs []Log
+	err = query.Order("created_at DESC, id DESC").
+		Offset((req.Page - 1) * req.PageSize).
+		Limit(req.PageSize).
+		Find(&logs).Error
+	if err != nil {
+		s.logger.Errorf("func: FindLogs, operation: query.Find(&logs), err: %s", err.Error())
+		return nil, err
+	}
+
+	res = &FindLogsResponse{
+		Logs:     make([]LogResponse, 0, len(logs)),
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	}
+	for i := range logs {
+		res.Logs = append(res.Logs, NewLogResponse(&logs[i]))
+	}
+
+	return res, nil
+}
+
+func NewLogResponse(l *Log) LogResponse {
+	entry := LogResponse{
+		ID:                 l.ID,
+		ActorUserID:        l.ActorUserID,
+		APIKeyID:           l.APIKeyID,
+		ImpersonatorUserID: l.ImpersonatorUserID,
+		OrgID:              l.OrgID,
+		Action:             l.Action,
+		TargetType:         l.TargetType,
+		TargetID:           l.TargetID,
+		IP:                 l.IP,
+		UserAgent:          l.UserAgent,
+		CreatedAt:          l.CreatedAt,
+	}
+	if l.Changes != "" {
+		entry.Changes = []byte(l.Changes)
+	}
+	return entry
+}
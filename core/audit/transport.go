@@ -0,0 +1,33 @@
+package audit
+
+import (
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuditHTTPTransport interface {
+	FindLogs(c *fiber.Ctx) error
+}
+
+type auditHttpTransport struct {
+	auditAPI AuditAPI
+}
+
+func NewAuditHTTPTransport(auditAPI AuditAPI) AuditHTTPTransport {
+	return &auditHttpTransport{auditAPI: auditAPI}
+}
+
+func (s *auditHttpTransport) FindLogs(c *fiber.Ctx) error {
+	req := &FindLogsRequest{}
+	if err := c.QueryParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidQuery.Wrap(err), "FindLogs.c.QueryParser")
+	}
+
+	resp, err := s.auditAPI.FindLogs(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "FindLogs.auditAPI.FindLogs")
+	}
+
+	return c.JSON(resp)
+}
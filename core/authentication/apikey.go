@@ -0,0 +1,137 @@
+package authentication
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"vezhguesi/core/apperr"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// API key scopes
+	ScopeReportsRead   = "reports:read"
+	ScopeEntitiesWrite = "entities:write"
+	ScopeArticlesSync  = "articles:sync"
+	ScopeMetricsRead   = "metrics:read"
+
+	APIKeyPrefix = "vzg"
+)
+
+// apiKeyIDBytes is the random part of the prefix, which is always
+// vzg_ and the 8 characters encoding these bytes.
+const apiKeyIDBytes = 6
+
+var apiKeyPrefixLen = len(APIKeyPrefix) + 1 + base64.RawURLEncoding.EncodedLen(apiKeyIDBytes)
+
+var Scopes = []string{ScopeReportsRead, ScopeEntitiesWrite, ScopeArticlesSync, ScopeMetricsRead}
+
+var ErrInvalidAPIKey = apperr.New(apperr.Unauthenticated, "invalid_api_key", "invalid api key")
+
+// APIKey grants programmatic access with a limited set of scopes. Keys belong
+// to the user who created them and, when OrgID is set, act within that org.
+// Only the prefix and a hash of the key are stored.
+type APIKey struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     int    `gorm:"not null;index"`
+	OrgID      *int   `gorm:"index"`
+	Name       string `gorm:"not null"`
+	Prefix     string `gorm:"unique;not null"`
+	KeyHash    string `gorm:"not null"`
+	Scopes     string `gorm:"not null"`
+	LastUsedAt *time.Time
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return nil
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// ValidScope reports whether scope is one of the known API key scopes.
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateAPIKey returns a new raw key of the form vzg_<prefix>_<secret>
+// together with its prefix.
+func GenerateAPIKey() (raw string, prefix string, err error) {
+	prefix, err = RandomToken(apiKeyIDBytes)
+	if err != nil {
+		return "", "", err
+	}
+	prefix = APIKeyPrefix + "_" + strings.NewReplacer("-", "x", "_", "y").Replace(prefix)
+
+	secret, err := RandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return prefix + "_" + secret, prefix, nil
+}
+
+// ResolveAPIKey authenticates a raw API key and returns its principal.
+func ResolveAPIKey(db *gorm.DB, raw string) (*Principal, error) {
+	raw = strings.TrimSpace(raw)
+	prefix, ok := splitAPIKey(raw)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var key APIKey
+	if err := db.Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(HashToken(raw))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && key.ExpiresAt.Before(now)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var user struct {
+		Role string
+	}
+	result := db.Table("users").
+		Select("role").
+		Where("id = ? AND active = ? AND deleted_at IS NULL", key.UserID, true).
+		Take(&user)
+	if result.Error != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Tracking last use at minute granularity is enough and saves a write per request
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > time.Minute {
+		db.Model(&key).Update("last_used_at", now)
+	}
+
+	return &Principal{
+		UserID:   key.UserID,
+		OrgID:    key.OrgID,
+		Role:     user.Role,
+		APIKeyID: key.ID,
+		Scopes:   key.ScopeList(),
+	}, nil
+}
+
+// splitAPIKey returns the prefix of a raw key. The prefix has a fixed length
+// since the secret after it may contain underscores too.
+func splitAPIKey(raw string) (string, bool) {
+	if !strings.HasPrefix(raw, APIKeyPrefix+"_") || len(raw) <= apiKeyPrefixLen+1 || raw[apiKeyPrefixLen] != '_' {
+		return "", false
+	}
+	return raw[:apiKeyPrefixLen], true
+}
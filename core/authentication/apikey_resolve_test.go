@@ -0,0 +1,59 @@
+package authentication_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/db/dbtest"
+	"vezhguesi/core/users"
+)
+
+func TestResolveGeneratedAPIKeys(t *testing.T) {
+	conn := dbtest.Open(t)
+
+	user := users.User{
+		Email:         fmt.Sprintf("apikey-%d@example.com", time.Now().UnixNano()),
+		Role:          "user",
+		Active:        true,
+		VerifiedEmail: true,
+	}
+	if err := conn.Omit("UpdatedAt").Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Where("user_id = ?", user.ID).Delete(&session.APIKey{})
+		conn.Delete(&users.User{}, user.ID)
+	})
+
+	for i := 0; i < 50; i++ {
+		raw, prefix, err := session.GenerateAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := session.APIKey{
+			UserID:  user.ID,
+			Name:    "test",
+			Prefix:  prefix,
+			KeyHash: session.HashToken(raw),
+			Scopes:  session.ScopeReportsRead,
+		}
+		if err := conn.Create(&key).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		principal, err := session.ResolveAPIKey(conn, raw)
+		if err != nil {
+			t.Fatalf("ResolveAPIKey(%q): %v", raw, err)
+		}
+		if principal.APIKeyID != key.ID || principal.UserID != user.ID {
+			t.Fatalf("ResolveAPIKey(%q) = key %d of user %d, want key %d of user %d", raw, principal.APIKeyID, principal.UserID, key.ID, user.ID)
+		}
+
+		// A key with another secret does not resolve
+		if _, err := session.ResolveAPIKey(conn, prefix+"_"+raw[len(prefix)+2:]+"x"); err == nil {
+			t.Fatalf("a key with another secret than %q was resolved", raw)
+		}
+	}
+}
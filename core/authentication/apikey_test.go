@@ -0,0 +1,46 @@
+package authentication
+
+import (
+	"strings"
+	"testing"
+)
+
+// Secrets are base64url and often contain underscores, which must not move
+// the split between prefix and secret.
+func TestSplitAPIKeyRoundTrip(t *testing.T) {
+	withUnderscore := 0
+	for i := 0; i < 2000; i++ {
+		raw, prefix, err := GenerateAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(raw[len(prefix)+1:], "_") {
+			withUnderscore++
+		}
+
+		got, ok := splitAPIKey(raw)
+		if !ok || got != prefix {
+			t.Fatalf("splitAPIKey(%q) = %q, %v, want %q", raw, got, ok, prefix)
+		}
+	}
+	if withUnderscore == 0 {
+		t.Error("no generated secret contained an underscore")
+	}
+}
+
+func TestSplitAPIKeyRejects(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"vzg",
+		"vzg_",
+		"vzg_abcdefgh",
+		"vzg_abcdefgh_",
+		"vzg_abc_defgh_secret",
+		"vzgxabcdefgh_secret",
+		"key_abcdefgh_secret",
+	} {
+		if prefix, ok := splitAPIKey(raw); ok {
+			t.Errorf("splitAPIKey(%q) = %q, want rejected", raw, prefix)
+		}
+	}
+}
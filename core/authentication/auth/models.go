@@ -2,12 +2,16 @@ package auth
 
 import (
 	"time"
+	"vezhguesi/core/audit"
 	"vezhguesi/core/users"
 )
 
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required"`
+	DeviceName string `json:"deviceName" validate:"max=100"`
+	UserAgent  string `json:"-"`
+	IP         string `json:"-"`
 }
 
 type StatusResponse struct {
@@ -15,9 +19,76 @@ type StatusResponse struct {
 }
 
 type LoginResponse struct {
-	UserData    *UserData `json:"userData"`
-	Token       string    `json:"token"`
-	SessionToken string   `json:"sessionToken"`
+	UserData     *UserData `json:"userData,omitempty"`
+	Token        string    `json:"token,omitempty"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	ExpiresIn    int64     `json:"expiresIn,omitempty"`
+	// Set instead of the tokens when a second factor is needed
+	TwoFactorRequired      bool     `json:"twoFactorRequired,omitempty"`
+	TwoFactorSetupRequired bool     `json:"twoFactorSetupRequired,omitempty"`
+	ChallengeToken         string   `json:"challengeToken,omitempty"`
+	RecoveryCodes          []string `json:"recoveryCodes,omitempty"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recoveryCode"`
+	DeviceName     string `json:"deviceName" validate:"max=100"`
+	UserAgent      string `json:"-"`
+	IP             string `json:"-"`
+}
+
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+}
+
+type TwoFactorRequest struct {
+	UserID   int         `json:"-" validate:"required"`
+	Code     string      `json:"code"`
+	Password string      `json:"password"`
+	Actor    audit.Actor `json:"-"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+type SessionRequest struct {
+	UserID           int         `json:"-" validate:"required"`
+	CurrentSessionID uint        `json:"-"`
+	SessionID        uint        `json:"-"`
+	Actor            audit.Actor `json:"-"`
+}
+
+type SessionData struct {
+	ID         uint       `json:"id"`
+	DeviceName string     `json:"deviceName"`
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	Current    bool       `json:"current"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+}
+
+type SessionsResponse struct {
+	Sessions []SessionData `json:"sessions"`
 }
 
 type UserData struct {
@@ -32,23 +103,40 @@ type UserData struct {
 }
 
 type ResetPasswordRequest struct {
-	Token              string `json:"-"`
-	NewPassword        string `json:"newPassword"`
-	ConfirmNewPassword string `json:"confirmNewPassword"`
+	Token              string      `json:"-" validate:"required"`
+	NewPassword        string      `json:"newPassword" validate:"required,password"`
+	ConfirmNewPassword string      `json:"confirmNewPassword" validate:"required,eqfield=NewPassword"`
+	Actor              audit.Actor `json:"-"`
 }
 
 type OAuthLoginRequest struct {
-	Provider string `json:"provider"`
-	Token    string `json:"token"`
+	Provider   string `json:"-"`
+	Code       string `json:"code" validate:"required"`
+	State      string `json:"state" validate:"required"`
+	DeviceName string `json:"deviceName" validate:"max=100"`
+	UserAgent  string `json:"-"`
+	IP         string `json:"-"`
+}
+
+type OAuthAuthorizeRequest struct {
+	Provider string `json:"-"`
+}
+
+type OAuthAuthorizeResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+	State            string `json:"state"`
 }
 
 type SignupRequest struct {
-	Email           string `json:"email"`
-	Username        string `json:"username"`
-	FirstName       string `json:"firstName"`
-	LastName        string `json:"lastName"`
-	Password        string `json:"password"`
-	ConfirmPassword string `json:"confirmPassword"`
+	Email           string `json:"email" validate:"required,email,max=255"`
+	Username        string `json:"username" validate:"max=50"`
+	FirstName       string `json:"firstName" validate:"max=100"`
+	LastName        string `json:"lastName" validate:"max=100"`
+	Password        string `json:"password" validate:"required,password"`
+	ConfirmPassword string `json:"confirmPassword" validate:"required,eqfield=Password"`
+	// Locale of the emails sent to the user, "en" or "sq"
+	Locale string      `json:"locale"`
+	Actor  audit.Actor `json:"-"`
 }
 
 type SignupResponse struct {
@@ -57,7 +145,8 @@ type SignupResponse struct {
 }
 
 type SignupVerifyRequest struct {
-	Token string `json:"-"`
+	Token string      `json:"-"`
+	Actor audit.Actor `json:"-"`
 }
 type PasswordUpdateRequest struct {
 	UserID             int    `json:"-"`
@@ -107,15 +196,17 @@ type UserResponse struct {
 }
 
 type UpdateUserRequest struct {
-	UserID int `json:"-"`
-	FirstName string `json:"firstName"`
-	LastName string `json:"lastName"`
-	Username string `json:"username"`
-	Phone string `json:"phone"`
+	UserID int `json:"-" validate:"required"`
+	FirstName string `json:"firstName" validate:"required,max=100"`
+	LastName string `json:"lastName" validate:"required,max=100"`
+	Username string `json:"username" validate:"required,max=50"`
+	Phone string `json:"phone" validate:"max=30"`
+	Actor audit.Actor `json:"-"`
 }
 
 type ForgotPasswordRequest struct {
-	Email        string `json:"email"`
+	Email        string `json:"email" validate:"required,email"`
+	Actor        audit.Actor `json:"-"`
 }
 
 
@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/authentication/oidc"
+	"vezhguesi/core/users"
+	"vezhguesi/core/validation"
+	helper "vezhguesi/helper"
+
+	"gorm.io/gorm"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+var usernameCleaner = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// @Summary      	OAuthAuthorize
+// @Description		Starts an OpenID Connect login with the provider. Returns the provider authorization URL (authorization-code flow with PKCE) and the state to be sent back with the code.
+// @Tags			Auth
+// @Produce			json
+// @Param			provider				path		string			true	"Provider"
+// @Success			200					{object}	OAuthAuthorizeResponse
+// @Router			/api/auth/oauth/{provider}/authorize	[GET]
+func (s *authApi) OAuthAuthorize(ctx context.Context, req *OAuthAuthorizeRequest) (res *OAuthAuthorizeResponse, err error) {
+	provider, ok := s.oidcProviders[req.Provider]
+	if !ok {
+		return nil, helper.ErrNotFound
+	}
+
+	state, errState := session.RandomToken(32)
+	nonce, errNonce := session.RandomToken(32)
+	verifier, errVerifier := session.RandomToken(48)
+	if errState != nil || errNonce != nil || errVerifier != nil {
+		return nil, fmt.Errorf("failed to generate oauth state")
+	}
+
+	authURL, err := provider.AuthCodeURL(state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		s.logger.Errorf("func: OAuthAuthorize, operation: provider.AuthCodeURL, err: %s", err.Error())
+		return nil, err
+	}
+
+	// Housekeeping: drop abandoned attempts
+	s.db.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&session.OAuthState{})
+
+	if err := s.db.WithContext(ctx).Create(&session.OAuthState{
+		State:        session.HashToken(state),
+		Provider:     req.Provider,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	}).Error; err != nil {
+		s.logger.Errorf("func: OAuthAuthorize, operation: s.db.Create(&session.OAuthState{}), err: %s", err.Error())
+		return nil, err
+	}
+
+	return &OAuthAuthorizeResponse{
+		AuthorizationURL: authURL,
+		State:            state,
+	}, nil
+}
+
+// @Summary      	OAuthLogin
+// @Description		Completes an OpenID Connect login. Validates the state, exchanges the code with the PKCE verifier, verifies the ID token against the provider JWKS, then logs in the linked user, links an existing user by verified email or creates a new verified user.
+// @Tags			Auth
+// @Accept			json
+// @Produce			json
+// @Param			provider				path		string			true	"Provider"
+// @Param			OAuthLoginRequest	body		OAuthLoginRequest	true	"OAuthLoginRequest"
+// @Success			200					{object}	LoginResponse
+// @Router			/api/auth/oauth/{provider}/callback	[POST]
+func (s *authApi) OAuthLogin(ctx context.Context, req *OAuthLoginRequest) (res *LoginResponse, err error) {
+	provider, ok := s.oidcProviders[req.Provider]
+	if !ok {
+		return nil, helper.ErrNotFound
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	req.State = strings.TrimSpace(req.State)
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+
+	// The state is single use: delete it before doing anything else with it
+	var state session.OAuthState
+	result := s.db.WithContext(ctx).Where("state = ? AND provider = ?", session.HashToken(req.State), req.Provider).First(&state)
+	if result.Error != nil {
+		return nil, apperr.New(apperr.Invalid, "invalid_oauth_state", "invalid oauth state")
+	}
+	s.db.WithContext(ctx).Delete(&state)
+	if state.ExpiresAt.Before(time.Now()) {
+		return nil, apperr.New(apperr.Invalid, "invalid_oauth_state", "invalid oauth state")
+	}
+
+	rawIDToken, err := provider.Exchange(req.Code, state.CodeVerifier)
+	if err != nil {
+		s.logger.Errorf("func: OAuthLogin, operation: provider.Exchange, err: %s", err.Error())
+		return nil, apperr.New(apperr.Unauthenticated, "oauth_exchange_failed", "failed to exchange code")
+	}
+
+	claims, err := provider.VerifyIDToken(rawIDToken, state.Nonce)
+	if err != nil {
+		s.logger.Errorf("func: OAuthLogin, operation: provider.VerifyIDToken, err: %s", err.Error())
+		return nil, err
+	}
+
+	user, err := s.userForIdentity(ctx, req.Provider, claims)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		return nil, apperr.New(apperr.Forbidden, "user_inactive", "user is not active")
+	}
+
+	challenge, err := s.twoFactorChallenge(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
+
+	pair, err := s.tokens.StartSession(user.ID, session.DeviceInfo{
+		Name:      req.DeviceName,
+		UserAgent: req.UserAgent,
+		IP:        req.IP,
+	})
+	if err != nil {
+		s.logger.Errorf("func: OAuthLogin, operation: s.tokens.StartSession, err: %s", err.Error())
+		return nil, err
+	}
+	s.record(ctx, audit.Event{
+		Actor:      audit.Actor{UserID: user.ID, IP: req.IP, UserAgent: req.UserAgent},
+		Action:     audit.ActionLogin,
+		TargetType: audit.TargetSession,
+		TargetID:   pair.SessionID,
+	})
+
+	return &LoginResponse{
+		UserData:     s.userData(user),
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	}, nil
+}
+
+// userForIdentity returns the user linked to the provider identity, linking
+// an existing user by verified email or creating a new user when needed. An
+// unverified signup with the email is handed over to the provider identity.
+func (s *authApi) userForIdentity(ctx context.Context, provider string, claims *oidc.Claims) (*users.User, error) {
+	now := time.Now()
+
+	var identity session.ProviderIdentity
+	s.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity)
+	if identity.ID != 0 {
+		var user users.User
+		if err := s.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", identity.UserID).First(&user).Error; err != nil {
+			return nil, helper.ErrNotFound
+		}
+		s.db.WithContext(ctx).Model(&identity).Update("last_login_at", now)
+		return &user, nil
+	}
+
+	// Only a verified email is trusted to link or create an account
+	email := strings.TrimSpace(strings.ToLower(claims.Email))
+	if !claims.EmailVerified || !helper.ValidEmail(email) {
+		return nil, apperr.New(apperr.Unauthenticated, "unverified_provider_email", "provider did not return a verified email")
+	}
+
+	var user users.User
+	takenOver := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tx.Where("email = ?", email).First(&user)
+		if user.ID != 0 && user.DeletedAt != nil {
+			return apperr.New(apperr.Forbidden, "user_inactive", "user is not active")
+		}
+
+		if user.ID == 0 {
+			username, err := s.uniqueUsername(tx, email)
+			if err != nil {
+				return err
+			}
+			user = users.User{
+				Email:         email,
+				Username:      &username,
+				FirstName:     claims.GivenName,
+				LastName:      claims.FamilyName,
+				VerifiedEmail: true,
+				Active:        true,
+				Role:          "user",
+			}
+			if err := tx.Omit("UpdatedAt").Create(&user).Error; err != nil {
+				return err
+			}
+		} else if !user.VerifiedEmail {
+			// The provider verified the address, so the pending signup is
+			// verified too. Whoever signed up never proved they own it, so
+			// their password, pending links and sessions go, or they could
+			// pre-register the address and keep access to the account
+			user.VerifiedEmail = true
+			user.Active = true
+			user.Password = ""
+			if err := tx.Save(&user).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ? AND consumed_at IS NULL", user.ID).Delete(&session.OneTimeToken{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&session.Session{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+			takenOver = true
+		}
+
+		return tx.Create(&session.ProviderIdentity{
+			Provider:    provider,
+			Subject:     claims.Subject,
+			UserID:      user.ID,
+			Email:       email,
+			LastLoginAt: &now,
+		}).Error
+	})
+	if err != nil {
+		s.logger.Errorf("func: userForIdentity, operation: s.db.Transaction, err: %s", err.Error())
+		return nil, err
+	}
+	if takenOver {
+		session.ForgetUser(user.ID)
+	}
+
+	return &user, nil
+}
+
+func (s *authApi) uniqueUsername(tx *gorm.DB, email string) (string, error) {
+	base := usernameCleaner.ReplaceAllString(strings.Split(email, "@")[0], "")
+	if base == "" {
+		base = "user"
+	}
+
+	username := base
+	for i := 0; i < 5; i++ {
+		var count int64
+		tx.Model(&users.User{}).Where("username = ?", username).Count(&count)
+		if count == 0 {
+			return username, nil
+		}
+
+		suffix, err := session.RandomToken(3)
+		if err != nil {
+			return "", err
+		}
+		username = base + "-" + strings.ToLower(usernameCleaner.ReplaceAllString(suffix, ""))
+	}
+
+	return "", fmt.Errorf("failed to generate username")
+}
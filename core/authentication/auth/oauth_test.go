@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/authentication/oidc"
+	"vezhguesi/core/authentication/oidc/oidctest"
+	"vezhguesi/core/db/dbtest"
+	"vezhguesi/core/logging"
+	"vezhguesi/core/users"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Someone signing up with another person's email must not keep access once
+// that person logs in through a provider verifying the email.
+func TestOAuthLoginTakesOverUnverifiedSignup(t *testing.T) {
+	conn := dbtest.Open(t)
+	issuer := oidctest.NewIssuer(t)
+	api := NewAuthApi(conn, dbtest.SecretKey, "http://localhost", logging.Logger("auth"),
+		session.NewTokenIssuer(conn, dbtest.SecretKey), []oidc.Client{oidc.NewClient(issuer.Provider("fake"), nil)}, nil)
+
+	email := fmt.Sprintf("takeover-%d@example.com", time.Now().UnixNano())
+	const squatterPassword = "squatter-password"
+	hash, err := bcrypt.GenerateFromPassword([]byte(squatterPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	squatter := users.User{Email: email, Password: string(hash), Role: "user"}
+	if err := conn.Omit("UpdatedAt").Create(&squatter).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Where("user_id = ?", squatter.ID).Delete(&session.ProviderIdentity{})
+		conn.Where("user_id = ?", squatter.ID).Delete(&session.OneTimeToken{})
+		conn.Where("session_id IN (?)", conn.Model(&session.Session{}).Select("id").Where("user_id = ?", squatter.ID)).Delete(&session.RefreshToken{})
+		conn.Where("user_id = ?", squatter.ID).Delete(&session.Session{})
+		conn.Where("email = ?", email).Delete(&session.LoginAttempt{})
+		conn.Delete(&users.User{}, squatter.ID)
+	})
+
+	if _, err := session.IssueOneTimeToken(conn, session.PurposeVerifyEmail, squatter.ID, email, "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	squatterSession := session.Session{
+		UserID:       uint(squatter.ID),
+		SessionToken: fmt.Sprintf("squatter-%d", squatter.ID),
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+	if err := conn.Create(&squatterSession).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	authorize, err := api.OAuthAuthorize(context.Background(), &OAuthAuthorizeRequest{Provider: "fake"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := issuer.Authorize(t, authorize.AuthorizationURL, jwt.MapClaims{
+		"sub":            "owner",
+		"email":          email,
+		"email_verified": true,
+	})
+	login, err := api.OAuthLogin(context.Background(), &OAuthLoginRequest{Provider: "fake", Code: code, State: authorize.State})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.Token == "" {
+		t.Fatal("the provider login did not start a session")
+	}
+
+	var user users.User
+	if err := conn.First(&user, squatter.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Password != "" {
+		t.Error("the password of the signup was kept")
+	}
+	if !user.VerifiedEmail || !user.Active {
+		t.Error("the account was not verified and activated")
+	}
+
+	var pending int64
+	conn.Model(&session.OneTimeToken{}).Where("user_id = ? AND consumed_at IS NULL", user.ID).Count(&pending)
+	if pending != 0 {
+		t.Errorf("%d one-time tokens of the signup are still pending", pending)
+	}
+	if err := conn.First(&squatterSession, squatterSession.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if squatterSession.RevokedAt == nil {
+		t.Error("the session of the signup was not revoked")
+	}
+
+	if _, err := api.Login(context.Background(), &LoginRequest{Email: email, Password: squatterPassword}); err == nil {
+		t.Error("the password of the signup still logs in")
+	}
+}
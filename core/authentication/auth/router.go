@@ -1,18 +1,31 @@
 package auth
 
 import (
+	"vezhguesi/core/middleware"
+
 	"github.com/gofiber/fiber/v2"
 )
 
-func RegisterRoutes(router fiber.Router, authHttpApi AuthHTTPTransport, authMiddleware func(c *fiber.Ctx) error, sessionMiddleware func(c *fiber.Ctx) error) {
+func RegisterRoutes(router fiber.Router, authHttpApi AuthHTTPTransport, authMiddleware func(c *fiber.Ctx) error) {
 	authRoutes := router.Group("/auth")
 	// Public routes
 	authRoutes.Post("", authHttpApi.Signup)
 	authRoutes.Get("/verify-signup/:token", authHttpApi.VerifySignup)
 	authRoutes.Post("/login", authHttpApi.Login)
+	authRoutes.Post("/login/2fa", authHttpApi.LoginTwoFactor)
+	authRoutes.Post("/login/2fa/setup", authHttpApi.LoginTwoFactorSetup)
 	authRoutes.Post("/forgot-password", authHttpApi.ForgotPassword)
 	authRoutes.Put("/reset-password/:token", authHttpApi.ResetPassword)
+	authRoutes.Post("/refresh", authHttpApi.Refresh)
+	authRoutes.Get("/oauth/:provider/authorize", authHttpApi.OAuthAuthorize)
+	authRoutes.Post("/oauth/:provider/callback", authHttpApi.OAuthLogin)
 	// Protected routes
-	authRoutes.Use(sessionMiddleware)
-	authRoutes.Put("/update", authMiddleware, authHttpApi.UpdateUser)
+	authRoutes.Post("/logout", authMiddleware, authHttpApi.Logout)
+	authRoutes.Get("/sessions", authMiddleware, authHttpApi.GetSessions)
+	authRoutes.Delete("/sessions/:id", authMiddleware, authHttpApi.RevokeSession)
+	authRoutes.Put("/update", authMiddleware, middleware.DenyImpersonation, authHttpApi.UpdateUser)
+	authRoutes.Post("/2fa/enroll", authMiddleware, middleware.DenyImpersonation, authHttpApi.EnrollTwoFactor)
+	authRoutes.Post("/2fa/confirm", authMiddleware, middleware.DenyImpersonation, authHttpApi.ConfirmTwoFactor)
+	authRoutes.Post("/2fa/disable", authMiddleware, middleware.DenyImpersonation, authHttpApi.DisableTwoFactor)
+	authRoutes.Post("/2fa/recovery-codes", authMiddleware, middleware.DenyImpersonation, authHttpApi.RegenerateRecoveryCodes)
 }
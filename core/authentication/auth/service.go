@@ -1,40 +1,67 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
+	"vezhguesi/core/apperr"
 	session "vezhguesi/core/authentication"
+	"vezhguesi/core/audit"
+	"vezhguesi/core/authentication/oidc"
+	"vezhguesi/core/mailer"
+	"vezhguesi/core/storage"
 	"vezhguesi/core/users"
+	"vezhguesi/core/validation"
 	helper "vezhguesi/helper"
 
 	"github.com/gofiber/fiber/v2/log"
-	"github.com/golang-jwt/jwt/v4"
-	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
-	"gopkg.in/gomail.v2"
 	"gorm.io/gorm"
 )
 
+const (
+	verifyEmailTokenTTL   = 24 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+)
+
 type authApi struct {
 	db *gorm.DB
 	secretKey string
-	mailDialer *gomail.Dialer // Use gomail Dialer
 	uiAppUrl string
 	logger log.AllLogger
+	tokens session.TokenIssuer
+	oidcProviders map[string]oidc.Client
+	blobs storage.BlobStore
 }
 
 type AuthApi interface{
-	Signup(req *SignupRequest) (*SignupResponse, error)
-	VerifySignup(req *SignupVerifyRequest) (*StatusResponse, error)
-	Login(req *LoginRequest) (*LoginResponse, error)
-	UpdateUser(req *UpdateUserRequest) (*UserData, error)
-	ForgotPassword(req *ForgotPasswordRequest) (*StatusResponse, error)
-	ResetPassword(req *ResetPasswordRequest) (*StatusResponse, error)
+	Signup(ctx context.Context, req *SignupRequest) (*SignupResponse, error)
+	VerifySignup(ctx context.Context, req *SignupVerifyRequest) (*StatusResponse, error)
+	Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error)
+	UpdateUser(ctx context.Context, req *UpdateUserRequest) (*UserData, error)
+	ForgotPassword(ctx context.Context, req *ForgotPasswordRequest) (*StatusResponse, error)
+	ResetPassword(ctx context.Context, req *ResetPasswordRequest) (*StatusResponse, error)
+	Refresh(ctx context.Context, req *RefreshRequest) (*TokenResponse, error)
+	Logout(ctx context.Context, req *SessionRequest) (*StatusResponse, error)
+	GetSessions(ctx context.Context, req *SessionRequest) (*SessionsResponse, error)
+	RevokeSession(ctx context.Context, req *SessionRequest) (*StatusResponse, error)
+	LoginTwoFactor(ctx context.Context, req *TwoFactorLoginRequest) (*LoginResponse, error)
+	LoginTwoFactorSetup(ctx context.Context, req *TwoFactorChallengeRequest) (*TwoFactorEnrollResponse, error)
+	EnrollTwoFactor(ctx context.Context, req *TwoFactorRequest) (*TwoFactorEnrollResponse, error)
+	ConfirmTwoFactor(ctx context.Context, req *TwoFactorRequest) (*RecoveryCodesResponse, error)
+	DisableTwoFactor(ctx context.Context, req *TwoFactorRequest) (*StatusResponse, error)
+	RegenerateRecoveryCodes(ctx context.Context, req *TwoFactorRequest) (*RecoveryCodesResponse, error)
+	OAuthAuthorize(ctx context.Context, req *OAuthAuthorizeRequest) (*OAuthAuthorizeResponse, error)
+	OAuthLogin(ctx context.Context, req *OAuthLoginRequest) (*LoginResponse, error)
 }
 
-func NewAuthApi(db *gorm.DB, secretKey string, dialer *gomail.Dialer, uiAppUrl string, logger log.AllLogger) AuthApi {
-	return &authApi{db: db, secretKey: secretKey, mailDialer: dialer, uiAppUrl: uiAppUrl, logger: logger}
+func NewAuthApi(db *gorm.DB, secretKey string, uiAppUrl string, logger log.AllLogger, tokens session.TokenIssuer, oidcProviders []oidc.Client, blobs storage.BlobStore) AuthApi {
+	providers := make(map[string]oidc.Client, len(oidcProviders))
+	for _, provider := range oidcProviders {
+		providers[provider.Name()] = provider
+	}
+	return &authApi{db: db, secretKey: secretKey, uiAppUrl: uiAppUrl, logger: logger, tokens: tokens, oidcProviders: providers, blobs: blobs}
 }
 
 // @Summary      	Signup
@@ -45,7 +72,7 @@ func NewAuthApi(db *gorm.DB, secretKey string, dialer *gomail.Dialer, uiAppUrl s
 // @Param			SignupRequest	body		SignupRequest	true	"SignupRequest"
 // @Success			200					{object}	SignupResponse
 // @Router			/api/auth/	[POST]
-func (s *authApi) Signup(req *SignupRequest) (*SignupResponse, error) {
+func (s *authApi) Signup(ctx context.Context, req *SignupRequest) (*SignupResponse, error) {
 	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
 	req.Username = strings.TrimSpace(req.Username)
 	req.FirstName = strings.TrimSpace(req.FirstName)
@@ -53,41 +80,23 @@ func (s *authApi) Signup(req *SignupRequest) (*SignupResponse, error) {
 	req.Password = strings.TrimSpace(req.Password)
 	req.ConfirmPassword = strings.TrimSpace(req.ConfirmPassword)
 
-	if req.Email == "" || req.Password == "" || req.ConfirmPassword == "" {
-		return nil, fmt.Errorf("email and password are required")
-	}
-
-	if !helper.ValidEmail(req.Email) {
-		return nil, fmt.Errorf("invalid email")
-	}
-
-	if req.Password != req.ConfirmPassword {
-		return nil, fmt.Errorf("password and confirm password do not match")
-	}
-
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
-	claims["exp"] = time.Now().Add(time.Hour * 12).Unix()
-	claims["email"] = req.Email
-
-	t, err := token.SignedString([]byte(s.secretKey))
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate token")
+	if err := validation.Struct(req); err != nil {
+		return nil, err
 	}
 
 	var user users.User 
-	_ = s.db.Where("email = ?", req.Email).First(&user)
+	_ = s.db.WithContext(ctx).Where("email = ?", req.Email).First(&user)
 	if user.ID > 0 {
 		if !user.VerifiedEmail {
-			return nil, fmt.Errorf("verify your email first")
+			return nil, apperr.New(apperr.Conflict, "email_not_verified", "verify your email first")
 		}
 
-		return nil, fmt.Errorf("email already in use")
+		return nil, apperr.New(apperr.Conflict, "email_in_use", "email already in use")
 	}
 
-	_ = s.db.Where("username = ?", req.Username).First(&user)
+	_ = s.db.WithContext(ctx).Where("username = ?", req.Username).First(&user)
 	if user.ID > 0 {
-		return nil, fmt.Errorf("username already in use")
+		return nil, apperr.New(apperr.Conflict, "username_in_use", "username already in use")
 	}
 
 	user.Email = req.Email
@@ -97,6 +106,7 @@ func (s *authApi) Signup(req *SignupRequest) (*SignupResponse, error) {
 	user.VerifiedEmail = false
 	user.Active = false
 	user.Role = "user"
+	user.Locale = mailer.SupportedLocale(req.Locale)
 
 	hashedPw, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -107,26 +117,39 @@ func (s *authApi) Signup(req *SignupRequest) (*SignupResponse, error) {
 	pwhs := string(hashedPw)
 	user.Password = pwhs
 
-	result := s.db.Omit("UpdatedAt").Create(&user)
-	if result.Error != nil {
-		s.logger.Errorf("func: Signup, operation: s.db.Omit('UpdatedAt').Create(&user), err: %s", result.Error)
-		return nil, result.Error
-	}
-
-	s.db.Model(users.User{Email: req.Email}).First(&user)
-
+	// The user, its verification token and the email are committed together
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("UpdatedAt").Create(&user).Error; err != nil {
+			s.logger.Errorf("func: Signup, operation: tx.Omit('UpdatedAt').Create(&user), err: %s", err)
+			return err
+		}
 
-	verifyLink := s.uiAppUrl + "/verify-signup/" + t
+		t, err := session.IssueOneTimeToken(tx, session.PurposeVerifyEmail, int(user.ID), user.Email, "", verifyEmailTokenTTL)
+		if err != nil {
+			s.logger.Errorf("func: Signup, operation: session.IssueOneTimeToken, err: %s", err.Error())
+			return fmt.Errorf("failed to generate token")
+		}
 
-	m := gomail.NewMessage()
-	m.SetHeader("From", "info@vezhguesi.com")
-	m.SetHeader("To", req.Email)
-	m.SetHeader("Subject", "Verify your email")
-	m.SetBody("text/html", fmt.Sprintf("Click on the link to verify your email: <a href=\"%s\">Click here</a>", verifyLink))
+		err = audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionSignup,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			After:      map[string]interface{}{"email": user.Email, "username": user.Username, "firstName": user.FirstName, "lastName": user.LastName},
+		})
+		if err != nil {
+			return err
+		}
 
-	if err := s.mailDialer.DialAndSend(m); err != nil {
-		s.logger.Errorf("func: Signup, operation: s.mailDialer.DialAndSend(m), err: %s", err.Error())
-		return nil, fmt.Errorf("failed to send email")
+		return mailer.Enqueue(tx, mailer.Message{
+			To:       user.Email,
+			Template: mailer.TemplateVerifyEmail,
+			Locale:   user.Locale,
+			Data:     map[string]string{"Name": user.FirstName, "Link": s.uiAppUrl + "/verify-signup/" + t},
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &SignupResponse{
@@ -136,45 +159,48 @@ func (s *authApi) Signup(req *SignupRequest) (*SignupResponse, error) {
 }
 
 // @Summary      	VerifySignup
-// @Description	Validates token in param, if token parses valid then user will be verified and be updated in DB.
+// @Description	Redeems the single-use verification token in param, if it is valid and unused then user will be verified and be updated in DB.
 // @Tags			Auth
 // @Accept			json
 // @Produce			json
 // @Param			token				path		string			true	"Token"
 // @Success			200					{object}	StatusResponse
 // @Router			/api/auth/verify-signup/{token}	[GET]
-func (s *authApi) VerifySignup(req *SignupVerifyRequest) (res *StatusResponse, err error) {
+func (s *authApi) VerifySignup(ctx context.Context, req *SignupVerifyRequest) (res *StatusResponse, err error) {
 	req.Token = strings.TrimSpace(req.Token)
 	
 	if req.Token == "" {
-		return nil, fmt.Errorf("token is required")
+		return nil, apperr.New(apperr.Invalid, "missing_token", "token is required")
 	}
 
-	//  Parse and validate token expiration
-	claims := jwt.MapClaims{}
-	_, err = jwt.ParseWithClaims(req.Token, claims, func(token *jwt.Token) (interface{}, error){
-		return []byte(s.secretKey), nil
-	})
-	if err != nil {
-		s.logger.Errorf("func: VerifySignup, operation: jwt.ParseWithClaims(req.Token, claims, func(token *jwt.Token) (interface{}, error){return []byte(s.secretKey), nil}), err: %s", err.Error())
-		return nil, fmt.Errorf("invalid token")
-	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		token, err := session.ConsumeOneTimeToken(tx, session.PurposeVerifyEmail, req.Token)
+		if err != nil {
+			return err
+		}
 
-	email := fmt.Sprintf("%v", claims["email"])
+		var user users.User
+		tx.Where("id = ? AND email = ?", token.UserID, token.Email).First(&user)
+		if user.ID == 0 {
+			return helper.ErrNotFound
+		}
 
-	// find user by email
-	var user users.User
-	s.db.Where("email = ?", email).First(&user)
-	if user.ID == 0 {
-		return nil, helper.ErrNotFound
-	}
+		user.VerifiedEmail = true
+		user.Active = true
+		if err := tx.Save(&user).Error; err != nil {
+			s.logger.Errorf("func: VerifySignup, operation: tx.Save(&user), err: %s", err.Error())
+			return err
+		}
 
-	user.VerifiedEmail = true
-	user.Active = true
-	result := s.db.Save(&user)
-	if result.Error != nil {
-		s.logger.Errorf("func: VerifySignup, operation: s.db.Save(&user), err: %s", result.Error.Error())
-		return nil, result.Error
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionVerifyEmail,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &StatusResponse{
@@ -183,83 +209,73 @@ func (s *authApi) VerifySignup(req *SignupVerifyRequest) (res *StatusResponse, e
 }
 
 // @Summary      	Login
-// @Description		Validates email and password in request, check if user exists in DB if not throw 404 otherwise compare the request password with hash, then check if user is active, then finds relationships of user with orgs and then generates a JWT token, and returns UserData, Orgs, and Token in response.
+// @Description		Validates email and password in request, rejects the attempt when the account or IP is throttled, returns the same error for an unknown email and a wrong password, then checks if user is verified and active, then asks for the second factor when two-factor authentication is enabled or required, otherwise starts a new session for the device and returns UserData with a short-lived access token and a refresh token.
 // @Tags			Auth
 // @Accept			json
 // @Produce			json
 // @Param			LoginRequest	body		LoginRequest	true	"LoginRequest"
 // @Success			200				{object}	LoginResponse
 // @Router			/api/auth/login			[POST]
-func (s *authApi) Login(req *LoginRequest) (res *LoginResponse, err error) {
+func (s *authApi) Login(ctx context.Context, req *LoginRequest) (res *LoginResponse, err error) {
 	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
 	req.Password = strings.TrimSpace(req.Password)
 
-	if req.Email == "" || req.Password == "" {
-		return nil, fmt.Errorf("email and password are required")
+	if err := validation.Struct(req); err != nil {
+		return nil, err
 	}
 
-	if !helper.ValidEmail(req.Email) {
-		return nil, fmt.Errorf("invalid email")
+	if err := s.checkLoginThrottle(ctx, req.Email, req.IP); err != nil {
+		return nil, err
 	}
 
 	var user users.User
-	s.db.Where("email = ?", req.Email).First(&user)
+	s.db.WithContext(ctx).Where("email = ? AND deleted_at IS NULL", req.Email).First(&user)
 	if user.ID == 0 {
-		fmt.Println("err", "email not found")
-		return nil, helper.ErrNotFound
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(req.Password))
+		s.recordLoginFailure(ctx, req.Email, audit.Actor{IP: req.IP, UserAgent: req.UserAgent}, nil)
+		return nil, ErrInvalidCredentials
 	}
 
-	if !user.VerifiedEmail {
-		return nil, fmt.Errorf("email not verified")
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		s.recordLoginFailure(ctx, req.Email, audit.Actor{IP: req.IP, UserAgent: req.UserAgent}, &user)
+		return nil, ErrInvalidCredentials
 	}
 
-	if !user.Active {
-		return nil, fmt.Errorf("user is not active")
+	// Only reveal the account state once the password proved ownership
+	if !user.VerifiedEmail {
+		return nil, apperr.New(apperr.Forbidden, "email_not_verified", "email not verified")
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, helper.ErrNotFound
+	if !user.Active {
+		return nil, apperr.New(apperr.Forbidden, "user_inactive", "user is not active")
 	}
 
-	// Invalidate existing session
-	s.db.Where("user_id = ?", user.ID).Delete(&session.Session{})
-
-	// Create new session
-	sessionToken := uuid.New().String()
-	session := session.Session{
-		UserID:      uint(user.ID),
-		SessionToken: sessionToken,
-		CreatedAt:   time.Now(),
-		ExpiresAt:   time.Now().Add(24 * time.Hour), // 24-hour session
+	// Ask for the second factor (or its enrollment) before issuing tokens
+	challenge, err := s.twoFactorChallenge(ctx, user.ID)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.db.Create(&session).Error; err != nil {
-		return nil, fmt.Errorf("failed to create session")
+	if challenge != nil {
+		return challenge, nil
 	}
 
-	// Generate JWT Token with expiration
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
-	claims["userId"] = user.ID
-	claims["exp"] = time.Now().Add(time.Hour * 24).Unix()
-
-	t, err := token.SignedString([]byte(s.secretKey))
+	// Start a new session for this device, other devices stay logged in
+	pair, err := s.tokens.StartSession(user.ID, session.DeviceInfo{
+		Name:      req.DeviceName,
+		UserAgent: req.UserAgent,
+		IP:        req.IP,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate token")
-	}
-
-	userData := UserData{
-		ID: user.ID,
-		Email: user.Email,
-		Username: *user.Username,
-		FirstName: user.FirstName,
-		LastName: user.LastName,
-		Role: user.Role,
+		s.logger.Errorf("func: Login, operation: s.tokens.StartSession, err: %s", err.Error())
+		return nil, err
 	}
+	s.recordLoginSuccess(ctx, req.Email, audit.Actor{UserID: user.ID, IP: req.IP, UserAgent: req.UserAgent}, pair.SessionID)
 
 	return &LoginResponse{
-		UserData: &userData,
-		Token: t,
-		SessionToken: sessionToken, // Include session token in response
+		UserData: s.userData(&user),
+		Token: pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn: pair.ExpiresIn,
 	}, nil
 }
 
@@ -272,86 +288,93 @@ func (s *authApi) Login(req *LoginRequest) (res *LoginResponse, err error) {
 // @Param			UpdateUserRequest	body		UpdateUserRequest	true	"UpdateUserRequest"
 // @Success			200				{object}	UserData
 // @Router			/api/auth/update			[PUT]
-func (s *authApi) UpdateUser(req *UpdateUserRequest) (res *UserData, err error) {
-	if req.UserID == 0 {
-		return nil, fmt.Errorf("user ID is required")
-	}
-	if req.FirstName == "" || req.LastName == "" || req.Username == "" {
-		return nil, fmt.Errorf("first name, last name and username are required")
+func (s *authApi) UpdateUser(ctx context.Context, req *UpdateUserRequest) (res *UserData, err error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
 	}
 
 	var user users.User
-	result := s.db.First(&user, req.UserID)
+	result := s.db.WithContext(ctx).First(&user, req.UserID)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
+	before := userDataFrom(&user)
 	user.FirstName = req.FirstName
 	user.LastName = req.LastName
 	user.Username = &req.Username
 
-	result = s.db.Save(&user)
-	if result.Error != nil {
-		return nil, result.Error
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionUserUpdated,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Before:     before,
+			After:      userDataFrom(&user),
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return &UserData{
-		ID: user.ID,
-		FirstName: user.FirstName,
-		LastName: user.LastName,
-		Username: *user.Username,
-		Email: user.Email,
-		Role: user.Role,
-		AvatarImgUrl: user.AvatarImgKey,
-	}, nil
+	return s.userData(&user), nil
 }
 
 // @Summary      	ForgotPassword
-// @Description		Sends email with reset password link to user.
+// @Description		Sends email with reset password link to user. Succeeds for unknown emails too.
 // @Tags			Auth
 // @Accept			json
 // @Produce			json
 // @Param			ForgotPasswordRequest	body		ForgotPasswordRequest	true	"ForgotPasswordRequest"
 // @Success			200				{object}	StatusResponse
 // @Router			/api/auth/forgot-password			[POST]
-func (s *authApi) ForgotPassword(req *ForgotPasswordRequest) (res *StatusResponse, err error) {
+func (s *authApi) ForgotPassword(ctx context.Context, req *ForgotPasswordRequest) (res *StatusResponse, err error) {
 	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
 
-	if req.Email == "" {
-		return nil, fmt.Errorf("email is required")
-	}
-
-	if !helper.ValidEmail(req.Email) {
-		return nil, fmt.Errorf("invalid email")
+	if err := validation.Struct(req); err != nil {
+		return nil, err
 	}
 
+	// An unknown email gets the same answer as a known one, so the form
+	// doesn't reveal which accounts exist
 	var user users.User
-	s.db.Where("email = ?", req.Email).First(&user)
+	s.db.WithContext(ctx).Where("email = ? AND deleted_at IS NULL", req.Email).Limit(1).Find(&user)
 	if user.ID == 0 {
-		return nil, helper.ErrNotFound
+		return &StatusResponse{
+			Status: true,
+		}, nil
 	}
 
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
-	claims["exp"] = time.Now().Add(time.Hour * 12).Unix()
-	claims["email"] = req.Email
-
-	t, err := token.SignedString([]byte(s.secretKey))
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate token")
-	}
-
-	verifyLink := s.uiAppUrl + "/reset-password/" + t
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		t, err := session.IssueOneTimeToken(tx, session.PurposeResetPassword, int(user.ID), user.Email, "", resetPasswordTokenTTL)
+		if err != nil {
+			s.logger.Errorf("func: ForgotPassword, operation: session.IssueOneTimeToken, err: %s", err.Error())
+			return fmt.Errorf("failed to generate token")
+		}
 
-	m := gomail.NewMessage()
-	m.SetHeader("From", "info@vezhguesi.com")
-	m.SetHeader("To", req.Email)
-	m.SetHeader("Subject", "Reset your password")
-	m.SetBody("text/html", fmt.Sprintf("Click on the link to reset your password: <a href=\"%s\">Click here</a>", verifyLink))
+		err = audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionPasswordResetRequested,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+		})
+		if err != nil {
+			return err
+		}
 
-	if err := s.mailDialer.DialAndSend(m); err != nil {
-		s.logger.Errorf("func: Signup, operation: s.mailDialer.DialAndSend(m), err: %s", err.Error())
-		return nil, fmt.Errorf("failed to send email")
+		return mailer.Enqueue(tx, mailer.Message{
+			To:       user.Email,
+			Template: mailer.TemplateResetPassword,
+			Locale:   user.Locale,
+			Data:     map[string]string{"Name": user.FirstName, "Link": s.uiAppUrl + "/reset-password/" + t},
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &StatusResponse{
@@ -361,7 +384,7 @@ func (s *authApi) ForgotPassword(req *ForgotPasswordRequest) (res *StatusRespons
 
 
 // @Summary      	ResetPassword
-// @Description		Validates token, new password, and confirm new password, checks if user exists in DB then it updates the password in DB.
+// @Description		Validates new password and confirm new password, redeems the single-use reset token, updates the password in DB and logs the user out of every session.
 // @Tags			Auth
 // @Accept			json
 // @Produce			json
@@ -369,49 +392,183 @@ func (s *authApi) ForgotPassword(req *ForgotPasswordRequest) (res *StatusRespons
 // @Param			ResetPasswordRequest	body		ResetPasswordRequest	true	"ResetPasswordRequest"
 // @Success			200					{object}	StatusResponse
 // @Router			/api/auth/reset-password/{token}	[PUT]
-func (s *authApi) ResetPassword(req *ResetPasswordRequest) (res *StatusResponse, err error) {
+func (s *authApi) ResetPassword(ctx context.Context, req *ResetPasswordRequest) (res *StatusResponse, err error) {
 	req.Token = strings.TrimSpace(req.Token)
 	req.NewPassword = strings.TrimSpace(req.NewPassword)
 	req.ConfirmNewPassword = strings.TrimSpace(req.ConfirmNewPassword)
-	if req.Token == "" || req.NewPassword == "" || req.ConfirmNewPassword == "" {
-		return nil, fmt.Errorf("token, new password and confirm new password are required")
+	if err := validation.Struct(req); err != nil {
+		return nil, err
 	}
 
-	if req.NewPassword != req.ConfirmNewPassword {
-		return nil, fmt.Errorf("new password and confirm new password do not match")
+	pwh, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password")
 	}
 
-	claims := jwt.MapClaims{}
-	_, err = jwt.ParseWithClaims(req.Token, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.secretKey), nil
+	var user users.User
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		token, err := session.ConsumeOneTimeToken(tx, session.PurposeResetPassword, req.Token)
+		if err != nil {
+			return err
+		}
+
+		tx.Where("id = ? AND email = ?", token.UserID, token.Email).First(&user)
+		if user.ID == 0 {
+			return helper.ErrNotFound
+		}
+
+		user.Password = string(pwh)
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionPasswordReset,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+		})
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Whoever knew the old password must not stay logged in
+	if err := s.tokens.RevokeAllSessions(int(user.ID), 0); err != nil {
+		s.logger.Errorf("func: ResetPassword, operation: s.tokens.RevokeAllSessions, err: %s", err.Error())
+		return nil, err
+	}
+
+	return &StatusResponse{
+		Status: true,
+	}, nil
+}
+
+// @Summary      	Refresh
+// @Description		Rotates the refresh token and returns a new access/refresh token pair. Reusing an already rotated refresh token revokes the whole session.
+// @Tags			Auth
+// @Accept			json
+// @Produce			json
+// @Param			RefreshRequest	body		RefreshRequest	true	"RefreshRequest"
+// @Success			200				{object}	TokenResponse
+// @Router			/api/auth/refresh			[POST]
+func (s *authApi) Refresh(ctx context.Context, req *RefreshRequest) (res *TokenResponse, err error) {
+	req.RefreshToken = strings.TrimSpace(req.RefreshToken)
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
 
+	pair, err := s.tokens.Refresh(req.RefreshToken)
 	if err != nil {
-		fmt.Println("ParseWithClaims", err)
+		if err == session.ErrRefreshTokenReused {
+			s.logger.Warnf("func: Refresh, refresh token reuse detected")
+		}
 		return nil, err
 	}
 
-	email := fmt.Sprintf("%v", claims["email"])
+	return &TokenResponse{
+		Token: pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn: pair.ExpiresIn,
+	}, nil
+}
 
-	var user users.User
-	s.db.Where("email = ?", email).First(&user)
-	if user.ID == 0 {
-		return nil, helper.ErrNotFound
+// @Summary      	Logout
+// @Description		Revokes the current session.
+// @Tags			Auth
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Success			200				{object}	StatusResponse
+// @Router			/api/auth/logout			[POST]
+func (s *authApi) Logout(ctx context.Context, req *SessionRequest) (res *StatusResponse, err error) {
+	if req.UserID == 0 || req.CurrentSessionID == 0 {
+		return nil, apperr.New(apperr.Invalid, "missing_session", "missing session")
 	}
 
-	pwh, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err := s.tokens.RevokeSession(req.UserID, req.CurrentSessionID); err != nil {
+		return nil, err
+	}
+	s.record(ctx, audit.Event{
+		Actor:      req.Actor,
+		Action:     audit.ActionLogout,
+		TargetType: audit.TargetSession,
+		TargetID:   req.CurrentSessionID,
+	})
+
+	return &StatusResponse{
+		Status: true,
+	}, nil
+}
+
+// @Summary      	GetSessions
+// @Description		Lists the live sessions of the user across devices.
+// @Tags			Auth
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Success			200				{object}	SessionsResponse
+// @Router			/api/auth/sessions			[GET]
+func (s *authApi) GetSessions(ctx context.Context, req *SessionRequest) (res *SessionsResponse, err error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.tokens.ListSessions(req.UserID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to hash password")
+		return nil, err
 	}
-	pwhs := string(pwh)
-	user.Password = pwhs
 
-	result := s.db.Save(&user)
-	if result.Error != nil {
-		return nil, result.Error
+	res = &SessionsResponse{Sessions: make([]SessionData, 0, len(sessions))}
+	for _, sess := range sessions {
+		res.Sessions = append(res.Sessions, SessionData{
+			ID: sess.ID,
+			DeviceName: sess.DeviceName,
+			UserAgent: sess.UserAgent,
+			IP: sess.IP,
+			Current: sess.ID == req.CurrentSessionID,
+			CreatedAt: sess.CreatedAt,
+			LastUsedAt: sess.LastUsedAt,
+			ExpiresAt: sess.ExpiresAt,
+		})
 	}
 
+	return res, nil
+}
+
+// @Summary      	RevokeSession
+// @Description		Revokes one of the user's sessions, e.g. a lost device.
+// @Tags			Auth
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			id				path		int		true	"Session ID"
+// @Success			200				{object}	StatusResponse
+// @Router			/api/auth/sessions/{id}			[DELETE]
+func (s *authApi) RevokeSession(ctx context.Context, req *SessionRequest) (res *StatusResponse, err error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	if req.SessionID == 0 {
+		return nil, helper.ErrMissingId
+	}
+
+	if err := s.tokens.RevokeSession(req.UserID, req.SessionID); err != nil {
+		return nil, err
+	}
+	s.record(ctx, audit.Event{
+		Actor:      req.Actor,
+		Action:     audit.ActionSessionRevoked,
+		TargetType: audit.TargetSession,
+		TargetID:   req.SessionID,
+	})
+
 	return &StatusResponse{
 		Status: true,
 	}, nil
-}
\ No newline at end of file
+}
+
+// record writes an audit event that isn't part of a transaction. A failure is
+// logged rather than failing the already completed action.
+func (s *authApi) record(ctx context.Context, event audit.Event) {
+	if err := audit.Record(s.db.WithContext(ctx), event); err != nil {
+		s.logger.Errorf("func: record, operation: audit.Record, action: %s, err: %s", event.Action, err.Error())
+	}
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/mailer"
+	"vezhguesi/core/users"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// loginAttemptWindow is how far back failed attempts are counted
+	loginAttemptWindow = 15 * time.Minute
+	// loginDelayAfter failures in a row each double the wait before the next
+	// attempt for the account is accepted, up to loginMaxDelay
+	loginDelayAfter = 3
+	loginMaxDelay   = 30 * time.Second
+	// accountLockoutAfter failures lock the account for accountLockoutDuration
+	accountLockoutAfter    = 10
+	accountLockoutDuration = 15 * time.Minute
+	// ipLockoutAfter failures from one IP, over any accounts, block the IP
+	ipLockoutAfter = 50
+)
+
+var (
+	// ErrInvalidCredentials is returned for an unknown email and a wrong
+	// password alike, so login doesn't reveal which accounts exist.
+	ErrInvalidCredentials = apperr.New(apperr.Unauthenticated, "invalid_credentials", "incorrect email or password")
+	ErrTooManyAttempts    = apperr.New(apperr.TooManyRequests, "too_many_attempts", "too many login attempts, try again later")
+)
+
+// dummyPasswordHash is compared against when the email is unknown so the
+// response takes as long as for an existing account.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// checkLoginThrottle rejects the attempt when the account is locked, the IP
+// has too many recent failures, or the progressive delay since the last
+// failure for the account hasn't passed yet.
+func (s *authApi) checkLoginThrottle(ctx context.Context, email, ip string) error {
+	now := time.Now()
+
+	var locked int64
+	s.db.WithContext(ctx).Model(&session.AccountLockout{}).Where("email = ? AND locked_until > ?", email, now).Count(&locked)
+	if locked > 0 {
+		return ErrTooManyAttempts
+	}
+
+	if ip != "" {
+		var ipFailures int64
+		s.db.WithContext(ctx).Model(&session.LoginAttempt{}).
+			Where("ip = ? AND success = ? AND created_at > ?", ip, false, now.Add(-loginAttemptWindow)).
+			Count(&ipFailures)
+		if ipFailures >= ipLockoutAfter {
+			return ErrTooManyAttempts
+		}
+	}
+
+	failures, lastFailure := s.recentFailures(ctx, email)
+	if failures >= loginDelayAfter {
+		delay := time.Second << (failures - loginDelayAfter)
+		if delay > loginMaxDelay || delay <= 0 {
+			delay = loginMaxDelay
+		}
+		if now.Before(lastFailure.Add(delay)) {
+			return ErrTooManyAttempts
+		}
+	}
+
+	return nil
+}
+
+// recordLoginFailure stores the failed attempt and locks the account once it
+// reaches accountLockoutAfter failures, notifying the owner by email. The
+// attempt is stored even when the client hangs up, so dropping the connection
+// doesn't get around the throttle.
+func (s *authApi) recordLoginFailure(ctx context.Context, email string, actor audit.Actor, user *users.User) {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.db.WithContext(ctx).Create(&session.LoginAttempt{Email: email, IP: actor.IP}).Error; err != nil {
+		s.logger.Errorf("func: recordLoginFailure, operation: s.db.Create(&attempt), err: %s", err.Error())
+		return
+	}
+
+	event := audit.Event{Actor: actor, Action: audit.ActionLoginFailed}
+	if user != nil && user.ID != 0 {
+		event.TargetType = audit.TargetUser
+		event.TargetID = user.ID
+	}
+	s.record(ctx, event)
+
+	failures, _ := s.recentFailures(ctx, email)
+	if failures < accountLockoutAfter {
+		return
+	}
+
+	lockout := session.AccountLockout{Email: email, LockedUntil: time.Now().Add(accountLockoutDuration)}
+	if err := s.db.WithContext(ctx).Create(&lockout).Error; err != nil {
+		s.logger.Errorf("func: recordLoginFailure, operation: s.db.Create(&lockout), err: %s", err.Error())
+		return
+	}
+	event.Action = audit.ActionAccountLocked
+	s.record(ctx, event)
+
+	if user != nil && user.ID != 0 {
+		err := mailer.Enqueue(s.db.WithContext(ctx), mailer.Message{
+			To:       user.Email,
+			Template: mailer.TemplateAccountLocked,
+			Locale:   user.Locale,
+			Data: map[string]string{
+				"Name":        user.FirstName,
+				"LockedUntil": lockout.LockedUntil.UTC().Format("2006-01-02 15:04 MST"),
+				"Link":        s.uiAppUrl + "/forgot-password",
+			},
+		})
+		if err != nil {
+			s.logger.Errorf("func: recordLoginFailure, operation: mailer.Enqueue, err: %s", err.Error())
+		}
+	}
+}
+
+// recordLoginSuccess clears the failure count of the account and audits the
+// new session.
+func (s *authApi) recordLoginSuccess(ctx context.Context, email string, actor audit.Actor, sessionID uint) {
+	s.record(ctx, audit.Event{
+		Actor:      actor,
+		Action:     audit.ActionLogin,
+		TargetType: audit.TargetSession,
+		TargetID:   sessionID,
+	})
+
+	if err := s.db.WithContext(ctx).Create(&session.LoginAttempt{Email: email, IP: actor.IP, Success: true}).Error; err != nil {
+		s.logger.Errorf("func: recordLoginSuccess, operation: s.db.Create(&attempt), err: %s", err.Error())
+	}
+	s.db.WithContext(ctx).Where("email = ? AND created_at < ?", email, time.Now().Add(-loginAttemptWindow)).Delete(&session.LoginAttempt{})
+}
+
+// recentFailures counts the failures of the account inside the window that
+// happened after its last successful login and its last lockout.
+func (s *authApi) recentFailures(ctx context.Context, email string) (count int, last time.Time) {
+	since := time.Now().Add(-loginAttemptWindow)
+
+	var lastSuccess session.LoginAttempt
+	s.db.WithContext(ctx).Where("email = ? AND success = ?", email, true).Order("created_at DESC").Limit(1).Find(&lastSuccess)
+	if lastSuccess.CreatedAt.After(since) {
+		since = lastSuccess.CreatedAt
+	}
+
+	var lastLockout session.AccountLockout
+	s.db.WithContext(ctx).Where("email = ?", email).Order("created_at DESC").Limit(1).Find(&lastLockout)
+	if lastLockout.CreatedAt.After(since) {
+		since = lastLockout.CreatedAt
+	}
+
+	var failures []session.LoginAttempt
+	s.db.WithContext(ctx).Where("email = ? AND success = ? AND created_at > ?", email, false, since).Order("created_at DESC").Find(&failures)
+	if len(failures) == 0 {
+		return 0, time.Time{}
+	}
+
+	return len(failures), failures[0].CreatedAt
+}
@@ -1,7 +1,10 @@
 package auth
 
 import (
+	"strconv"
+	"vezhguesi/core/audit"
 	"vezhguesi/core/middleware"
+	"vezhguesi/helper"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,6 +16,18 @@ type AuthHTTPTransport interface {
 	UpdateUser(c *fiber.Ctx) error
 	ForgotPassword(c *fiber.Ctx) error
 	ResetPassword(c *fiber.Ctx) error
+	Refresh(c *fiber.Ctx) error
+	Logout(c *fiber.Ctx) error
+	GetSessions(c *fiber.Ctx) error
+	RevokeSession(c *fiber.Ctx) error
+	LoginTwoFactor(c *fiber.Ctx) error
+	LoginTwoFactorSetup(c *fiber.Ctx) error
+	EnrollTwoFactor(c *fiber.Ctx) error
+	ConfirmTwoFactor(c *fiber.Ctx) error
+	DisableTwoFactor(c *fiber.Ctx) error
+	RegenerateRecoveryCodes(c *fiber.Ctx) error
+	OAuthAuthorize(c *fiber.Ctx) error
+	OAuthLogin(c *fiber.Ctx) error
 }
 
 type authHttpTransport struct {
@@ -26,12 +41,13 @@ func NewAuthHTTPTransport(authAPI AuthApi) AuthHTTPTransport {
 func (s *authHttpTransport) Signup(c *fiber.Ctx) error {
 	req := &SignupRequest{}
 	if err := c.BodyParser(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "Signup.c.BodyParser")
 	}
+	req.Actor = audit.ActorFrom(c)
 
-	resp, err := s.authAPI.Signup(req)
+	resp, err := s.authAPI.Signup(c.UserContext(), req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, err, "Signup.authAPI.Signup")
 	}
 
 	return c.JSON(resp)
@@ -41,9 +57,10 @@ func (s *authHttpTransport) VerifySignup(c *fiber.Ctx) error {
 	req := &SignupVerifyRequest{}
 
 	req.Token = c.Params("token")
-	resp, err := s.authAPI.VerifySignup(req)
+	req.Actor = audit.ActorFrom(c)
+	resp, err := s.authAPI.VerifySignup(c.UserContext(), req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, err, "VerifySignup.authAPI.VerifySignup")
 	}
 
 	return c.JSON(resp)
@@ -52,11 +69,13 @@ func (s *authHttpTransport) VerifySignup(c *fiber.Ctx) error {
 func (s *authHttpTransport) Login(c *fiber.Ctx) error {
 	req := &LoginRequest{}
 	if err := c.BodyParser(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "Login.c.BodyParser")
 	}
-	resp, err := s.authAPI.Login(req)
+	req.UserAgent = c.Get(fiber.HeaderUserAgent)
+	req.IP = c.IP()
+	resp, err := s.authAPI.Login(c.UserContext(), req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, err, "Login.authAPI.Login")
 	}
 
 	return c.JSON(resp)
@@ -66,16 +85,17 @@ func (s *authHttpTransport) UpdateUser(c *fiber.Ctx) error {
 	req := &UpdateUserRequest{}
 	userId, err := middleware.CtxUserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, err, "UpdateUser.middleware.CtxUserID")
 	}
 	req.UserID = userId
 	if err := c.BodyParser(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "UpdateUser.c.BodyParser")
 	}
+	req.Actor = audit.ActorFrom(c)
 
-	resp, err := s.authAPI.UpdateUser(req)
+	resp, err := s.authAPI.UpdateUser(c.UserContext(), req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, err, "UpdateUser.authAPI.UpdateUser")
 	}
 
 	return c.JSON(resp)
@@ -84,12 +104,13 @@ func (s *authHttpTransport) UpdateUser(c *fiber.Ctx) error {
 func (s *authHttpTransport) ForgotPassword(c *fiber.Ctx) error {
 	req := &ForgotPasswordRequest{}
 	if err := c.BodyParser(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "ForgotPassword.c.BodyParser")
 	}
+	req.Actor = audit.ActorFrom(c)
 
-	resp, err := s.authAPI.ForgotPassword(req)
+	resp, err := s.authAPI.ForgotPassword(c.UserContext(), req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, err, "ForgotPassword.authAPI.ForgotPassword")
 	}
 
 	return c.JSON(resp)
@@ -99,14 +120,221 @@ func (s *authHttpTransport) ResetPassword(c *fiber.Ctx) error {
 	req := &ResetPasswordRequest{}
 	req.Token = c.Params("token")
 	if err := c.BodyParser(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "ResetPassword.c.BodyParser")
 	}
+	req.Actor = audit.ActorFrom(c)
 
-	resp, err := s.authAPI.ResetPassword(req)
+	resp, err := s.authAPI.ResetPassword(c.UserContext(), req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, err, "ResetPassword.authAPI.ResetPassword")
 	}
 
 	return c.JSON(resp)
 }
 
+func (s *authHttpTransport) Refresh(c *fiber.Ctx) error {
+	req := &RefreshRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "Refresh.c.BodyParser")
+	}
+
+	resp, err := s.authAPI.Refresh(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "Refresh.authAPI.Refresh")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *authHttpTransport) Logout(c *fiber.Ctx) error {
+	req, err := sessionRequest(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "Logout.sessionRequest")
+	}
+
+	resp, err := s.authAPI.Logout(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "Logout.authAPI.Logout")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *authHttpTransport) GetSessions(c *fiber.Ctx) error {
+	req, err := sessionRequest(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "GetSessions.sessionRequest")
+	}
+
+	resp, err := s.authAPI.GetSessions(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "GetSessions.authAPI.GetSessions")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *authHttpTransport) RevokeSession(c *fiber.Ctx) error {
+	req, err := sessionRequest(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "RevokeSession.sessionRequest")
+	}
+	sessionId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidArgument.Wrap(err), "RevokeSession.strconv.Atoi")
+	}
+	req.SessionID = uint(sessionId)
+
+	resp, err := s.authAPI.RevokeSession(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "RevokeSession.authAPI.RevokeSession")
+	}
+
+	return c.JSON(resp)
+}
+
+func sessionRequest(c *fiber.Ctx) (*SessionRequest, error) {
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return nil, err
+	}
+	sessionId, err := middleware.CtxSessionID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionRequest{UserID: userId, CurrentSessionID: sessionId, Actor: audit.ActorFrom(c)}, nil
+}
+
+func (s *authHttpTransport) LoginTwoFactor(c *fiber.Ctx) error {
+	req := &TwoFactorLoginRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "LoginTwoFactor.c.BodyParser")
+	}
+	req.UserAgent = c.Get(fiber.HeaderUserAgent)
+	req.IP = c.IP()
+
+	resp, err := s.authAPI.LoginTwoFactor(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "LoginTwoFactor.authAPI.LoginTwoFactor")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *authHttpTransport) LoginTwoFactorSetup(c *fiber.Ctx) error {
+	req := &TwoFactorChallengeRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "LoginTwoFactorSetup.c.BodyParser")
+	}
+
+	resp, err := s.authAPI.LoginTwoFactorSetup(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "LoginTwoFactorSetup.authAPI.LoginTwoFactorSetup")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *authHttpTransport) EnrollTwoFactor(c *fiber.Ctx) error {
+	req := &TwoFactorRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "EnrollTwoFactor.middleware.CtxUserID")
+	}
+	req.UserID = userId
+	req.Actor = audit.ActorFrom(c)
+
+	resp, err := s.authAPI.EnrollTwoFactor(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "EnrollTwoFactor.authAPI.EnrollTwoFactor")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *authHttpTransport) ConfirmTwoFactor(c *fiber.Ctx) error {
+	req, err := twoFactorRequest(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "ConfirmTwoFactor.twoFactorRequest")
+	}
+
+	resp, err := s.authAPI.ConfirmTwoFactor(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "ConfirmTwoFactor.authAPI.ConfirmTwoFactor")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *authHttpTransport) DisableTwoFactor(c *fiber.Ctx) error {
+	req, err := twoFactorRequest(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "DisableTwoFactor.twoFactorRequest")
+	}
+
+	resp, err := s.authAPI.DisableTwoFactor(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "DisableTwoFactor.authAPI.DisableTwoFactor")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *authHttpTransport) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	req, err := twoFactorRequest(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "RegenerateRecoveryCodes.twoFactorRequest")
+	}
+
+	resp, err := s.authAPI.RegenerateRecoveryCodes(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "RegenerateRecoveryCodes.authAPI.RegenerateRecoveryCodes")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *authHttpTransport) OAuthAuthorize(c *fiber.Ctx) error {
+	req := &OAuthAuthorizeRequest{}
+	req.Provider = c.Params("provider")
+
+	resp, err := s.authAPI.OAuthAuthorize(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "OAuthAuthorize.authAPI.OAuthAuthorize")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *authHttpTransport) OAuthLogin(c *fiber.Ctx) error {
+	req := &OAuthLoginRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "OAuthLogin.c.BodyParser")
+	}
+	req.Provider = c.Params("provider")
+	req.UserAgent = c.Get(fiber.HeaderUserAgent)
+	req.IP = c.IP()
+
+	resp, err := s.authAPI.OAuthLogin(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "OAuthLogin.authAPI.OAuthLogin")
+	}
+
+	return c.JSON(resp)
+}
+
+func twoFactorRequest(c *fiber.Ctx) (*TwoFactorRequest, error) {
+	req := &TwoFactorRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.BodyParser(req); err != nil {
+		return nil, helper.ErrInvalidBody.Wrap(err)
+	}
+	req.UserID = userId
+	req.Actor = audit.ActorFrom(c)
+
+	return req, nil
+}
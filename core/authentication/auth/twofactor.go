@@ -0,0 +1,482 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/users"
+	"vezhguesi/core/validation"
+	helper "vezhguesi/helper"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	twoFactorIssuer       = "Vezhguesi"
+	challengeTokenTTL     = 5 * time.Minute
+	recoveryCodeCount     = 10
+	purposeTwoFactorLogin = "2fa-login"
+	purposeTwoFactorSetup = "2fa-setup"
+)
+
+// @Summary      	LoginTwoFactor
+// @Description		Second step of the login for users with two-factor authentication. Validates the challenge token from Login together with a TOTP code or a recovery code and returns the session tokens. When the challenge was issued for a required enrollment, the code confirms the enrollment and the recovery codes are returned once.
+// @Tags			Auth
+// @Accept			json
+// @Produce			json
+// @Param			TwoFactorLoginRequest	body		TwoFactorLoginRequest	true	"TwoFactorLoginRequest"
+// @Success			200				{object}	LoginResponse
+// @Router			/api/auth/login/2fa			[POST]
+func (s *authApi) LoginTwoFactor(ctx context.Context, req *TwoFactorLoginRequest) (res *LoginResponse, err error) {
+	userID, purpose, err := s.parseChallengeToken(req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var user users.User
+	if err := s.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", userID).First(&user).Error; err != nil {
+		return nil, helper.ErrNotFound
+	}
+
+	if err := s.checkLoginThrottle(ctx, user.Email, req.IP); err != nil {
+		return nil, err
+	}
+
+	actor := audit.Actor{UserID: user.ID, IP: req.IP, UserAgent: req.UserAgent}
+	var recoveryCodes []string
+	switch purpose {
+	case purposeTwoFactorLogin:
+		if req.RecoveryCode != "" {
+			err = s.useRecoveryCode(ctx, user.ID, req.RecoveryCode)
+		} else {
+			err = s.verifyTOTP(ctx, user.ID, req.Code, true)
+		}
+		if err != nil {
+			s.recordLoginFailure(ctx, user.Email, actor, &user)
+			return nil, err
+		}
+	case purposeTwoFactorSetup:
+		recoveryCodes, err = s.confirmTwoFactor(ctx, user.ID, req.Code)
+		if err != nil {
+			s.recordLoginFailure(ctx, user.Email, actor, &user)
+			return nil, err
+		}
+		s.record(ctx, audit.Event{
+			Actor:      actor,
+			Action:     audit.ActionTwoFactorEnabled,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+		})
+	}
+
+	pair, err := s.tokens.StartSession(user.ID, session.DeviceInfo{
+		Name:      req.DeviceName,
+		UserAgent: req.UserAgent,
+		IP:        req.IP,
+	})
+	if err != nil {
+		s.logger.Errorf("func: LoginTwoFactor, operation: s.tokens.StartSession, err: %s", err.Error())
+		return nil, err
+	}
+	s.recordLoginSuccess(ctx, user.Email, actor, pair.SessionID)
+
+	return &LoginResponse{
+		UserData:      s.userData(&user),
+		Token:         pair.AccessToken,
+		RefreshToken:  pair.RefreshToken,
+		ExpiresIn:     pair.ExpiresIn,
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// @Summary      	LoginTwoFactorSetup
+// @Description		Starts the enrollment required by the org policy during login. Validates the setup challenge token from Login and returns the TOTP secret and provisioning URI; the enrollment is confirmed through /api/auth/login/2fa.
+// @Tags			Auth
+// @Accept			json
+// @Produce			json
+// @Param			TwoFactorChallengeRequest	body		TwoFactorChallengeRequest	true	"TwoFactorChallengeRequest"
+// @Success			200				{object}	TwoFactorEnrollResponse
+// @Router			/api/auth/login/2fa/setup			[POST]
+func (s *authApi) LoginTwoFactorSetup(ctx context.Context, req *TwoFactorChallengeRequest) (res *TwoFactorEnrollResponse, err error) {
+	userID, purpose, err := s.parseChallengeToken(req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if purpose != purposeTwoFactorSetup {
+		return nil, apperr.New(apperr.Invalid, "invalid_challenge_token", "invalid challenge token")
+	}
+
+	return s.EnrollTwoFactor(ctx, &TwoFactorRequest{UserID: userID})
+}
+
+// @Summary      	EnrollTwoFactor
+// @Description		Generates a new TOTP secret for the user and returns it with the otpauth:// provisioning URI to render as a QR code. Two-factor authentication is enabled once a code is confirmed.
+// @Tags			Auth
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Success			200				{object}	TwoFactorEnrollResponse
+// @Router			/api/auth/2fa/enroll			[POST]
+func (s *authApi) EnrollTwoFactor(ctx context.Context, req *TwoFactorRequest) (res *TwoFactorEnrollResponse, err error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+
+	var user users.User
+	if err := s.db.WithContext(ctx).First(&user, req.UserID).Error; err != nil {
+		return nil, helper.ErrNotFound
+	}
+
+	var tf session.TwoFactor
+	s.db.WithContext(ctx).Where("user_id = ?", user.ID).First(&tf)
+	if tf.Enabled {
+		return nil, apperr.New(apperr.Conflict, "two_factor_already_enabled", "two-factor authentication is already enabled")
+	}
+
+	secret, err := session.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret")
+	}
+	tf.UserID = user.ID
+	tf.Secret = secret
+	tf.LastUsedStep = 0
+	if err := s.db.WithContext(ctx).Save(&tf).Error; err != nil {
+		s.logger.Errorf("func: EnrollTwoFactor, operation: s.db.Save(&tf), err: %s", err.Error())
+		return nil, err
+	}
+
+	return &TwoFactorEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: session.TOTPProvisioningURI(twoFactorIssuer, user.Email, secret),
+	}, nil
+}
+
+// @Summary      	ConfirmTwoFactor
+// @Description		Verifies a code from the authenticator app, enables two-factor authentication and returns the recovery codes. The recovery codes are only shown once.
+// @Tags			Auth
+// @Accept			json
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			TwoFactorRequest	body		TwoFactorRequest	true	"TwoFactorRequest"
+// @Success			200				{object}	RecoveryCodesResponse
+// @Router			/api/auth/2fa/confirm			[POST]
+func (s *authApi) ConfirmTwoFactor(ctx context.Context, req *TwoFactorRequest) (res *RecoveryCodesResponse, err error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.confirmTwoFactor(ctx, req.UserID, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, audit.Event{
+		Actor:      req.Actor,
+		Action:     audit.ActionTwoFactorEnabled,
+		TargetType: audit.TargetUser,
+		TargetID:   req.UserID,
+	})
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// @Summary      	DisableTwoFactor
+// @Description		Disables two-factor authentication after checking the password and a current code, or only the code for accounts without a password. Not allowed when an org of the user requires it for the user's role.
+// @Tags			Auth
+// @Accept			json
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			TwoFactorRequest	body		TwoFactorRequest	true	"TwoFactorRequest"
+// @Success			200				{object}	StatusResponse
+// @Router			/api/auth/2fa/disable			[POST]
+func (s *authApi) DisableTwoFactor(ctx context.Context, req *TwoFactorRequest) (res *StatusResponse, err error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+
+	var user users.User
+	if err := s.db.WithContext(ctx).First(&user, req.UserID).Error; err != nil {
+		return nil, helper.ErrNotFound
+	}
+	// Accounts that only sign in with an identity provider have no password,
+	// the current code alone proves it's the user
+	if user.Password != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			return nil, apperr.New(apperr.Invalid, "invalid_password", "invalid password")
+		}
+	}
+	if err := s.verifyTOTP(ctx, user.ID, req.Code, true); err != nil {
+		return nil, err
+	}
+	if s.twoFactorRequired(ctx, user.ID) {
+		return nil, apperr.New(apperr.Forbidden, "two_factor_required", "two-factor authentication is required by your organization")
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&session.TwoFactor{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&session.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionTwoFactorDisabled,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+		})
+	})
+	if err != nil {
+		s.logger.Errorf("func: DisableTwoFactor, operation: s.db.Transaction, err: %s", err.Error())
+		return nil, err
+	}
+
+	return &StatusResponse{
+		Status: true,
+	}, nil
+}
+
+// @Summary      	RegenerateRecoveryCodes
+// @Description		Replaces the user's recovery codes after checking a current code.
+// @Tags			Auth
+// @Accept			json
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			TwoFactorRequest	body		TwoFactorRequest	true	"TwoFactorRequest"
+// @Success			200				{object}	RecoveryCodesResponse
+// @Router			/api/auth/2fa/recovery-codes			[POST]
+func (s *authApi) RegenerateRecoveryCodes(ctx context.Context, req *TwoFactorRequest) (res *RecoveryCodesResponse, err error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	if err := s.verifyTOTP(ctx, req.UserID, req.Code, true); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, req.UserID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionRecoveryCodesRegenerated,
+			TargetType: audit.TargetUser,
+			TargetID:   req.UserID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// twoFactorChallenge returns the login response asking for a second factor, or
+// nil when the user can be logged in with the password alone.
+func (s *authApi) twoFactorChallenge(ctx context.Context, userID int) (*LoginResponse, error) {
+	var tf session.TwoFactor
+	s.db.WithContext(ctx).Where("user_id = ?", userID).First(&tf)
+
+	purpose := ""
+	switch {
+	case tf.Enabled:
+		purpose = purposeTwoFactorLogin
+	case s.twoFactorRequired(ctx, userID):
+		purpose = purposeTwoFactorSetup
+	default:
+		return nil, nil
+	}
+
+	token := jwt.New(jwt.SigningMethodHS256)
+	claims := token.Claims.(jwt.MapClaims)
+	claims["userId"] = userID
+	claims["purpose"] = purpose
+	claims["exp"] = time.Now().Add(challengeTokenTTL).Unix()
+
+	t, err := token.SignedString([]byte(s.secretKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token")
+	}
+
+	return &LoginResponse{
+		TwoFactorRequired:      purpose == purposeTwoFactorLogin,
+		TwoFactorSetupRequired: purpose == purposeTwoFactorSetup,
+		ChallengeToken:         t,
+	}, nil
+}
+
+func (s *authApi) parseChallengeToken(challengeToken string) (int, string, error) {
+	challengeToken = strings.TrimSpace(challengeToken)
+	if challengeToken == "" {
+		return 0, "", helper.ErrMissingToken
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(challengeToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(s.secretKey), nil
+	})
+	if err != nil {
+		return 0, "", apperr.New(apperr.Invalid, "invalid_challenge_token", "invalid challenge token")
+	}
+
+	purpose, _ := claims["purpose"].(string)
+	userID, ok := claims["userId"].(float64)
+	if !ok || (purpose != purposeTwoFactorLogin && purpose != purposeTwoFactorSetup) {
+		return 0, "", apperr.New(apperr.Invalid, "invalid_challenge_token", "invalid challenge token")
+	}
+
+	return int(userID), purpose, nil
+}
+
+// twoFactorRequired reports whether an org the user is owner or admin of
+// requires two-factor authentication.
+func (s *authApi) twoFactorRequired(ctx context.Context, userID int) bool {
+	var count int64
+	s.db.WithContext(ctx).Table("user_org_roles").
+		Joins("JOIN roles ON roles.id = user_org_roles.role_id").
+		Joins("JOIN orgs ON orgs.id = user_org_roles.org_id").
+		Where("user_org_roles.user_id = ? AND user_org_roles.deleted_at IS NULL", userID).
+		Where("roles.name IN ?", []string{helper.OwnerRoleName, helper.AdminRoleName}).
+		Where("orgs.require_two_factor = ?", true).
+		Count(&count)
+	return count > 0
+}
+
+// verifyTOTP checks the code against the user's secret. With requireEnabled
+// false it also accepts the pending secret of an unconfirmed enrollment.
+func (s *authApi) verifyTOTP(ctx context.Context, userID int, code string, requireEnabled bool) error {
+	var tf session.TwoFactor
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&tf).Error; err != nil {
+		return apperr.New(apperr.Conflict, "two_factor_not_enrolled", "two-factor authentication is not enrolled")
+	}
+	if requireEnabled && !tf.Enabled {
+		return apperr.New(apperr.Conflict, "two_factor_not_enabled", "two-factor authentication is not enabled")
+	}
+
+	step, ok := session.ValidateTOTP(tf.Secret, code, time.Now())
+	if !ok || step <= tf.LastUsedStep {
+		return apperr.New(apperr.Invalid, "invalid_two_factor_code", "invalid two-factor code")
+	}
+
+	result := s.db.WithContext(ctx).Model(&session.TwoFactor{}).
+		Where("id = ? AND last_used_step < ?", tf.ID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apperr.New(apperr.Invalid, "invalid_two_factor_code", "invalid two-factor code")
+	}
+
+	return nil
+}
+
+func (s *authApi) confirmTwoFactor(ctx context.Context, userID int, code string) ([]string, error) {
+	if err := s.verifyTOTP(ctx, userID, code, false); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&session.TwoFactor{}).
+			Where("user_id = ?", userID).
+			Updates(map[string]interface{}{"enabled": true, "enabled_at": now}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		s.logger.Errorf("func: confirmTwoFactor, operation: s.db.Transaction, err: %s", err.Error())
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *authApi) replaceRecoveryCodes(tx *gorm.DB, userID int) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&session.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := session.GenerateTOTPSecret()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery codes")
+		}
+		code := strings.ToLower(raw[:5] + "-" + raw[5:10])
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash recovery code")
+		}
+		if err := tx.Create(&session.RecoveryCode{UserID: userID, CodeHash: string(hash)}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+func (s *authApi) useRecoveryCode(ctx context.Context, userID int, code string) error {
+	code = strings.ToLower(strings.TrimSpace(code))
+
+	var recoveryCodes []session.RecoveryCode
+	s.db.WithContext(ctx).Where("user_id = ? AND used_at IS NULL", userID).Find(&recoveryCodes)
+	for _, rc := range recoveryCodes {
+		if bcrypt.CompareHashAndPassword([]byte(rc.CodeHash), []byte(code)) != nil {
+			continue
+		}
+
+		result := s.db.WithContext(ctx).Model(&session.RecoveryCode{}).
+			Where("id = ? AND used_at IS NULL", rc.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil
+		}
+	}
+
+	return apperr.New(apperr.Invalid, "invalid_recovery_code", "invalid recovery code")
+}
+
+// userData is the UserData of a response, linking the avatar with a signed
+// URL instead of its storage key.
+func (s *authApi) userData(user *users.User) *UserData {
+	userData := userDataFrom(user)
+	userData.AvatarImgUrl = users.AvatarURL(s.blobs, user.AvatarImgKey, users.DefaultAvatarSize)
+	return userData
+}
+
+func userDataFrom(user *users.User) *UserData {
+	userData := &UserData{
+		ID:           user.ID,
+		Email:        user.Email,
+		FirstName:    user.FirstName,
+		LastName:     user.LastName,
+		Role:         user.Role,
+		AvatarImgUrl: user.AvatarImgKey,
+	}
+	if user.Username != nil {
+		userData.Username = *user.Username
+	}
+	return userData
+}
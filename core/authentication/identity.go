@@ -0,0 +1,27 @@
+package authentication
+
+import "time"
+
+// ProviderIdentity links a user to their account at an external OpenID
+// Connect provider.
+type ProviderIdentity struct {
+	ID          uint   `gorm:"primaryKey"`
+	Provider    string `gorm:"not null;uniqueIndex:idx_provider_subject"`
+	Subject     string `gorm:"not null;uniqueIndex:idx_provider_subject"`
+	UserID      int    `gorm:"not null;index"`
+	Email       string
+	LastLoginAt *time.Time
+	CreatedAt   time.Time
+}
+
+// OAuthState holds the per-attempt secrets of an authorization-code flow
+// between the redirect to the provider and the callback.
+type OAuthState struct {
+	ID           uint   `gorm:"primaryKey"`
+	State        string `gorm:"unique;not null"`
+	Provider     string `gorm:"not null"`
+	CodeVerifier string `gorm:"not null"`
+	Nonce        string `gorm:"not null"`
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
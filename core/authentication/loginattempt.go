@@ -0,0 +1,39 @@
+package authentication
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginAttempt records every password or second-factor check made during
+// login, whether or not the email belongs to an account, so throttling can't
+// be used to probe for existing accounts.
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey"`
+	Email     string    `gorm:"not null;index"`
+	IP        string    `gorm:"not null;index"`
+	Success   bool      `gorm:"not null;default:false"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// AccountLockout temporarily blocks logins for an email after too many
+// failed attempts.
+type AccountLockout struct {
+	ID          uint      `gorm:"primaryKey"`
+	Email       string    `gorm:"not null;index"`
+	LockedUntil time.Time `gorm:"not null"`
+	CreatedAt   time.Time
+}
+
+// PruneLoginAttempts deletes the attempts made before olderThan, along with
+// the lockouts that have ended. Attempts for unknown emails are never
+// cleared by a successful login, so nothing else removes them.
+func PruneLoginAttempts(ctx context.Context, db *gorm.DB, olderThan time.Time) error {
+	db = db.WithContext(ctx)
+	if err := db.Where("created_at < ?", olderThan).Delete(&LoginAttempt{}).Error; err != nil {
+		return err
+	}
+	return db.Where("locked_until < ?", time.Now()).Delete(&AccountLockout{}).Error
+}
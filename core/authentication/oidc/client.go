@@ -0,0 +1,285 @@
+package oidc
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"vezhguesi/core/apperr"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var ErrInvalidIDToken = apperr.New(apperr.Unauthenticated, "invalid_id_token", "invalid id token")
+
+// Provider is the configuration of one OpenID Connect identity provider.
+type Provider struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Claims are the ID token claims we use to link or create a user.
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type client struct {
+	provider   Provider
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]*rsa.PublicKey
+	keysAt    time.Time
+}
+
+type Client interface {
+	Name() string
+	AuthCodeURL(state, nonce, codeChallenge string) (string, error)
+	Exchange(code, codeVerifier string) (idToken string, err error)
+	VerifyIDToken(rawIDToken, nonce string) (*Claims, error)
+}
+
+func NewClient(provider Provider, httpClient *http.Client) Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(provider.Scopes) == 0 {
+		provider.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &client{provider: provider, httpClient: httpClient}
+}
+
+func (c *client) Name() string {
+	return c.provider.Name
+}
+
+// AuthCodeURL returns the provider URL the user is redirected to for the
+// authorization-code flow with PKCE (S256).
+func (c *client) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	d, err := c.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", c.provider.ClientID)
+	params.Set("redirect_uri", c.provider.RedirectURL)
+	params.Set("scope", strings.Join(c.provider.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens and returns the ID token.
+func (c *client) Exchange(code, codeVerifier string) (string, error) {
+	d, err := c.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.provider.RedirectURL)
+	form.Set("client_id", c.provider.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if c.provider.ClientSecret != "" {
+		form.Set("client_secret", c.provider.ClientSecret)
+	}
+
+	resp, err := c.httpClient.PostForm(d.TokenEndpoint, form)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange code: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("failed to exchange code: status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %v", err)
+	}
+	if tokenResp.IDToken == "" {
+		return "", fmt.Errorf("token response has no id_token")
+	}
+
+	return tokenResp.IDToken, nil
+}
+
+// VerifyIDToken checks the signature against the provider JWKS and the
+// issuer, audience, expiry and nonce claims.
+func (c *client) VerifyIDToken(rawIDToken, nonce string) (*Claims, error) {
+	d, err := c.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != jwt.SigningMethodRS256.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		kid, _ := token.Header["kid"].(string)
+		return c.getKey(kid)
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidIDToken
+	}
+
+	if !claims.VerifyIssuer(d.Issuer, true) || !claims.VerifyAudience(c.provider.ClientID, true) {
+		return nil, ErrInvalidIDToken
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, ErrInvalidIDToken
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, ErrInvalidIDToken
+	}
+
+	result := &Claims{}
+	result.Subject, _ = claims["sub"].(string)
+	result.Email, _ = claims["email"].(string)
+	result.GivenName, _ = claims["given_name"].(string)
+	result.FamilyName, _ = claims["family_name"].(string)
+	// Some providers send email_verified as a string
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = v
+	case string:
+		result.EmailVerified = v == "true"
+	}
+	if result.Subject == "" {
+		return nil, ErrInvalidIDToken
+	}
+
+	return result, nil
+}
+
+func (c *client) getDiscovery() (*discovery, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.discovery != nil {
+		return c.discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(c.provider.IssuerURL, "/") + "/.well-known/openid-configuration"
+	var d discovery
+	if err := c.getJSON(wellKnown, &d); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s provider configuration: %v", c.provider.Name, err)
+	}
+	if d.Issuer != strings.TrimSuffix(c.provider.IssuerURL, "/") && d.Issuer != c.provider.IssuerURL {
+		return nil, fmt.Errorf("issuer mismatch for %s provider: %s", c.provider.Name, d.Issuer)
+	}
+
+	c.discovery = &d
+	return c.discovery, nil
+}
+
+// getKey returns the signing key with the given id, refetching the JWKS when
+// the key is unknown (providers rotate keys) at most once a minute.
+func (c *client) getKey(kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(c.keysAt) < time.Minute && c.keys != nil {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := c.getJSON(c.discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %v", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	c.keys = keys
+	c.keysAt = time.Now()
+
+	key, ok := c.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+func (c *client) getJSON(u string, v interface{}) error {
+	resp, err := c.httpClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// CodeChallenge returns the S256 PKCE challenge of the verifier.
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,172 @@
+package oidc_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"vezhguesi/core/authentication/oidc"
+	"vezhguesi/core/authentication/oidc/oidctest"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testState    = "state"
+	testNonce    = "nonce"
+	testVerifier = "verifier-verifier-verifier-verifier-verifier"
+)
+
+func TestLoginFlow(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	client := oidc.NewClient(issuer.Provider("fake"), nil)
+
+	authURL, err := client.AuthCodeURL(testState, testNonce, oidc.CodeChallenge(testVerifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := u.Query()
+	for key, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             oidctest.ClientID,
+		"redirect_uri":          oidctest.RedirectURL,
+		"state":                 testState,
+		"nonce":                 testNonce,
+		"code_challenge":        oidc.CodeChallenge(testVerifier),
+		"code_challenge_method": "S256",
+	} {
+		if got := params.Get(key); got != want {
+			t.Errorf("authorization URL %s = %q, want %q", key, got, want)
+		}
+	}
+
+	code := issuer.Authorize(t, authURL, jwt.MapClaims{
+		"sub":            "subject-1",
+		"email":          "ana@example.com",
+		"email_verified": true,
+		"given_name":     "Ana",
+		"family_name":    "Hoxha",
+	})
+	idToken, err := client.Exchange(code, testVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := client.VerifyIDToken(idToken, testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := oidc.Claims{
+		Subject:       "subject-1",
+		Email:         "ana@example.com",
+		EmailVerified: true,
+		GivenName:     "Ana",
+		FamilyName:    "Hoxha",
+	}
+	if *claims != want {
+		t.Errorf("claims = %+v, want %+v", *claims, want)
+	}
+
+	// Codes are single use
+	if _, err := client.Exchange(code, testVerifier); err == nil {
+		t.Error("second exchange of the code succeeded")
+	}
+}
+
+func TestExchangeChecksVerifier(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	client := oidc.NewClient(issuer.Provider("fake"), nil)
+
+	authURL, err := client.AuthCodeURL(testState, testNonce, oidc.CodeChallenge(testVerifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := issuer.Authorize(t, authURL, jwt.MapClaims{"sub": "subject-1"})
+	if _, err := client.Exchange(code, "another-verifier"); err == nil {
+		t.Error("exchange with another verifier succeeded")
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"other nonce", issuer.IDToken(t, jwt.MapClaims{"sub": "s", "nonce": "other"})},
+		{"no nonce", issuer.IDToken(t, jwt.MapClaims{"sub": "s"})},
+		{"other audience", issuer.IDToken(t, jwt.MapClaims{"sub": "s", "nonce": testNonce, "aud": "other-client"})},
+		{"other issuer", issuer.IDToken(t, jwt.MapClaims{"sub": "s", "nonce": testNonce, "iss": "https://other.example.com"})},
+		{"expired", issuer.IDToken(t, jwt.MapClaims{"sub": "s", "nonce": testNonce, "exp": time.Now().Add(-time.Minute).Unix()})},
+		{"no subject", issuer.IDToken(t, jwt.MapClaims{"nonce": testNonce})},
+		{"signed by another key", issuer.SignWith(t, jwt.SigningMethodRS256, otherKey, jwt.MapClaims{"sub": "s", "nonce": testNonce})},
+		{"HMAC signed", issuer.SignWith(t, jwt.SigningMethodHS256, []byte("secret"), jwt.MapClaims{"sub": "s", "nonce": testNonce})},
+		{"unsigned", issuer.SignWith(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, jwt.MapClaims{"sub": "s", "nonce": testNonce})},
+		{"malformed", "not.a.token"},
+	}
+	for _, tt := range tests {
+		// A client per case, so a failed key lookup does not affect the next
+		client := oidc.NewClient(issuer.Provider("fake"), nil)
+		if _, err := client.VerifyIDToken(tt.token, testNonce); err == nil {
+			t.Errorf("%s: token was accepted", tt.name)
+		}
+	}
+}
+
+func TestVerifyIDTokenEmailVerified(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	client := oidc.NewClient(issuer.Provider("fake"), nil)
+
+	tests := []struct {
+		value interface{}
+		want  bool
+	}{
+		{true, true},
+		{false, false},
+		// Some providers send a string
+		{"true", true},
+		{"false", false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		claims := jwt.MapClaims{"sub": "s", "nonce": testNonce, "email": "ana@example.com"}
+		if tt.value != nil {
+			claims["email_verified"] = tt.value
+		}
+		got, err := client.VerifyIDToken(issuer.IDToken(t, claims), testNonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.EmailVerified != tt.want {
+			t.Errorf("email_verified %v: EmailVerified = %v, want %v", tt.value, got.EmailVerified, tt.want)
+		}
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	provider := issuer.Provider("fake")
+
+	provider.IssuerURL = issuer.URL + "/"
+	if _, err := oidc.NewClient(provider, nil).AuthCodeURL(testState, testNonce, "challenge"); err != nil {
+		t.Fatalf("issuer URL with a trailing slash was rejected: %v", err)
+	}
+
+	// The same server under another name announces an issuer that is not
+	// the configured one
+	provider.IssuerURL = strings.Replace(issuer.URL, "127.0.0.1", "localhost", 1)
+	if _, err := oidc.NewClient(provider, nil).AuthCodeURL(testState, testNonce, "challenge"); err == nil {
+		t.Error("discovery of another issuer was accepted")
+	}
+}
@@ -0,0 +1,189 @@
+// Package oidctest runs a fake OpenID Connect issuer for tests: discovery,
+// JWKS and a token endpoint checking PKCE, with ID tokens signed by a key
+// generated per issuer.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"vezhguesi/core/authentication/oidc"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	ClientID    = "test-client"
+	RedirectURL = "http://localhost/callback"
+	keyID       = "test-key"
+)
+
+// Issuer is a fake provider. Its URL is the issuer of the tokens it signs.
+type Issuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+// grant is an authorization code waiting to be exchanged.
+type grant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+// NewIssuer starts an issuer, closed when the test ends.
+func NewIssuer(t testing.TB) *Issuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := &Issuer{key: key, codes: make(map[string]grant)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", i.discovery)
+	mux.HandleFunc("/jwks", i.jwks)
+	mux.HandleFunc("/token", i.token)
+	i.Server = httptest.NewServer(mux)
+	t.Cleanup(i.Close)
+	return i
+}
+
+// Provider is the configuration of a client of the issuer.
+func (i *Issuer) Provider(name string) oidc.Provider {
+	return oidc.Provider{
+		Name:        name,
+		IssuerURL:   i.URL,
+		ClientID:    ClientID,
+		RedirectURL: RedirectURL,
+	}
+}
+
+// Authorize plays the user logging in at authURL, the URL built by
+// AuthCodeURL, and returns the code the provider redirects back with. The ID
+// token of the code holds claims along with the nonce of authURL.
+func (i *Issuer) Authorize(t testing.TB, authURL string, claims jwt.MapClaims) string {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := u.Query()
+
+	tokenClaims := jwt.MapClaims{"nonce": params.Get("nonce")}
+	for k, v := range claims {
+		tokenClaims[k] = v
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	code := hex.EncodeToString(b)
+	i.mu.Lock()
+	i.codes[code] = grant{challenge: params.Get("code_challenge"), claims: tokenClaims}
+	i.mu.Unlock()
+	return code
+}
+
+// IDToken signs claims with the issuer's key. The issuer, audience and
+// expiry claims are filled in unless claims sets them.
+func (i *Issuer) IDToken(t testing.TB, claims jwt.MapClaims) string {
+	t.Helper()
+	return i.sign(t, jwt.SigningMethodRS256, i.key, claims)
+}
+
+// SignWith signs claims like IDToken but with another method and key, e.g.
+// to forge a token.
+func (i *Issuer) SignWith(t testing.TB, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	return i.sign(t, method, key, claims)
+}
+
+func (i *Issuer) sign(t testing.TB, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	signed, err := i.signed(method, key, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (i *Issuer) signed(method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) (string, error) {
+	full := jwt.MapClaims{
+		"iss": i.URL,
+		"aud": ClientID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		full[k] = v
+	}
+
+	token := jwt.NewWithClaims(method, full)
+	token.Header["kid"] = keyID
+	return token.SignedString(key)
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{
+		"issuer":                 i.URL,
+		"authorization_endpoint": i.URL + "/authorize",
+		"token_endpoint":         i.URL + "/token",
+		"jwks_uri":               i.URL + "/jwks",
+	})
+}
+
+func (i *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": keyID,
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+		}},
+	})
+}
+
+// token exchanges a code once, for the verifier of its challenge.
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("client_id") != ClientID {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+
+	i.mu.Lock()
+	g, ok := i.codes[r.PostForm.Get("code")]
+	delete(i.codes, r.PostForm.Get("code"))
+	i.mu.Unlock()
+	if !ok || oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != g.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	signed, err := i.signed(jwt.SigningMethodRS256, i.key, g.claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"id_token": signed, "token_type": "Bearer"})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
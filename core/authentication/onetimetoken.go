@@ -0,0 +1,97 @@
+package authentication
+
+import (
+	"time"
+
+	"vezhguesi/core/apperr"
+
+	"gorm.io/gorm"
+)
+
+// Purposes a one-time token can be issued for. A token only ever redeems for
+// the purpose it was issued with.
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+	PurposeChangeEmail   = "change_email"
+	PurposeOrgInvite     = "org_invite"
+)
+
+var ErrInvalidOneTimeToken = apperr.New(apperr.Invalid, "invalid_token", "invalid or expired token")
+
+// OneTimeToken is a single-use token sent by email. Only its hash is stored
+// and it is marked consumed the first time it is redeemed.
+type OneTimeToken struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    int    `gorm:"index"`
+	Purpose   string `gorm:"not null;index"`
+	TokenHash string `gorm:"unique;not null"`
+	// Email is the address the token was sent to
+	Email string
+	// Data holds purpose specific details, e.g. the org and role of an invite
+	Data       string
+	ExpiresAt  time.Time `gorm:"not null"`
+	ConsumedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// IssueOneTimeToken stores a new token for purpose and returns the raw token
+// to send. Earlier unconsumed tokens of the user for the same purpose are
+// invalidated, so only the latest link works.
+func IssueOneTimeToken(db *gorm.DB, purpose string, userID int, email, data string, ttl time.Duration) (string, error) {
+	raw, err := RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if userID != 0 {
+		err = db.Model(&OneTimeToken{}).
+			Where("user_id = ? AND purpose = ? AND consumed_at IS NULL", userID, purpose).
+			Update("consumed_at", now).Error
+		if err != nil {
+			return "", err
+		}
+	}
+
+	token := OneTimeToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: HashToken(raw),
+		Email:     email,
+		Data:      data,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := db.Create(&token).Error; err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+// ConsumeOneTimeToken redeems a token issued for purpose. The conditional
+// update makes sure concurrent redemptions of the same token can't both win.
+func ConsumeOneTimeToken(db *gorm.DB, purpose, raw string) (*OneTimeToken, error) {
+	if raw == "" {
+		return nil, ErrInvalidOneTimeToken
+	}
+	hash := HashToken(raw)
+
+	result := db.Model(&OneTimeToken{}).
+		Where("token_hash = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?", hash, purpose, time.Now()).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidOneTimeToken
+	}
+
+	var token OneTimeToken
+	if err := db.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
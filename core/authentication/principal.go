@@ -0,0 +1,176 @@
+package authentication
+
+import (
+	"sync"
+	"time"
+
+	"vezhguesi/core/apperr"
+
+	"gorm.io/gorm"
+)
+
+// PrincipalCacheTTL bounds how long a revoked session or a deactivated user
+// may keep working on an instance that did not perform the revocation.
+const PrincipalCacheTTL = 30 * time.Second
+
+var ErrSessionNotLive = apperr.New(apperr.Unauthenticated, "session_expired", "session expired or revoked")
+
+// Principal is the authenticated caller of a request, either a user session
+// or an API key.
+type Principal struct {
+	UserID    int
+	SessionID uint
+	OrgID     *int
+	OrgRole   string
+	Role      string
+	APIKeyID  uint
+	Scopes    []string
+	// ImpersonatorID is the admin acting as the user, 0 for the user's own
+	// sessions
+	ImpersonatorID int
+}
+
+// HasScope reports whether the principal may act within scope. User
+// sessions have every scope, API keys only the ones they were created with.
+func (p *Principal) HasScope(scope string) bool {
+	if p.APIKeyID == 0 {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalEntry struct {
+	principal Principal
+	cachedAt  time.Time
+	expiresAt time.Time
+}
+
+// stale reports whether the entry outlived the cache TTL or its session.
+func (e principalEntry) stale(now time.Time) bool {
+	return now.Sub(e.cachedAt) > PrincipalCacheTTL || e.expiresAt.Before(now)
+}
+
+// principalCache holds the principals of recent requests. Stale entries are
+// dropped when read and swept every PrincipalCacheTTL, so it only holds the
+// sessions seen within the last two TTLs.
+type principalCache struct {
+	mu      sync.Mutex
+	entries map[uint]principalEntry
+	sweptAt time.Time
+}
+
+var principals = &principalCache{entries: make(map[uint]principalEntry)}
+
+func (c *principalCache) get(sessionID uint, now time.Time) (Principal, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[sessionID]
+	if !ok {
+		return Principal{}, false
+	}
+	if entry.stale(now) {
+		delete(c.entries, sessionID)
+		return Principal{}, false
+	}
+	return entry.principal, true
+}
+
+func (c *principalCache) set(p Principal, expiresAt, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[p.SessionID] = principalEntry{principal: p, cachedAt: now, expiresAt: expiresAt}
+
+	if now.Sub(c.sweptAt) > PrincipalCacheTTL {
+		for id, entry := range c.entries {
+			if entry.stale(now) {
+				delete(c.entries, id)
+			}
+		}
+		c.sweptAt = now
+	}
+}
+
+func (c *principalCache) forget(sessionID uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, sessionID)
+}
+
+func (c *principalCache) forgetUser(userID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, entry := range c.entries {
+		if entry.principal.UserID == userID {
+			delete(c.entries, id)
+		}
+	}
+}
+
+// ForgetUser drops every cached principal of the user, e.g. after their role
+// or status changed, so the next request reloads them from the database.
+func ForgetUser(userID int) {
+	principals.forgetUser(userID)
+}
+
+// ResolvePrincipal checks that the session is live and belongs to userID and
+// loads the user's role and org membership. Results are cached for
+// PrincipalCacheTTL.
+func ResolvePrincipal(db *gorm.DB, userID int, sessionID uint) (*Principal, error) {
+	now := time.Now()
+	if p, ok := principals.get(sessionID, now); ok && p.UserID == userID {
+		return &p, nil
+	}
+
+	var sess Session
+	if err := db.First(&sess, sessionID).Error; err != nil {
+		return nil, ErrSessionNotLive
+	}
+	if int(sess.UserID) != userID || sess.RevokedAt != nil || sess.ExpiresAt.Before(now) {
+		return nil, ErrSessionNotLive
+	}
+
+	var user struct {
+		Role string
+	}
+	result := db.Table("users").
+		Select("role").
+		Where("id = ? AND active = ? AND deleted_at IS NULL", userID, true).
+		Take(&user)
+	if result.Error != nil {
+		return nil, ErrSessionNotLive
+	}
+
+	p := Principal{
+		UserID:    userID,
+		SessionID: sessionID,
+		Role:      user.Role,
+	}
+	if sess.ImpersonatorID != nil {
+		p.ImpersonatorID = *sess.ImpersonatorID
+	}
+
+	var membership struct {
+		OrgID    int
+		RoleName string
+	}
+	result = db.Table("user_org_roles").
+		Select("user_org_roles.org_id, roles.name AS role_name").
+		Joins("JOIN roles ON roles.id = user_org_roles.role_id").
+		Where("user_org_roles.user_id = ? AND user_org_roles.deleted_at IS NULL", userID).
+		Order("user_org_roles.created_at").
+		Limit(1).
+		Scan(&membership)
+	if result.Error == nil && membership.OrgID != 0 {
+		p.OrgID = &membership.OrgID
+		p.OrgRole = membership.RoleName
+	}
+
+	principals.set(p, sess.ExpiresAt, now)
+	return &p, nil
+}
@@ -4,9 +4,27 @@ import "time"
 
 type Session struct {
 	ID           uint   `gorm:"primaryKey"`
-	UserID       uint   `gorm:"not null"`
+	UserID       uint   `gorm:"not null;index"`
 	SessionToken string `gorm:"unique;not null"`
-	CreatedAt    time.Time
-	ExpiresAt    time.Time
+	DeviceName   string
+	UserAgent    string
+	IP           string
+	LastUsedAt   *time.Time
+	RevokedAt    *time.Time
+	// ImpersonatorID is the admin acting as the user in this session, if any
+	ImpersonatorID *int `gorm:"index"`
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
 }
 
+// RefreshToken is a single link of a session's rotation chain. Every refresh
+// marks the presented token as used and issues the next one, so a used token
+// showing up again means it was stolen and the whole session is revoked.
+type RefreshToken struct {
+	ID        uint   `gorm:"primaryKey"`
+	SessionID uint   `gorm:"not null;index"`
+	TokenHash string `gorm:"unique;not null"`
+	UsedAt    *time.Time
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,286 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"vezhguesi/core/apperr"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	// ImpersonationTTL is the lifetime of an impersonation session. It lasts
+	// a single access token and can't be refreshed.
+	ImpersonationTTL = AccessTokenTTL
+)
+
+var (
+	ErrInvalidRefreshToken = apperr.New(apperr.Unauthenticated, "invalid_refresh_token", "invalid refresh token")
+	ErrRefreshTokenReused  = apperr.New(apperr.Unauthenticated, "refresh_token_reused", "refresh token reuse detected, session revoked")
+	ErrSessionNotFound     = apperr.New(apperr.NotFound, "session_not_found", "session not found")
+)
+
+type DeviceInfo struct {
+	Name      string
+	UserAgent string
+	IP        string
+}
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	SessionID    uint
+	ExpiresIn    int64
+}
+
+type tokenIssuer struct {
+	db        *gorm.DB
+	secretKey string
+}
+
+type TokenIssuer interface {
+	StartSession(userID int, device DeviceInfo) (*TokenPair, error)
+	StartImpersonation(userID, impersonatorID int, device DeviceInfo) (*TokenPair, error)
+	Refresh(refreshToken string) (*TokenPair, error)
+	ListSessions(userID int) ([]Session, error)
+	RevokeSession(userID int, sessionID uint) error
+	RevokeAllSessions(userID int, exceptSessionID uint) error
+}
+
+func NewTokenIssuer(db *gorm.DB, secretKey string) TokenIssuer {
+	return &tokenIssuer{db: db, secretKey: secretKey}
+}
+
+// StartSession creates a new session for the device, leaving the user's other
+// sessions untouched, and returns its first access/refresh token pair.
+func (i *tokenIssuer) StartSession(userID int, device DeviceInfo) (*TokenPair, error) {
+	now := time.Now()
+
+	// Housekeeping: drop this user's sessions that can no longer be refreshed
+	i.db.Where("user_id = ? AND expires_at < ?", userID, now).Delete(&Session{})
+
+	sess := Session{
+		UserID:       uint(userID),
+		SessionToken: uuid.New().String(),
+		DeviceName:   device.Name,
+		UserAgent:    device.UserAgent,
+		IP:           device.IP,
+		LastUsedAt:   &now,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(RefreshTokenTTL),
+	}
+
+	var refreshToken string
+	err := i.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&sess).Error; err != nil {
+			return fmt.Errorf("failed to create session")
+		}
+
+		var err error
+		refreshToken, err = i.createRefreshToken(tx, sess.ID, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return i.tokenPair(&sess, refreshToken, now)
+}
+
+// StartImpersonation creates a session of userID on behalf of the admin
+// impersonatorID. The pair has no refresh token, the session ends when its
+// access token expires.
+func (i *tokenIssuer) StartImpersonation(userID, impersonatorID int, device DeviceInfo) (*TokenPair, error) {
+	now := time.Now()
+
+	sess := Session{
+		UserID:         uint(userID),
+		SessionToken:   uuid.New().String(),
+		DeviceName:     device.Name,
+		UserAgent:      device.UserAgent,
+		IP:             device.IP,
+		LastUsedAt:     &now,
+		ImpersonatorID: &impersonatorID,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(ImpersonationTTL),
+	}
+	if err := i.db.Create(&sess).Error; err != nil {
+		return nil, fmt.Errorf("failed to create session")
+	}
+
+	return i.tokenPair(&sess, "", now)
+}
+
+// Refresh rotates the refresh token and issues a new access token. Presenting
+// a refresh token that was already rotated revokes the session.
+func (i *tokenIssuer) Refresh(refreshToken string) (*TokenPair, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	now := time.Now()
+
+	var stored RefreshToken
+	if err := i.db.Where("token_hash = ?", HashToken(refreshToken)).First(&stored).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var sess Session
+	if err := i.db.First(&sess, stored.SessionID).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if sess.RevokedAt != nil || sess.ExpiresAt.Before(now) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if stored.UsedAt != nil {
+		i.revoke(i.db.Where("id = ?", sess.ID), now)
+		principals.forget(sess.ID)
+		return nil, ErrRefreshTokenReused
+	}
+	if stored.ExpiresAt.Before(now) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var nextToken string
+	err := i.db.Transaction(func(tx *gorm.DB) error {
+		// Conditional update so two concurrent refreshes cannot both win
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		sess.LastUsedAt = &now
+		sess.ExpiresAt = now.Add(RefreshTokenTTL)
+		if err := tx.Model(&sess).Updates(map[string]interface{}{
+			"last_used_at": sess.LastUsedAt,
+			"expires_at":   sess.ExpiresAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		nextToken, err = i.createRefreshToken(tx, sess.ID, now)
+		return err
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		i.revoke(i.db.Where("id = ?", sess.ID), now)
+		principals.forget(sess.ID)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return i.tokenPair(&sess, nextToken, now)
+}
+
+func (i *tokenIssuer) ListSessions(userID int) ([]Session, error) {
+	var sessions []Session
+	result := i.db.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return sessions, nil
+}
+
+func (i *tokenIssuer) RevokeSession(userID int, sessionID uint) error {
+	result := i.revoke(i.db.Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID), time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	principals.forget(sessionID)
+
+	return nil
+}
+
+// RevokeAllSessions revokes every live session of the user except
+// exceptSessionID, which may be 0 to revoke them all.
+func (i *tokenIssuer) RevokeAllSessions(userID int, exceptSessionID uint) error {
+	result := i.revoke(i.db.Where("user_id = ? AND revoked_at IS NULL AND id <> ?", userID, exceptSessionID), time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	principals.forgetUser(userID)
+
+	return nil
+}
+
+func (i *tokenIssuer) revoke(query *gorm.DB, now time.Time) *gorm.DB {
+	return query.Model(&Session{}).Update("revoked_at", now)
+}
+
+func (i *tokenIssuer) createRefreshToken(tx *gorm.DB, sessionID uint, now time.Time) (string, error) {
+	raw, err := RandomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate refresh token")
+	}
+
+	if err := tx.Create(&RefreshToken{
+		SessionID: sessionID,
+		TokenHash: HashToken(raw),
+		ExpiresAt: now.Add(RefreshTokenTTL),
+		CreatedAt: now,
+	}).Error; err != nil {
+		return "", fmt.Errorf("failed to store refresh token")
+	}
+
+	return raw, nil
+}
+
+func (i *tokenIssuer) tokenPair(sess *Session, refreshToken string, now time.Time) (*TokenPair, error) {
+	token := jwt.New(jwt.SigningMethodHS256)
+	claims := token.Claims.(jwt.MapClaims)
+	claims["userId"] = sess.UserID
+	claims["sid"] = sess.ID
+	claims["exp"] = now.Add(AccessTokenTTL).Unix()
+
+	t, err := token.SignedString([]byte(i.secretKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token")
+	}
+
+	return &TokenPair{
+		AccessToken:  t,
+		RefreshToken: refreshToken,
+		SessionID:    sess.ID,
+		ExpiresIn:    int64(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// RandomToken returns n random bytes encoded as URL-safe base64.
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex SHA-256 of a high-entropy token, which is what we
+// store instead of the token itself.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,96 @@
+package authentication
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 key of RFC 6238 appendix B, "12345678901234567890"
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// The SHA1 vectors of RFC 6238 appendix B, cut to the last six of their eight
+// digits as six digit codes are.
+func TestValidateTOTPRFC6238Vectors(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(rfc6238Secret, tt.code, time.Unix(tt.unix, 0))
+		if !ok {
+			t.Errorf("ValidateTOTP(%d) rejected %s", tt.unix, tt.code)
+			continue
+		}
+		if want := tt.unix / totpPeriod; step != want {
+			t.Errorf("ValidateTOTP(%d) matched step %d, want %d", tt.unix, step, want)
+		}
+	}
+}
+
+func TestValidateTOTPSkewWindow(t *testing.T) {
+	key := []byte("12345678901234567890")
+	// The last second of step 37037036
+	now := time.Unix(1111111109, 0)
+	current := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name string
+		step int64
+		ok   bool
+	}{
+		{"current step", current, true},
+		{"one step behind", current - 1, true},
+		{"one step ahead", current + 1, true},
+		{"two steps behind", current - 2, false},
+		{"two steps ahead", current + 2, false},
+	}
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(rfc6238Secret, totpCode(key, tt.step), now)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+		}
+		if ok && step != tt.step {
+			t.Errorf("%s: matched step %d, want %d", tt.name, step, tt.step)
+		}
+	}
+
+	// A second later is the next step, which moves the window with it
+	next := now.Add(time.Second)
+	if _, ok := ValidateTOTP(rfc6238Secret, totpCode(key, current-1), next); ok {
+		t.Error("code two steps behind after the step boundary was accepted")
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, totpCode(key, current+2), next); !ok {
+		t.Error("code one step ahead after the step boundary was rejected")
+	}
+}
+
+func TestValidateTOTPInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		ok     bool
+	}{
+		{"surrounding spaces", rfc6238Secret, " 287082 ", true},
+		{"lower case secret", strings.ToLower(rfc6238Secret), "287082", true},
+		{"wrong code", rfc6238Secret, "287083", false},
+		{"too short", rfc6238Secret, "28708", false},
+		{"eight digits", rfc6238Secret, "94287082", false},
+		{"empty", rfc6238Secret, "", false},
+		{"invalid secret", "not base32!", "287082", false},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, now); ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+		}
+	}
+}
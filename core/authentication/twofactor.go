@@ -0,0 +1,24 @@
+package authentication
+
+import "time"
+
+type TwoFactor struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    int    `gorm:"unique;not null"`
+	Secret    string `gorm:"not null"`
+	Enabled   bool
+	EnabledAt *time.Time
+	// LastUsedStep is the TOTP time step of the last accepted code, so a code
+	// cannot be replayed within its validity window.
+	LastUsedStep int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    int    `gorm:"not null;index"`
+	CodeHash  string `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
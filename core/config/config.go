@@ -0,0 +1,192 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the application. Load fills it from, in
+// increasing precedence, the `default` tags, a YAML file, a .env file and
+// the environment variables named by the `env` tags.
+type Config struct {
+	Env       string         `yaml:"env" env:"ENV" default:"development" validate:"oneof=development test production"`
+	HTTP      HTTP           `yaml:"http"`
+	DB        DB             `yaml:"db"`
+	Auth      Auth           `yaml:"auth"`
+	Mail      Mail           `yaml:"mail"`
+	Blob      Blob           `yaml:"blob"`
+	Analysis  Analysis       `yaml:"analysis"`
+	Scheduler Scheduler      `yaml:"scheduler"`
+	Tracing   Tracing        `yaml:"tracing"`
+	Logging   Logging        `yaml:"logging"`
+	OpenAI    OpenAI         `yaml:"openai"`
+	Swagger   Swagger        `yaml:"swagger"`
+	OIDC      []OIDCProvider `yaml:"oidc"`
+}
+
+type HTTP struct {
+	Port int `yaml:"port" env:"PORT" default:"3001" validate:"min=1,max=65535"`
+	// APIURL is the public URL of this API, used in signed file links
+	APIURL string `yaml:"apiUrl" env:"API_URL"`
+	// UIAppURL is the public URL of the web app, used in email links
+	UIAppURL string `yaml:"uiAppUrl" env:"UI_APP_URL"`
+	// ShutdownTimeout bounds how long a stopping server waits for in-flight
+	// requests and background workers to finish
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT" default:"30s"`
+}
+
+type DB struct {
+	Host            string        `yaml:"host" env:"DB_HOST" validate:"required"`
+	Port            int           `yaml:"port" env:"DB_PORT" default:"5432" validate:"min=1,max=65535"`
+	User            string        `yaml:"user" env:"DB_USERNAME" validate:"required"`
+	Password        Secret        `yaml:"password" env:"DB_PASSWORD"`
+	Name            string        `yaml:"name" env:"DB_NAME" validate:"required"`
+	SSLMode         string        `yaml:"sslMode" env:"DB_SSLMODE" default:"require" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	TimeZone        string        `yaml:"timeZone" env:"DB_TIMEZONE" default:"Asia/Jakarta"`
+	MaxOpenConns    int           `yaml:"maxOpenConns" env:"DB_MAX_OPEN_CONNS" default:"100" validate:"min=1"`
+	MaxIdleConns    int           `yaml:"maxIdleConns" env:"DB_MAX_IDLE_CONNS" default:"10" validate:"min=0"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime" env:"DB_CONN_MAX_LIFETIME" default:"1h"`
+}
+
+// DSN is the connection string of the database.
+func (d DB) DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=%s",
+		dsnValue(d.Host), dsnValue(d.User), dsnValue(d.Password.Value()), dsnValue(d.Name), d.Port, d.SSLMode, dsnValue(d.TimeZone))
+}
+
+// dsnValue quotes a connection string value so it may contain spaces and
+// quotes.
+func dsnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+type Auth struct {
+	// JWTSecretKey signs access tokens and file links
+	JWTSecretKey Secret `yaml:"jwtSecretKey" env:"JWT_SECRET_KEY" validate:"required"`
+}
+
+type Mail struct {
+	// Transport is "smtp", "file" writing .eml files to FileDir, or "log"
+	Transport    string `yaml:"transport" env:"MAIL_TRANSPORT" default:"smtp" validate:"oneof=smtp file log"`
+	From         string `yaml:"from" env:"MAIL_FROM" default:"info@vezhguesi.com" validate:"email"`
+	FileDir      string `yaml:"fileDir" env:"MAIL_FILE_DIR" default:"tmp/mail"`
+	SMTPHost     string `yaml:"smtpHost" env:"MAIL_SMTP_HOST" default:"smtp.gmail.com"`
+	SMTPPort     int    `yaml:"smtpPort" env:"MAIL_SMTP_PORT" default:"587" validate:"min=1,max=65535"`
+	SMTPUsername string `yaml:"smtpUsername" env:"EMAIL_FROM"`
+	SMTPPassword Secret `yaml:"smtpPassword" env:"MAIL_PASSWORD"`
+}
+
+type Blob struct {
+	// Store is "local", keeping files under LocalDir, or "s3"
+	Store    string `yaml:"store" env:"BLOB_STORE" default:"local" validate:"oneof=local s3"`
+	LocalDir string `yaml:"localDir" env:"BLOB_LOCAL_DIR" default:"tmp/blobs"`
+	S3       S3     `yaml:"s3"`
+}
+
+type S3 struct {
+	Endpoint        string `yaml:"endpoint" env:"S3_ENDPOINT"`
+	Region          string `yaml:"region" env:"S3_REGION"`
+	Bucket          string `yaml:"bucket" env:"S3_BUCKET"`
+	AccessKeyID     string `yaml:"accessKeyId" env:"S3_ACCESS_KEY_ID"`
+	SecretAccessKey Secret `yaml:"secretAccessKey" env:"S3_SECRET_ACCESS_KEY"`
+	PathStyle       bool   `yaml:"pathStyle" env:"S3_PATH_STYLE"`
+}
+
+// Analysis is the article and analysis server.
+type Analysis struct {
+	URL          string `yaml:"url" env:"SERVER_URL"`
+	ArticlesPort string `yaml:"articlesPort" env:"SERVER_ARTICLES_PORT"`
+	AnalysisPort string `yaml:"analysisPort" env:"SERVER_ANALYSIS_PORT"`
+	APIKey       Secret `yaml:"apiKey" env:"SERVER_API_KEY"`
+}
+
+// ArticlesURL is the base URL of the article endpoints.
+func (a Analysis) ArticlesURL() string {
+	return a.URL + ":" + a.ArticlesPort
+}
+
+// AnalysisURL is the base URL of the analysis endpoints.
+func (a Analysis) AnalysisURL() string {
+	return a.URL + ":" + a.AnalysisPort
+}
+
+// Scheduler runs the periodic jobs, each on one instance at a time.
+// Schedules are "@every <duration>" or five field cron expressions.
+type Scheduler struct {
+	// Enabled may be turned off on instances that should only serve requests
+	Enabled bool `yaml:"enabled" env:"SCHEDULER_ENABLED" default:"true"`
+	// PollInterval is how often due and triggered jobs are looked for
+	PollInterval time.Duration `yaml:"pollInterval" env:"SCHEDULER_POLL_INTERVAL" default:"15s" validate:"min=1"`
+	ArticleFetch string        `yaml:"articleFetch" env:"ARTICLE_FETCH_SCHEDULE" default:"@every 1h" validate:"required"`
+	// LoginAttemptCleanup deletes the login attempts older than a day
+	LoginAttemptCleanup string `yaml:"loginAttemptCleanup" env:"LOGIN_ATTEMPT_CLEANUP_SCHEDULE" default:"@every 1h" validate:"required"`
+}
+
+// Tracing exports OpenTelemetry spans. The otlp exporter is set up by the
+// standard OTEL_EXPORTER_OTLP_* variables and sampling by
+// OTEL_TRACES_SAMPLER, as in any OpenTelemetry SDK.
+type Tracing struct {
+	// Exporter is "none", "otlp" over HTTP, or "console" writing to stdout
+	Exporter    string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" default:"none" validate:"oneof=none otlp console"`
+	ServiceName string `yaml:"serviceName" env:"OTEL_SERVICE_NAME" default:"vezhguesi" validate:"required"`
+}
+
+// Logging configures the structured logs written to stdout. Subsystems are
+// the parts of the app logging separately, e.g. http, db, scheduler or
+// reports.
+type Logging struct {
+	// Level is the minimum level of the subsystems not listed in Levels
+	Level string `yaml:"level" env:"LOG_LEVEL" default:"info" validate:"oneof=debug info warn error"`
+	// Levels overrides the level of some subsystems, e.g.
+	// "db=debug,scheduler=warn"
+	Levels string `yaml:"levels" env:"LOG_LEVELS"`
+	// Format is "json" or "text", by default json in production and text
+	// elsewhere
+	Format string `yaml:"format" env:"LOG_FORMAT" validate:"omitempty,oneof=json text"`
+	// SlowQuery is the duration above which queries are logged as warnings
+	SlowQuery time.Duration `yaml:"slowQuery" env:"LOG_SLOW_QUERY" default:"500ms" validate:"min=1"`
+}
+
+// SubsystemLevels parses Levels into the level of each listed subsystem.
+func (l Logging) SubsystemLevels() (map[string]string, error) {
+	levels := make(map[string]string)
+	for _, entry := range strings.Split(l.Levels, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		subsystem, level, ok := strings.Cut(entry, "=")
+		subsystem, level = strings.TrimSpace(subsystem), strings.TrimSpace(level)
+		switch {
+		case !ok || subsystem == "":
+			return nil, fmt.Errorf("log level %q is not <subsystem>=<level>", entry)
+		case level != "debug" && level != "info" && level != "warn" && level != "error":
+			return nil, fmt.Errorf("log level of %s must be one of debug, info, warn or error", subsystem)
+		}
+		levels[subsystem] = level
+	}
+	return levels, nil
+}
+
+type OpenAI struct {
+	APIKey Secret `yaml:"apiKey" env:"OPENAI_API_KEY"`
+}
+
+// Swagger protects the API docs with basic auth. The docs are not served
+// unless both are set.
+type Swagger struct {
+	Username string `yaml:"username" env:"SWAGGER_USERNAME"`
+	Password Secret `yaml:"password" env:"SWAGGER_PASSWORD"`
+}
+
+// OIDCProvider is a single sign-on provider. From the environment they are
+// listed in OIDC_PROVIDERS, e.g. "google,keycloak", each configured with
+// OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and _REDIRECT_URL.
+type OIDCProvider struct {
+	Name         string `yaml:"name" validate:"required"`
+	IssuerURL    string `yaml:"issuerUrl" validate:"required"`
+	ClientID     string `yaml:"clientId" validate:"required"`
+	ClientSecret Secret `yaml:"clientSecret"`
+	RedirectURL  string `yaml:"redirectUrl" validate:"required"`
+}
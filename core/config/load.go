@@ -0,0 +1,233 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/validation"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is read when CONFIG_FILE is not set, if it exists.
+const DefaultFile = "config.yaml"
+
+// Load reads the configuration and validates it. Outside production a .env
+// file in the working directory is loaded into the environment first,
+// without overriding variables that are already set.
+func Load() (*Config, error) {
+	if os.Getenv("ENV") != "production" {
+		// A missing .env is fine, everything may come from the environment
+		_ = godotenv.Load()
+	}
+
+	cfg := &Config{}
+	if err := setDefaults(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+
+	path, explicit := os.LookupEnv("CONFIG_FILE")
+	if !explicit {
+		path = DefaultFile
+	}
+	if err := loadFile(cfg, path, explicit); err != nil {
+		return nil, err
+	}
+
+	if err := setFromEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+	if cfg.Env == "test" {
+		loadTestDB(&cfg.DB)
+	}
+	if providers := oidcProvidersFromEnv(); providers != nil {
+		cfg.OIDC = providers
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the `validate` tags and the settings that depend on each
+// other, returning every problem at once.
+func (c *Config) Validate() error {
+	var problems []string
+	if err := validation.Struct(c); err != nil {
+		fields, _ := apperr.From(err).Details["fields"].([]validation.FieldError)
+		for _, f := range fields {
+			problems = append(problems, f.Message)
+		}
+	}
+	if c.Blob.Store == "s3" {
+		if c.Blob.S3.Endpoint == "" || c.Blob.S3.Bucket == "" || c.Blob.S3.AccessKeyID == "" || c.Blob.S3.SecretAccessKey == "" {
+			problems = append(problems, "blob.s3 endpoint, bucket, accessKeyId and secretAccessKey are required with the s3 store")
+		}
+	}
+	if _, err := c.Logging.SubsystemLevels(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if (c.Swagger.Username == "") != (c.Swagger.Password == "") {
+		problems = append(problems, "swagger username and password must be set together")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// String is the configuration as YAML with secrets redacted.
+func (c *Config) String() string {
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+// loadFile reads the YAML file at path. A missing file is only an error when
+// it was asked for explicitly.
+func loadFile(cfg *Config, path string, explicit bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func setDefaults(v reflect.Value) error {
+	return walk(v, func(field reflect.Value, sf reflect.StructField) error {
+		def, ok := sf.Tag.Lookup("default")
+		if !ok {
+			return nil
+		}
+		if err := setValue(field, def); err != nil {
+			return fmt.Errorf("invalid default of %s: %w", sf.Name, err)
+		}
+		return nil
+	})
+}
+
+// setFromEnv overrides the fields whose `env` variable is set and not empty.
+func setFromEnv(v reflect.Value) error {
+	return walk(v, func(field reflect.Value, sf reflect.StructField) error {
+		name := sf.Tag.Get("env")
+		if name == "" {
+			return nil
+		}
+		value := os.Getenv(name)
+		if value == "" {
+			return nil
+		}
+		if err := setValue(field, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// walk calls fn for every field of v, descending into nested structs.
+func walk(v reflect.Value, fn func(field reflect.Value, sf reflect.StructField) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, sf := v.Field(i), t.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := walk(field, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(field, sf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setValue(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// loadTestDB switches to the TEST_DB_* database when running the tests.
+func loadTestDB(db *DB) {
+	db.SSLMode = "prefer"
+	for name, target := range map[string]*string{
+		"TEST_DB_HOST":     &db.Host,
+		"TEST_DB_USERNAME": &db.User,
+		"TEST_DB_NAME":     &db.Name,
+		"TEST_DB_SSLMODE":  &db.SSLMode,
+	} {
+		if value := os.Getenv(name); value != "" {
+			*target = value
+		}
+	}
+	if value := os.Getenv("TEST_DB_PASSWORD"); value != "" {
+		db.Password = Secret(value)
+	}
+	if port, err := strconv.Atoi(os.Getenv("TEST_DB_PORT")); err == nil {
+		db.Port = port
+	}
+}
+
+// oidcProvidersFromEnv reads the providers listed in OIDC_PROVIDERS, nil
+// when it is not set.
+func oidcProvidersFromEnv() []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, OIDCProvider{
+			Name:         name,
+			IssuerURL:    os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: Secret(os.Getenv(prefix + "CLIENT_SECRET")),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+		})
+	}
+	return providers
+}
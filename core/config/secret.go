@@ -0,0 +1,36 @@
+package config
+
+import "log/slog"
+
+const redacted = "[REDACTED]"
+
+// Secret is a string that is redacted whenever it is printed or marshalled,
+// so logging a config never leaks it. Value returns the secret itself.
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
@@ -1,70 +1,31 @@
 package db
 
 import (
-	"fmt"
-	"os"
-	"strconv"
-	"time"
+	"vezhguesi/core/config"
+	"vezhguesi/core/logging"
+	"vezhguesi/core/metrics"
+	"vezhguesi/core/tracing"
 
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
-func getEnvAsInt(name string, defaultVal int) int {
-	valueStr := os.Getenv(name)
-	if value, err := strconv.Atoi(valueStr); err == nil {
-		return value
-	}
-	return defaultVal
-}
-
-func ConnectDB() (*gorm.DB, error) {
-	// Load .env file only in development
-	if os.Getenv("ENV") != "production" {
-		err := godotenv.Load()
-		if err != nil {
-			fmt.Print("Error loading .env file")
-		}
-	}
-
-	var dbhost, dbuser, dbpassword, dbname string
-	var dbport int
-
-	// Determine which database configuration to use based on the ENV variable
-	fmt.Println("Env", os.Getenv("ENV"))
-	var dsn string
-	switch os.Getenv("ENV") {
-	case "test":
-		dbhost = os.Getenv("TEST_DB_HOST")
-		dbport = getEnvAsInt("TEST_DB_PORT", 5432)
-		dbuser = os.Getenv("TEST_DB_USERNAME")
-		dbpassword = os.Getenv("TEST_DB_PASSWORD")
-		dbname = os.Getenv("TEST_DB_NAME")
-		dsn = fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d  TimeZone=Asia/Jakarta", dbhost, dbuser, dbpassword, dbname, dbport)
-	default:
-		dbhost = os.Getenv("DB_HOST")
-		dbport = getEnvAsInt("DB_PORT", 5432)
-		dbuser = os.Getenv("DB_USERNAME")
-		dbpassword = os.Getenv("DB_PASSWORD")
-		dbname = os.Getenv("DB_NAME")
-		dsn = fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=require TimeZone=Asia/Jakarta", dbhost, dbuser, dbpassword, dbname, dbport)
-	}
-
-	fmt.Printf("Connecting to DB at %s:%d with user %s\n", dbhost, dbport, dbuser)
+func ConnectDB(cfg config.DB) (*gorm.DB, error) {
+	logger := logging.Logger("db")
+	logger.Infow("connecting to the database", "host", cfg.Host, "port", cfg.Port, "user", cfg.User)
 
 	// Use pgx as the driver
 
 	// Open the database connection using postgres with pgx
 	db, err := gorm.Open(postgres.New(postgres.Config{
-		DSN:                  dsn,
+		DSN:                  cfg.DSN(),
 		PreferSimpleProtocol: true, // disables implicit prepared statement usage
 	}), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info), // Enable detailed logging
+		// Failed and slow queries, and every query at debug, without values
+		Logger: logging.Gorm(),
 	})
 	if err != nil {
-		fmt.Printf("Failed to connect to database: %v\n", err)
+		logger.Errorf("func: ConnectDB, operation: gorm.Open, err: %s", err.Error())
 		return nil, err
 	}
 
@@ -73,9 +34,19 @@ func ConnectDB() (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	// Time queries and expose the pool on /metrics, and trace queries made
+	// within a request
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	metrics.RegisterDBStats(sqlDB)
 
 	return db, nil
 }
@@ -0,0 +1,52 @@
+// Package dbtest connects tests to the TEST_DB_* database.
+package dbtest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"vezhguesi/core/config"
+	"vezhguesi/core/db"
+	"vezhguesi/core/db/migrations"
+	"vezhguesi/core/logging"
+
+	"gorm.io/gorm"
+)
+
+// SecretKey is the JWT secret of the test configuration.
+const SecretKey = "test-secret-key"
+
+// Open connects to the TEST_DB_* database and applies the migrations. The
+// test is skipped when TEST_DB_HOST is not set.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	if os.Getenv("TEST_DB_HOST") == "" {
+		t.Skip("TEST_DB_HOST is not set")
+	}
+	t.Setenv("ENV", "test")
+	t.Setenv("JWT_SECRET_KEY", SecretKey)
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := db.ConnectDB(cfg.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrator, err := migrations.NewMigrator(conn, logging.Logger("migrations"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
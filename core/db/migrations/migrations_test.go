@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, m := range migrations {
+		if want := int64(i + 1); m.Version != want {
+			t.Errorf("migration %s has version %d, want %d", m, m.Version, want)
+		}
+		if m.Version > 1 && m.Down == "" {
+			t.Errorf("migration %s has no down file", m)
+		}
+	}
+
+	// The baseline is the schema from before versioned migrations, later
+	// additions must not creep into it
+	baseline := migrations[0]
+	if baseline.Name != "baseline" || baseline.Down != "" {
+		t.Fatalf("first migration is %s, want the irreversible baseline", baseline)
+	}
+	for _, added := range []string{`"locale"`, `"require_two_factor"`, `"impersonator_id"`, `"revoked_at"`, `"usage_events"`, `"audit_logs"`, `"outbox_emails"`, `"api_keys"`} {
+		if strings.Contains(baseline.Up, added) {
+			t.Errorf("baseline holds %s, added after it", added)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := load(fstest.MapFS{
+		"sql/0001_baseline.up.sql": {Data: []byte("CREATE TABLE a ();")},
+		"sql/0002_b.up.sql":        {Data: []byte("CREATE TABLE b ();")},
+		"sql/0002_b.down.sql":      {Data: []byte("DROP TABLE b;")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Down != "" || migrations[1].Down != "DROP TABLE b;" {
+		t.Errorf("load = %+v", migrations)
+	}
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"down without up": {"sql/0001_a.down.sql": {Data: []byte("DROP TABLE a;")}},
+		"two names":       {"sql/0001_a.up.sql": {Data: []byte("SELECT 1;")}, "sql/0001_b.down.sql": {Data: []byte("SELECT 1;")}},
+		"bad file name":   {"sql/a.up.sql": {Data: []byte("SELECT 1;")}},
+	} {
+		if _, err := load(fsys); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+	"gorm.io/gorm"
+)
+
+// lockKey is the Postgres advisory lock held while migrating, so instances
+// started by concurrent deploys apply each migration only once.
+const lockKey int64 = 0x76657a686d6967 // "vezhmig"
+
+const createTableSQL = `CREATE TABLE IF NOT EXISTS "schema_migrations" (
+    "version" bigint PRIMARY KEY,
+    "name" text NOT NULL,
+    "applied_at" timestamptz NOT NULL
+)`
+
+// Migrator applies and rolls back the embedded migrations, recording applied
+// versions in schema_migrations.
+type Migrator struct {
+	db         *gorm.DB
+	logger     log.AllLogger
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB, logger log.AllLogger) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, logger: logger, migrations: migrations}, nil
+}
+
+// Up applies every pending migration in order and returns how many were
+// applied. Each migration runs in its own transaction.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		versions, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s up: %w", migration, err)
+			}
+			m.logger.Infof("func: Migrator.Up, migration: %s, applied", migration)
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and
+// returns how many were rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	rolledBack := 0
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		var rows []schemaMigration
+		if err := conn.Order("version DESC").Limit(steps).Find(&rows).Error; err != nil {
+			return err
+		}
+
+		// Check every step first so none is rolled back when a later one
+		// can't be
+		steps := make([]Migration, 0, len(rows))
+		for _, row := range rows {
+			migration, ok := m.find(row.Version)
+			if !ok {
+				return fmt.Errorf("migration %04d_%s is applied but unknown to this build", row.Version, row.Name)
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %s can't be rolled back", migration)
+			}
+			steps = append(steps, migration)
+		}
+
+		for _, migration := range steps {
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{}, "version = ?", migration.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s down: %w", migration, err)
+			}
+			m.logger.Infof("func: Migrator.Down, migration: %s, rolled back", migration)
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status lists every known migration with the time it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn := m.db.WithContext(ctx)
+	versions := map[int64]time.Time{}
+	if conn.Migrator().HasTable(&schemaMigration{}) {
+		var err error
+		if versions, err = appliedVersions(conn); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := versions[migration.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// withLock runs fn on a single connection holding the advisory lock, after
+// making sure schema_migrations exists. Session level advisory locks belong
+// to a connection, so the lock is taken and released on the same one.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return fmt.Errorf("failed to take the migration lock: %w", err)
+		}
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(?)", lockKey).Error; err != nil {
+				m.logger.Errorf("func: Migrator.withLock, operation: pg_advisory_unlock, err: %s", err.Error())
+			}
+		}()
+
+		if err := conn.Exec(createTableSQL).Error; err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func appliedVersions(db *gorm.DB) (map[int64]time.Time, error) {
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	versions := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		versions[row.Version] = row.AppliedAt
+	}
+	return versions, nil
+}
@@ -0,0 +1,222 @@
+-- Baseline schema, as AutoMigrate created it before versioned migrations.
+-- Every statement is guarded so databases created by AutoMigrate adopt it
+-- unchanged. Later additions go in their own migrations, never here.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" bigserial,
+    "email" text,
+    "username" text,
+    "password" text,
+    "first_name" text,
+    "last_name" text,
+    "status" text,
+    "avatar_img_key" text,
+    "active" boolean,
+    "phone" text,
+    "verified_email" boolean,
+    "role" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_users_email" UNIQUE ("email"),
+    CONSTRAINT "uni_users_username" UNIQUE ("username")
+);
+
+CREATE TABLE IF NOT EXISTS "roles" (
+    "id" bigserial,
+    "org_id" bigint,
+    "name" text NOT NULL,
+    "description" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "permissions" (
+    "id" bigserial,
+    "name" text,
+    "http_methods" text,
+    "path" text,
+    "description" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "role_permissions" (
+    "role_id" bigint,
+    "permission_id" bigint,
+    PRIMARY KEY ("role_id","permission_id"),
+    CONSTRAINT "fk_role_permissions_permission" FOREIGN KEY ("permission_id") REFERENCES "permissions"("id"),
+    CONSTRAINT "fk_role_permissions_role" FOREIGN KEY ("role_id") REFERENCES "roles"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "sessions" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "session_token" text NOT NULL,
+    "created_at" timestamptz,
+    "expires_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_sessions_session_token" UNIQUE ("session_token")
+);
+
+CREATE TABLE IF NOT EXISTS "reports" (
+    "id" bigserial,
+    "title" text NOT NULL,
+    "subject" text NOT NULL,
+    "user_id" bigint,
+    "report_text" text,
+    "source_id" bigint,
+    "findings" text,
+    "sentiment" bigint,
+    "start_date" timestamptz,
+    "end_date" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_reports_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "entities" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "type" text,
+    "related_topics" json,
+    "sentiment_label" text,
+    "sentiment_score" decimal,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "report_entities" (
+    "report_id" bigint,
+    "entity_id" bigint,
+    PRIMARY KEY ("report_id","entity_id"),
+    CONSTRAINT "fk_report_entities_report" FOREIGN KEY ("report_id") REFERENCES "reports"("id"),
+    CONSTRAINT "fk_report_entities_entity" FOREIGN KEY ("entity_id") REFERENCES "entities"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "subscriptions" (
+    "id" bigserial,
+    "name" text,
+    "description" text,
+    "price" decimal,
+    "currency" text,
+    "duration_type" text,
+    "duration_time" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "orgs" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "slug" text NOT NULL,
+    "size" text NOT NULL,
+    "subscription_id" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_orgs_subscription" FOREIGN KEY ("subscription_id") REFERENCES "subscriptions"("id"),
+    CONSTRAINT "uni_orgs_slug" UNIQUE ("slug")
+);
+CREATE INDEX IF NOT EXISTS "idx_roles_deleted_at" ON "roles" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "user_org_roles" (
+    "user_id" bigint,
+    "org_id" bigint,
+    "role_id" bigint,
+    "status" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    CONSTRAINT "fk_user_org_roles_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_user_org_roles_role" FOREIGN KEY ("role_id") REFERENCES "roles"("id"),
+    CONSTRAINT "fk_orgs_user_org_role" FOREIGN KEY ("org_id") REFERENCES "orgs"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "features" (
+    "id" bigserial,
+    "subscription_id" bigint,
+    "key" text,
+    "value" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_subscriptions_features" FOREIGN KEY ("subscription_id") REFERENCES "subscriptions"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "urls" (
+    "id" bigserial,
+    "path" text NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_urls_deleted_at" ON "urls" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "articles" (
+    "id" bigserial,
+    "config_id" bigint,
+    "url_id" bigint,
+    "title" text,
+    "content" text,
+    "published_date" timestamptz,
+    "scraped_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_articles_url" FOREIGN KEY ("url_id") REFERENCES "urls"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "article_entities" (
+    "article_id" bigint,
+    "entity_name" text,
+    "sentiment_score" decimal,
+    "sentiment_label" text,
+    PRIMARY KEY ("article_id","entity_name"),
+    CONSTRAINT "fk_articles_entity_relations" FOREIGN KEY ("article_id") REFERENCES "articles"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "entity_reports" (
+    "id" bigserial,
+    "entity_id" bigint NOT NULL,
+    "summary" text,
+    "article_count" bigint NOT NULL,
+    "last_analyzed" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_entity_reports_entity" FOREIGN KEY ("entity_id") REFERENCES "entities"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "user_entity_reports" (
+    "user_id" bigint,
+    "entity_report_id" bigint,
+    "created_at" timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS "entity_report_articles" (
+    "entity_report_id" bigint,
+    "article_id" bigint,
+    "created_at" timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS "analyses" (
+    "id" bigserial,
+    "article_id" bigint,
+    "article_summary" text,
+    "entities" json,
+    "topics" json,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_analyses_article" FOREIGN KEY ("article_id") REFERENCES "articles"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_article" ON "analyses" ("article_id");
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "idx_article_entities_entity_name";
+DROP INDEX IF EXISTS "idx_user_entity_reports_user_report";
+DROP INDEX IF EXISTS "idx_entity_report_articles_report_article";
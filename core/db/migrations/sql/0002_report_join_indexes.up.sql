@@ -0,0 +1,26 @@
+-- The report join tables had no keys, so the same article or user could be
+-- linked to a report twice. Drop existing duplicates before adding the
+-- unique indexes.
+
+DELETE FROM "entity_report_articles" a
+USING "entity_report_articles" b
+WHERE a.ctid > b.ctid
+  AND a."entity_report_id" = b."entity_report_id"
+  AND a."article_id" = b."article_id";
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_entity_report_articles_report_article"
+    ON "entity_report_articles" ("entity_report_id", "article_id");
+
+DELETE FROM "user_entity_reports" a
+USING "user_entity_reports" b
+WHERE a.ctid > b.ctid
+  AND a."user_id" = b."user_id"
+  AND a."entity_report_id" = b."entity_report_id";
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_entity_reports_user_report"
+    ON "user_entity_reports" ("user_id", "entity_report_id");
+
+-- Reports look up article mentions by entity name, the primary key of
+-- article_entities starts with article_id
+CREATE INDEX IF NOT EXISTS "idx_article_entities_entity_name"
+    ON "article_entities" ("entity_name");
@@ -0,0 +1,17 @@
+-- One row per scheduled job, shared by every instance of the app. The
+-- instance holding the job's advisory lock runs it when next_run_at is due
+-- or triggered_at is set.
+CREATE TABLE IF NOT EXISTS "scheduled_jobs" (
+    "name" text,
+    "schedule" text NOT NULL,
+    "next_run_at" timestamptz NOT NULL,
+    "triggered_at" timestamptz,
+    "last_status" text,
+    "last_error" text,
+    "last_instance" text,
+    "last_started_at" timestamptz,
+    "last_finished_at" timestamptz,
+    "last_duration_ms" bigint,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("name")
+);
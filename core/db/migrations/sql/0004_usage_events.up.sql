@@ -0,0 +1,15 @@
+-- Metered LLM tokens and analysis calls
+CREATE TABLE IF NOT EXISTS "usage_events" (
+    "id" bigserial,
+    "org_id" bigint,
+    "user_id" bigint,
+    "kind" text NOT NULL,
+    "model" text,
+    "prompt_tokens" bigint,
+    "completion_tokens" bigint,
+    "articles_analyzed" bigint,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_usage_org_created" ON "usage_events" ("org_id","created_at");
+CREATE INDEX IF NOT EXISTS "idx_usage_events_user_id" ON "usage_events" ("user_id");
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS "refresh_tokens";
+DROP INDEX IF EXISTS "idx_sessions_user_id";
+DROP INDEX IF EXISTS "idx_sessions_impersonator_id";
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS "impersonator_id";
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS "revoked_at";
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS "last_used_at";
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS "ip";
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS "user_agent";
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS "device_name";
@@ -0,0 +1,22 @@
+-- Sessions per device, revocable and possibly started by an admin
+-- impersonating the user, with rotating refresh tokens
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS "device_name" text;
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS "user_agent" text;
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS "ip" text;
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS "last_used_at" timestamptz;
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS "revoked_at" timestamptz;
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS "impersonator_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_sessions_impersonator_id" ON "sessions" ("impersonator_id");
+CREATE INDEX IF NOT EXISTS "idx_sessions_user_id" ON "sessions" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "refresh_tokens" (
+    "id" bigserial,
+    "session_id" bigint NOT NULL,
+    "token_hash" text NOT NULL,
+    "used_at" timestamptz,
+    "expires_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_refresh_tokens_token_hash" UNIQUE ("token_hash")
+);
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_session_id" ON "refresh_tokens" ("session_id");
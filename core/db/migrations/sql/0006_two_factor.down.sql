@@ -0,0 +1,3 @@
+ALTER TABLE "orgs" DROP COLUMN IF EXISTS "require_two_factor";
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "two_factors";
@@ -0,0 +1,25 @@
+-- TOTP two-factor authentication, and the org policy requiring it
+CREATE TABLE IF NOT EXISTS "two_factors" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "secret" text NOT NULL,
+    "enabled" boolean,
+    "enabled_at" timestamptz,
+    "last_used_step" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_two_factors_user_id" UNIQUE ("user_id")
+);
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "code_hash" text NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+
+ALTER TABLE "orgs" ADD COLUMN IF NOT EXISTS "require_two_factor" boolean NOT NULL DEFAULT false;
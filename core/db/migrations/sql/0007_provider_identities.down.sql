@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "o_auth_states";
+DROP TABLE IF EXISTS "provider_identities";
@@ -0,0 +1,25 @@
+-- OpenID Connect logins
+CREATE TABLE IF NOT EXISTS "provider_identities" (
+    "id" bigserial,
+    "provider" text NOT NULL,
+    "subject" text NOT NULL,
+    "user_id" bigint NOT NULL,
+    "email" text,
+    "last_login_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_provider_identities_user_id" ON "provider_identities" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_provider_subject" ON "provider_identities" ("provider","subject");
+
+CREATE TABLE IF NOT EXISTS "o_auth_states" (
+    "id" bigserial,
+    "state" text NOT NULL,
+    "provider" text NOT NULL,
+    "code_verifier" text NOT NULL,
+    "nonce" text NOT NULL,
+    "expires_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_o_auth_states_state" UNIQUE ("state")
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "account_lockouts";
+DROP TABLE IF EXISTS "login_attempts";
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS "login_attempts" (
+    "id" bigserial,
+    "email" text NOT NULL,
+    "ip" text NOT NULL,
+    "success" boolean NOT NULL DEFAULT false,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_login_attempts_created_at" ON "login_attempts" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_login_attempts_ip" ON "login_attempts" ("ip");
+CREATE INDEX IF NOT EXISTS "idx_login_attempts_email" ON "login_attempts" ("email");
+
+CREATE TABLE IF NOT EXISTS "account_lockouts" (
+    "id" bigserial,
+    "email" text NOT NULL,
+    "locked_until" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_account_lockouts_email" ON "account_lockouts" ("email");
@@ -0,0 +1,16 @@
+-- Single-use tokens of email verification, password resets and invites
+CREATE TABLE IF NOT EXISTS "one_time_tokens" (
+    "id" bigserial,
+    "user_id" bigint,
+    "purpose" text NOT NULL,
+    "token_hash" text NOT NULL,
+    "email" text,
+    "data" text,
+    "expires_at" timestamptz NOT NULL,
+    "consumed_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_one_time_tokens_token_hash" UNIQUE ("token_hash")
+);
+CREATE INDEX IF NOT EXISTS "idx_one_time_tokens_purpose" ON "one_time_tokens" ("purpose");
+CREATE INDEX IF NOT EXISTS "idx_one_time_tokens_user_id" ON "one_time_tokens" ("user_id");
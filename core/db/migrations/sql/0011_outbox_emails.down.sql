@@ -0,0 +1,2 @@
+ALTER TABLE "users" DROP COLUMN IF EXISTS "locale";
+DROP TABLE IF EXISTS "outbox_emails";
@@ -0,0 +1,22 @@
+-- Emails are queued in the transaction of the change sending them, in the
+-- user's language
+CREATE TABLE IF NOT EXISTS "outbox_emails" (
+    "id" bigserial,
+    "to" text NOT NULL,
+    "template" text NOT NULL,
+    "locale" text NOT NULL,
+    "subject" text NOT NULL,
+    "text_body" text,
+    "html_body" text,
+    "status" text NOT NULL,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "last_error" text,
+    "next_attempt_at" timestamptz NOT NULL,
+    "sent_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_outbox_emails_next_attempt_at" ON "outbox_emails" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_outbox_emails_status" ON "outbox_emails" ("status");
+
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "locale" text NOT NULL DEFAULT 'en';
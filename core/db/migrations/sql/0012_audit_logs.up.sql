@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS "audit_logs" (
+    "id" bigserial,
+    "actor_user_id" bigint,
+    "api_key_id" bigint,
+    "impersonator_user_id" bigint,
+    "org_id" bigint,
+    "action" text NOT NULL,
+    "target_type" text,
+    "target_id" text,
+    "changes" text,
+    "ip" text,
+    "user_agent" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_target_id" ON "audit_logs" ("target_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_target_type" ON "audit_logs" ("target_type");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_action" ON "audit_logs" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_org_id" ON "audit_logs" ("org_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_impersonator_user_id" ON "audit_logs" ("impersonator_user_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_actor_user_id" ON "audit_logs" ("actor_user_id");
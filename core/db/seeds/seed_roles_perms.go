@@ -1,8 +1,8 @@
 package seeds
 
 import (
-	"fmt"
 	rolesvc "vezhguesi/core/authorization/role"
+	"vezhguesi/core/logging"
 
 	"gorm.io/gorm"
 )
@@ -320,6 +320,7 @@ var defaultRoleMappingPerms map[string][]rolesvc.Permission = map[string][]roles
 
 // db *gorm.DB
 func SeedDefaultRolesAndPermissions(db *gorm.DB) {
+	logger := logging.Logger("db")
 	var roles []string = []string{Owner, Admin, Coach, SME, ClientAlumn, ClientCurrent, ClientFuture, Partner, Guest}
 	for _, role := range roles {
 		// Handle role
@@ -332,7 +333,7 @@ func SeedDefaultRolesAndPermissions(db *gorm.DB) {
 			rl.Name = role
 			result = db.Omit("UpdatedAt").Create(&rl)
 			if result.Error != nil {
-				fmt.Println("Create(&rl).err", result.Error.Error())
+				logger.Errorf("func: SeedDefaultRolesAndPermissions, operation: db.Create(&rl), role: %s, err: %s", role, result.Error.Error())
 			}
 		}
 
@@ -347,12 +348,11 @@ func SeedDefaultRolesAndPermissions(db *gorm.DB) {
 				prm.HTTPMethods = perm.HTTPMethods // Correct field name
 				prm.Path = perm.Path               // Correct field name
 
-				// Log the JSON data before insertion
-				fmt.Printf("Inserting Permission: Name=%s, HTTPMethods=%v, Path=%v\n", prm.Name, prm.HTTPMethods, prm.Path)
+				logger.Debugf("func: SeedDefaultRolesAndPermissions, inserting permission: %s, methods: %v, path: %v", prm.Name, prm.HTTPMethods, prm.Path)
 
 				result = db.Omit("UpdatedAt").Create(&prm)
 				if result.Error != nil {
-					fmt.Println("Create(&prm).err", result.Error.Error())
+					logger.Errorf("func: SeedDefaultRolesAndPermissions, operation: db.Create(&prm), permission: %s, err: %s", prm.Name, result.Error.Error())
 				}
 			}
 
@@ -360,7 +360,7 @@ func SeedDefaultRolesAndPermissions(db *gorm.DB) {
 			rl.Permissions = append(rl.Permissions, prm)
 			result = db.Save(&rl)
 			if result.Error != nil {
-				fmt.Println("db.Save(&rl)", result.Error.Error())
+				logger.Errorf("func: SeedDefaultRolesAndPermissions, operation: db.Save(&rl), role: %s, err: %s", role, result.Error.Error())
 			}
 		}
 	}
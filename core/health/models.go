@@ -0,0 +1,61 @@
+package health
+
+import "time"
+
+// Check statuses
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusDisabled = "disabled"
+)
+
+// Overall statuses
+const (
+	StatusReady       = "ready"
+	StatusUnavailable = "unavailable"
+	StatusDegraded    = "degraded"
+)
+
+// Check is the outcome of checking one dependency. Messages never carry raw
+// errors, the endpoints are not authenticated.
+type Check struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latencyMs"`
+	Message   string `json:"message,omitempty"`
+}
+
+type LivenessResponse struct {
+	Status string `json:"status"`
+}
+
+type ReadinessResponse struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks"`
+}
+
+type ArticleSync struct {
+	// LastRunAt and LastRunStatus are of the last scheduled fetch
+	LastRunAt     *time.Time `json:"lastRunAt"`
+	LastRunStatus string     `json:"lastRunStatus,omitempty"`
+	NextRunAt     *time.Time `json:"nextRunAt"`
+	// NewestArticleAt is when the newest stored article was scraped, and
+	// LagSeconds how long ago that was
+	NewestArticleAt *time.Time `json:"newestArticleAt"`
+	LagSeconds      *int64     `json:"lagSeconds"`
+}
+
+type Queue struct {
+	EmailsPending int64 `json:"emailsPending"`
+	EmailsFailed  int64 `json:"emailsFailed"`
+	// OldestPendingEmailSeconds is the age of the oldest undelivered email
+	OldestPendingEmailSeconds *int64 `json:"oldestPendingEmailSeconds"`
+	// JobsDue are scheduled jobs past their run time or triggered
+	JobsDue int64 `json:"jobsDue"`
+}
+
+type StatusResponse struct {
+	Status      string           `json:"status"`
+	Checks      map[string]Check `json:"checks"`
+	ArticleSync ArticleSync      `json:"articleSync"`
+	Queue       Queue            `json:"queue"`
+}
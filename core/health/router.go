@@ -0,0 +1,17 @@
+package health
+
+import (
+	"vezhguesi/core/middleware"
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterRoutes adds the probes at the root of router, outside /api and
+// without authentication, for Kubernetes and load balancers. The dependency
+// status calls the upstreams, so it is for admins only.
+func RegisterRoutes(router fiber.Router, healthHttpApi HealthHTTPTransport, authMiddleware fiber.Handler) {
+	router.Get("/healthz", healthHttpApi.Live)
+	router.Get("/readyz", healthHttpApi.Ready)
+	router.Get("/status", authMiddleware, middleware.RequireRole(helper.AdminRoleName), healthHttpApi.Status)
+}
@@ -0,0 +1,303 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"vezhguesi/core/config"
+	"vezhguesi/core/db/migrations"
+	"vezhguesi/core/mailer"
+	"vezhguesi/core/scheduler"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/gorm"
+)
+
+// checkTimeout bounds each dependency check, a probe must answer before
+// Kubernetes gives up on it
+const checkTimeout = 3 * time.Second
+
+// statusCacheTTL is how long a status report is served again, so frequent
+// requests do not each call the upstreams and OpenAI
+const statusCacheTTL = 5 * time.Second
+
+type healthApi struct {
+	db              *gorm.DB
+	logger          log.AllLogger
+	migrator        *migrations.Migrator
+	analysis        config.Analysis
+	openAIKey       string
+	articleFetchJob string
+	client          *http.Client
+
+	// statusMu is held while a report is made, concurrent requests wait
+	// for it and share it
+	statusMu sync.Mutex
+	status   *StatusResponse
+	statusAt time.Time
+}
+
+type HealthAPI interface {
+	Live() (res *LivenessResponse)
+	Ready(ctx context.Context) (res *ReadinessResponse)
+	Status(ctx context.Context) (res *StatusResponse, err error)
+}
+
+// NewHealthAPI checks the database and migrations through db and migrator,
+// the article and analysis server at analysis, OpenAI with openAIKey, and
+// reports the runs of the scheduled job named articleFetchJob.
+func NewHealthAPI(db *gorm.DB, logger log.AllLogger, migrator *migrations.Migrator, analysis config.Analysis, openAIKey string, articleFetchJob string) HealthAPI {
+	return &healthApi{
+		db:              db,
+		logger:          logger,
+		migrator:        migrator,
+		analysis:        analysis,
+		openAIKey:       openAIKey,
+		articleFetchJob: articleFetchJob,
+		client:          &http.Client{Timeout: checkTimeout},
+	}
+}
+
+// @Summary      	Liveness
+// @Description		Answers as long as the process serves requests.
+// @Tags			Health
+// @Produce			json
+// @Success			200					{object}	LivenessResponse
+// @Router			/healthz	[GET]
+func (s *healthApi) Live() (res *LivenessResponse) {
+	return &LivenessResponse{Status: StatusOK}
+}
+
+// @Summary      	Readiness
+// @Description		Ready when the database answers and every migration of this build is applied.
+// @Tags			Health
+// @Produce			json
+// @Success			200					{object}	ReadinessResponse
+// @Failure			503					{object}	ReadinessResponse
+// @Router			/readyz	[GET]
+func (s *healthApi) Ready(ctx context.Context) (res *ReadinessResponse) {
+	checks := s.runChecks(ctx, map[string]func(ctx context.Context) Check{
+		"database":   s.checkDatabase,
+		"migrations": s.checkMigrations,
+	})
+
+	res = &ReadinessResponse{Status: StatusReady, Checks: checks}
+	for _, check := range checks {
+		if check.Status != StatusOK {
+			res.Status = StatusUnavailable
+		}
+	}
+	return res
+}
+
+// @Summary      	Dependency Status
+// @Description		Reports the database, migrations, article scraper, analysis service and LLM provider, when articles were last synced and the email and job backlog. Degraded when any dependency fails. Admins only; reports are reused for 5 seconds.
+// @Tags			Health
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Success			200					{object}	StatusResponse
+// @Router			/status	[GET]
+func (s *healthApi) Status(ctx context.Context) (res *StatusResponse, err error) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+
+	if s.status != nil && time.Since(s.statusAt) < statusCacheTTL {
+		return s.status, nil
+	}
+	if res, err = s.checkStatus(ctx); err != nil {
+		return nil, err
+	}
+	s.status, s.statusAt = res, time.Now()
+	return res, nil
+}
+
+// checkStatus runs the checks of Status.
+func (s *healthApi) checkStatus(ctx context.Context) (res *StatusResponse, err error) {
+	checks := s.runChecks(ctx, map[string]func(ctx context.Context) Check{
+		"database":   s.checkDatabase,
+		"migrations": s.checkMigrations,
+		"scraper": func(ctx context.Context) Check {
+			return s.checkReachable(ctx, s.analysis.URL, s.analysis.ArticlesURL())
+		},
+		"analysis": func(ctx context.Context) Check {
+			return s.checkReachable(ctx, s.analysis.URL, s.analysis.AnalysisURL())
+		},
+		"llm": s.checkLLM,
+	})
+
+	res = &StatusResponse{Status: StatusOK, Checks: checks}
+	for _, check := range checks {
+		if check.Status == StatusFailing {
+			res.Status = StatusDegraded
+		}
+	}
+	// Without the database there is nothing more to report
+	if checks["database"].Status != StatusOK {
+		return res, nil
+	}
+
+	if res.ArticleSync, err = s.articleSync(ctx); err != nil {
+		s.logger.Errorf("func: Status, operation: s.articleSync, err: %s", err.Error())
+		return nil, err
+	}
+	if res.Queue, err = s.queue(ctx); err != nil {
+		s.logger.Errorf("func: Status, operation: s.queue, err: %s", err.Error())
+		return nil, err
+	}
+	return res, nil
+}
+
+// runChecks runs the checks concurrently, each with checkTimeout.
+func (s *healthApi) runChecks(ctx context.Context, checks map[string]func(ctx context.Context) Check) map[string]Check {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]Check, len(checks))
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) Check) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			result := check(ctx)
+			result.LatencyMs = time.Since(start).Milliseconds()
+
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	return results
+}
+
+func (s *healthApi) checkDatabase(ctx context.Context) Check {
+	sqlDB, err := s.db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		s.logger.Errorf("func: checkDatabase, operation: sqlDB.PingContext, err: %s", err.Error())
+		return Check{Status: StatusFailing, Message: "database unreachable"}
+	}
+	return Check{Status: StatusOK}
+}
+
+func (s *healthApi) checkMigrations(ctx context.Context) Check {
+	statuses, err := s.migrator.Status(ctx)
+	if err != nil {
+		s.logger.Errorf("func: checkMigrations, operation: s.migrator.Status, err: %s", err.Error())
+		return Check{Status: StatusFailing, Message: "migration state unknown"}
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return Check{Status: StatusFailing, Message: fmt.Sprintf("%d pending migrations", pending)}
+	}
+	return Check{Status: StatusOK}
+}
+
+// checkReachable counts any HTTP response from baseURL as reachable, except
+// server errors. It is disabled when host is not configured.
+func (s *healthApi) checkReachable(ctx context.Context, host string, baseURL string) Check {
+	if host == "" {
+		return Check{Status: StatusDisabled, Message: "not configured"}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL, nil)
+	if err != nil {
+		return Check{Status: StatusFailing, Message: "invalid url"}
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Check{Status: StatusFailing, Message: "unreachable"}
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return Check{Status: StatusFailing, Message: fmt.Sprintf("HTTP %d", resp.StatusCode)}
+	}
+	return Check{Status: StatusOK}
+}
+
+// checkLLM lists the models of OpenAI, which also verifies the API key.
+func (s *healthApi) checkLLM(ctx context.Context) Check {
+	if s.openAIKey == "" {
+		return Check{Status: StatusDisabled, Message: "not configured"}
+	}
+
+	if _, err := openai.NewClient(s.openAIKey).ListModels(ctx); err != nil {
+		var apiErr *openai.APIError
+		if errors.As(err, &apiErr) {
+			return Check{Status: StatusFailing, Message: fmt.Sprintf("HTTP %d", apiErr.HTTPStatusCode)}
+		}
+		return Check{Status: StatusFailing, Message: "unreachable"}
+	}
+	return Check{Status: StatusOK}
+}
+
+func (s *healthApi) articleSync(ctx context.Context) (ArticleSync, error) {
+	db := s.db.WithContext(ctx)
+	result := ArticleSync{}
+
+	var job scheduler.ScheduledJob
+	err := db.Where("name = ?", s.articleFetchJob).Limit(1).Find(&job).Error
+	if err != nil {
+		return result, err
+	}
+	if job.Name != "" {
+		result.LastRunAt = job.LastFinishedAt
+		result.LastRunStatus = job.LastStatus
+		result.NextRunAt = &job.NextRunAt
+	}
+
+	var newest *time.Time
+	if err := db.Table("articles").Select("MAX(scraped_at)").Scan(&newest).Error; err != nil {
+		return result, err
+	}
+	if newest != nil {
+		lag := int64(time.Since(*newest).Seconds())
+		result.NewestArticleAt = newest
+		result.LagSeconds = &lag
+	}
+	return result, nil
+}
+
+func (s *healthApi) queue(ctx context.Context) (Queue, error) {
+	db := s.db.WithContext(ctx)
+	queue := Queue{}
+
+	if err := db.Model(&mailer.OutboxEmail{}).Where("status IN ?", []string{mailer.StatusPending, mailer.StatusSending}).Count(&queue.EmailsPending).Error; err != nil {
+		return queue, err
+	}
+	if err := db.Model(&mailer.OutboxEmail{}).Where("status = ?", mailer.StatusFailed).Count(&queue.EmailsFailed).Error; err != nil {
+		return queue, err
+	}
+
+	var oldest *time.Time
+	if err := db.Model(&mailer.OutboxEmail{}).Where("status IN ?", []string{mailer.StatusPending, mailer.StatusSending}).Select("MIN(created_at)").Scan(&oldest).Error; err != nil {
+		return queue, err
+	}
+	if oldest != nil {
+		age := int64(time.Since(*oldest).Seconds())
+		queue.OldestPendingEmailSeconds = &age
+	}
+
+	err := db.Model(&scheduler.ScheduledJob{}).
+		Where("next_run_at <= ? OR triggered_at IS NOT NULL", time.Now()).
+		Count(&queue.JobsDue).Error
+	return queue, err
+}
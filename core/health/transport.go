@@ -0,0 +1,43 @@
+package health
+
+import (
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type HealthHTTPTransport interface {
+	Live(c *fiber.Ctx) error
+	Ready(c *fiber.Ctx) error
+	Status(c *fiber.Ctx) error
+}
+
+type healthHttpTransport struct {
+	healthAPI HealthAPI
+}
+
+func NewHealthHTTPTransport(healthAPI HealthAPI) HealthHTTPTransport {
+	return &healthHttpTransport{healthAPI: healthAPI}
+}
+
+func (s *healthHttpTransport) Live(c *fiber.Ctx) error {
+	return c.JSON(s.healthAPI.Live())
+}
+
+func (s *healthHttpTransport) Ready(c *fiber.Ctx) error {
+	resp := s.healthAPI.Ready(c.UserContext())
+	if resp.Status != StatusReady {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(resp)
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *healthHttpTransport) Status(c *fiber.Ctx) error {
+	resp, err := s.healthAPI.Status(c.UserContext())
+	if err != nil {
+		return helper.HTTPError(c, err, "Status.healthAPI.Status")
+	}
+
+	return c.JSON(resp)
+}
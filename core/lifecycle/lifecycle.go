@@ -0,0 +1,91 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// Manager runs the server and the background workers of the app and stops
+// them in order when the process is asked to terminate: the server is
+// drained first so no new work comes in, then the workers are cancelled and
+// waited for, so a worker is never cut off in the middle of a transaction.
+// Closing the database is left to the caller, once Run has returned.
+type Manager struct {
+	logger  log.AllLogger
+	timeout time.Duration
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+// NewManager creates a manager that gives the shutdown timeout to stop the
+// server and the workers.
+func NewManager(logger log.AllLogger, timeout time.Duration) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{logger: logger, timeout: timeout, ctx: ctx, cancel: cancel}
+}
+
+// Go runs worker in the background. Its context is cancelled on shutdown,
+// after which the worker should finish what it is doing and return.
+func (m *Manager) Go(name string, worker func(ctx context.Context)) {
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		worker(m.ctx)
+		m.logger.Infof("worker %s stopped", name)
+	}()
+}
+
+// Run calls serve and blocks until SIGINT or SIGTERM is received, or serve
+// returns, then calls shutdown to drain the server and stops the workers.
+// A second signal during shutdown kills the process right away.
+func (m *Manager) Run(serve func() error, shutdown func(ctx context.Context) error) error {
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- serve()
+	}()
+
+	var serveErr error
+	select {
+	case <-signals.Done():
+		m.logger.Infof("shutting down, waiting up to %s", m.timeout)
+	case serveErr = <-served:
+		if serveErr != nil {
+			m.logger.Errorf("func: Manager.Run, operation: serve, err: %s", serveErr.Error())
+		}
+	}
+	// Restore the default behaviour so another signal terminates at once
+	stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	if err := shutdown(ctx); err != nil {
+		m.logger.Errorf("func: Manager.Run, operation: shutdown, err: %s", err.Error())
+	}
+
+	m.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return fmt.Errorf("background workers did not stop within %s", m.timeout)
+	}
+
+	return serveErr
+}
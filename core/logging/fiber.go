@@ -0,0 +1,136 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// Logger is the logger of a subsystem as a fiber log.AllLogger, the logger
+// the services are given. WithContext returns a logger whose records carry
+// the IDs of ctx.
+func Logger(subsystem string) log.AllLogger {
+	level := new(slog.LevelVar)
+	level.Set(levelOf(subsystem))
+	return &fiberLogger{
+		logger:    newSlog(output, subsystem, level),
+		subsystem: subsystem,
+		level:     level,
+		ctx:       context.Background(),
+	}
+}
+
+type fiberLogger struct {
+	logger    *slog.Logger
+	subsystem string
+	level     *slog.LevelVar
+	ctx       context.Context
+}
+
+func (l *fiberLogger) log(level slog.Level, msg string, args ...any) {
+	l.logger.Log(l.ctx, level, msg, args...)
+	switch level {
+	case LevelFatal:
+		os.Exit(1)
+	case LevelPanic:
+		panic(msg)
+	}
+}
+
+func (l *fiberLogger) Trace(v ...interface{}) { l.log(LevelTrace, fmt.Sprint(v...)) }
+func (l *fiberLogger) Debug(v ...interface{}) { l.log(slog.LevelDebug, fmt.Sprint(v...)) }
+func (l *fiberLogger) Info(v ...interface{})  { l.log(slog.LevelInfo, fmt.Sprint(v...)) }
+func (l *fiberLogger) Warn(v ...interface{})  { l.log(slog.LevelWarn, fmt.Sprint(v...)) }
+func (l *fiberLogger) Error(v ...interface{}) { l.log(slog.LevelError, fmt.Sprint(v...)) }
+func (l *fiberLogger) Fatal(v ...interface{}) { l.log(LevelFatal, fmt.Sprint(v...)) }
+func (l *fiberLogger) Panic(v ...interface{}) { l.log(LevelPanic, fmt.Sprint(v...)) }
+
+func (l *fiberLogger) Tracef(format string, v ...interface{}) {
+	l.log(LevelTrace, fmt.Sprintf(format, v...))
+}
+
+func (l *fiberLogger) Debugf(format string, v ...interface{}) {
+	l.log(slog.LevelDebug, fmt.Sprintf(format, v...))
+}
+
+func (l *fiberLogger) Infof(format string, v ...interface{}) {
+	l.log(slog.LevelInfo, fmt.Sprintf(format, v...))
+}
+
+func (l *fiberLogger) Warnf(format string, v ...interface{}) {
+	l.log(slog.LevelWarn, fmt.Sprintf(format, v...))
+}
+
+func (l *fiberLogger) Errorf(format string, v ...interface{}) {
+	l.log(slog.LevelError, fmt.Sprintf(format, v...))
+}
+
+func (l *fiberLogger) Fatalf(format string, v ...interface{}) {
+	l.log(LevelFatal, fmt.Sprintf(format, v...))
+}
+
+func (l *fiberLogger) Panicf(format string, v ...interface{}) {
+	l.log(LevelPanic, fmt.Sprintf(format, v...))
+}
+
+func (l *fiberLogger) Tracew(msg string, keysAndValues ...interface{}) {
+	l.log(LevelTrace, msg, keysAndValues...)
+}
+
+func (l *fiberLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.log(slog.LevelDebug, msg, keysAndValues...)
+}
+
+func (l *fiberLogger) Infow(msg string, keysAndValues ...interface{}) {
+	l.log(slog.LevelInfo, msg, keysAndValues...)
+}
+
+func (l *fiberLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.log(slog.LevelWarn, msg, keysAndValues...)
+}
+
+func (l *fiberLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.log(slog.LevelError, msg, keysAndValues...)
+}
+
+func (l *fiberLogger) Fatalw(msg string, keysAndValues ...interface{}) {
+	l.log(LevelFatal, msg, keysAndValues...)
+}
+
+func (l *fiberLogger) Panicw(msg string, keysAndValues ...interface{}) {
+	l.log(LevelPanic, msg, keysAndValues...)
+}
+
+// SetLevel changes the level of this logger only, not of its subsystem.
+func (l *fiberLogger) SetLevel(level log.Level) {
+	switch level {
+	case log.LevelTrace:
+		l.level.Set(LevelTrace)
+	case log.LevelDebug:
+		l.level.Set(slog.LevelDebug)
+	case log.LevelInfo:
+		l.level.Set(slog.LevelInfo)
+	case log.LevelWarn:
+		l.level.Set(slog.LevelWarn)
+	case log.LevelError:
+		l.level.Set(slog.LevelError)
+	case log.LevelFatal:
+		l.level.Set(LevelFatal)
+	case log.LevelPanic:
+		l.level.Set(LevelPanic)
+	}
+}
+
+func (l *fiberLogger) SetOutput(w io.Writer) {
+	l.logger = newSlog(w, l.subsystem, l.level)
+}
+
+func (l *fiberLogger) WithContext(ctx context.Context) log.CommonLogger {
+	copied := *l
+	copied.ctx = ctx
+	return &copied
+}
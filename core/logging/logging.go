@@ -0,0 +1,94 @@
+// Package logging writes the logs of the app through log/slog, as JSON in
+// production and text elsewhere. Every record names its subsystem, carries
+// the request, user, org and trace IDs found in its context, and has the
+// values of secret, password and content attributes redacted.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"vezhguesi/core/config"
+
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// Levels below debug and above error, for the fiber log API
+const (
+	LevelTrace = slog.LevelDebug - 4
+	LevelFatal = slog.LevelError + 4
+	LevelPanic = slog.LevelError + 8
+)
+
+// The settings of Setup. Loggers made before Setup write text at info.
+var (
+	output       io.Writer = os.Stdout
+	jsonFormat   bool
+	defaultLevel = slog.LevelInfo
+	levels       = map[string]slog.Level{}
+	slowQuery    = 500 * time.Millisecond
+)
+
+// Setup applies the logging configuration and makes the "app" logger the
+// default of log/slog, the standard log package and fiber. It must run
+// before the loggers of the subsystems are made.
+func Setup(cfg config.Logging, env string) error {
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	subsystemLevels, err := cfg.SubsystemLevels()
+	if err != nil {
+		return err
+	}
+	levels = make(map[string]slog.Level, len(subsystemLevels))
+	for subsystem, name := range subsystemLevels {
+		if levels[subsystem], err = parseLevel(name); err != nil {
+			return err
+		}
+	}
+
+	defaultLevel = level
+	jsonFormat = cfg.Format == "json" || (cfg.Format == "" && env == "production")
+	slowQuery = cfg.SlowQuery
+
+	slog.SetDefault(Slog("app"))
+	log.SetLogger(Logger("app"))
+	return nil
+}
+
+// Slog is the logger of a subsystem, at the level configured for it.
+func Slog(subsystem string) *slog.Logger {
+	level := new(slog.LevelVar)
+	level.Set(levelOf(subsystem))
+	return newSlog(output, subsystem, level)
+}
+
+func newSlog(w io.Writer, subsystem string, level slog.Leveler) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: replaceAttr}
+	var handler slog.Handler
+	if jsonFormat {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(contextHandler{handler}).With(slog.String("subsystem", subsystem))
+}
+
+func levelOf(subsystem string) slog.Level {
+	if level, ok := levels[subsystem]; ok {
+		return level
+	}
+	return defaultLevel
+}
+
+func parseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", name, err)
+	}
+	return level, nil
+}
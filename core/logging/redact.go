@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"log/slog"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveWords mark the attributes holding credentials wherever they
+// appear in the key, e.g. password_hash or clientSecret.
+var sensitiveWords = []string{"password", "passwd", "secret", "authorization", "cookie", "apikey", "api_key"}
+
+// contentKeys are the attributes holding article text or what was sent to
+// and received from the LLM, too large and not ours to keep in logs.
+var contentKeys = map[string]bool{
+	"content":    true,
+	"body":       true,
+	"text":       true,
+	"article":    true,
+	"prompt":     true,
+	"completion": true,
+	"messages":   true,
+}
+
+// replaceAttr names the levels fiber adds and redacts sensitive attributes.
+// Only attributes are redacted: messages are formatted by the caller, which
+// must leave secrets out of them.
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(levelName(level))
+		}
+		return a
+	}
+	if sensitive(a.Key) {
+		a.Value = slog.StringValue(redacted)
+	}
+	return a
+}
+
+func sensitive(key string) bool {
+	key = strings.ToLower(key)
+	if contentKeys[key] || strings.HasSuffix(key, "token") {
+		return true
+	}
+	for _, word := range sensitiveWords {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func levelName(level slog.Level) string {
+	switch level {
+	case LevelTrace:
+		return "TRACE"
+	case LevelFatal:
+		return "FATAL"
+	case LevelPanic:
+		return "PANIC"
+	default:
+		return level.String()
+	}
+}
@@ -0,0 +1,132 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	LocaleEnglish  = "en"
+	LocaleAlbanian = "sq"
+	DefaultLocale  = LocaleEnglish
+)
+
+// Templates, each one available in every supported locale
+const (
+	TemplateVerifyEmail        = "verify_email"
+	TemplateResetPassword      = "reset_password"
+	TemplateAccountLocked      = "account_locked"
+	TemplatePasswordChanged    = "password_changed"
+	TemplateEmailChangeConfirm = "email_change_confirm"
+	TemplateEmailChangeNotice  = "email_change_notice"
+	TemplateOrgInvite          = "org_invite"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Message is an email to render from a template. Data is passed to the
+// "subject", "text" and "html" blocks of the template.
+type Message struct {
+	To       string
+	Template string
+	Locale   string
+	Data     any
+}
+
+type templateSet struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var (
+	templatesMu sync.Mutex
+	templates   = map[string]*templateSet{}
+)
+
+// SupportedLocale returns locale if templates exist for it, DefaultLocale
+// otherwise.
+func SupportedLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if locale == LocaleEnglish || locale == LocaleAlbanian {
+		return locale
+	}
+	return DefaultLocale
+}
+
+// Enqueue renders msg and stores it in the outbox using db, which should be
+// the transaction of the change that triggers the email.
+func Enqueue(db *gorm.DB, msg Message) error {
+	email, err := Render(msg)
+	if err != nil {
+		return err
+	}
+	return db.Create(email).Error
+}
+
+// Render renders msg into a pending OutboxEmail.
+func Render(msg Message) (*OutboxEmail, error) {
+	if msg.To == "" {
+		return nil, fmt.Errorf("mailer: recipient is required")
+	}
+	locale := SupportedLocale(msg.Locale)
+
+	set, err := loadTemplate(locale, msg.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := set.text.ExecuteTemplate(&subject, "subject", msg.Data); err != nil {
+		return nil, fmt.Errorf("mailer: render %s subject: %w", msg.Template, err)
+	}
+	if err := set.text.ExecuteTemplate(&text, "text", msg.Data); err != nil {
+		return nil, fmt.Errorf("mailer: render %s text: %w", msg.Template, err)
+	}
+	if err := set.html.ExecuteTemplate(&html, "html", msg.Data); err != nil {
+		return nil, fmt.Errorf("mailer: render %s html: %w", msg.Template, err)
+	}
+
+	return &OutboxEmail{
+		To:            msg.To,
+		Template:      msg.Template,
+		Locale:        locale,
+		Subject:       strings.TrimSpace(subject.String()),
+		TextBody:      strings.TrimSpace(text.String()),
+		HTMLBody:      strings.TrimSpace(html.String()),
+		Status:        StatusPending,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
+
+func loadTemplate(locale, name string) (*templateSet, error) {
+	key := locale + "/" + name
+
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	if set, ok := templates[key]; ok {
+		return set, nil
+	}
+
+	path := "templates/" + key + ".tmpl"
+	text, err := texttemplate.ParseFS(templateFS, path)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: unknown template %s: %w", key, err)
+	}
+	html, err := htmltemplate.ParseFS(templateFS, path)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: parse template %s: %w", key, err)
+	}
+
+	set := &templateSet{text: text, html: html}
+	templates[key] = set
+	return set, nil
+}
@@ -0,0 +1,30 @@
+package mailer
+
+import "time"
+
+const (
+	StatusPending = "pending"
+	StatusSending = "sending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+// OutboxEmail is a rendered email waiting to be delivered by the Sender. Rows
+// are written in the same transaction as the change that triggers the email,
+// so an email is queued if and only if that change is committed. The bodies
+// are cleared once the email is sent or given up on.
+type OutboxEmail struct {
+	ID            uint   `gorm:"primaryKey"`
+	To            string `gorm:"not null"`
+	Template      string `gorm:"not null"`
+	Locale        string `gorm:"not null"`
+	Subject       string `gorm:"not null"`
+	TextBody      string `gorm:"type:text"`
+	HTMLBody      string `gorm:"type:text"`
+	Status        string `gorm:"not null;index"`
+	Attempts      int    `gorm:"not null;default:0"`
+	LastError     string
+	NextAttemptAt time.Time `gorm:"not null;index"`
+	SentAt        *time.Time
+	CreatedAt     time.Time
+}
@@ -0,0 +1,136 @@
+package mailer
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	senderInterval  = 5 * time.Second
+	senderBatchSize = 20
+	// maxAttempts before an email is given up on and marked failed
+	maxAttempts  = 8
+	retryBackoff = 30 * time.Second
+	maxBackoff   = time.Hour
+	// sendClaimTimeout after claiming a batch its unsent emails are due again
+	sendClaimTimeout = 10 * time.Minute
+)
+
+// Sender delivers the emails of the outbox in the background, retrying
+// failed deliveries with exponential backoff.
+type Sender struct {
+	db        *gorm.DB
+	transport Transport
+	logger    log.AllLogger
+}
+
+func NewSender(db *gorm.DB, transport Transport, logger log.AllLogger) *Sender {
+	return &Sender{db: db, transport: transport, logger: logger}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (s *Sender) Run(ctx context.Context) {
+	ticker := time.NewTicker(senderInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.SendPending(ctx); err != nil {
+				s.logger.Errorf("func: Sender.Run, operation: s.SendPending, err: %s", err.Error())
+			}
+		}
+	}
+}
+
+// SendPending delivers one batch of due emails. The batch is claimed first so
+// the SMTP calls don't hold a transaction and row locks open.
+func (s *Sender) SendPending(ctx context.Context) error {
+	emails, err := s.claim(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range emails {
+		email := &emails[i]
+		updates := map[string]interface{}{}
+
+		if err := s.transport.Send(email); err != nil {
+			s.logger.Errorf("func: SendPending, operation: s.transport.Send, id: %d, attempt: %d, err: %s", email.ID, email.Attempts, err.Error())
+			updates["last_error"] = err.Error()
+			if email.Attempts >= maxAttempts {
+				updates["status"] = StatusFailed
+				redactBodies(updates)
+			} else {
+				updates["status"] = StatusPending
+				updates["next_attempt_at"] = time.Now().Add(backoff(email.Attempts - 1))
+			}
+		} else {
+			updates["status"] = StatusSent
+			updates["sent_at"] = time.Now()
+			redactBodies(updates)
+		}
+
+		// A claimed email whose update is lost is retried once its claim
+		// expires. The update isn't cancelled on shutdown so a sent email
+		// isn't delivered twice.
+		if err := s.db.WithContext(context.WithoutCancel(ctx)).Model(email).Updates(updates).Error; err != nil {
+			s.logger.Errorf("func: SendPending, operation: s.db.Updates, id: %d, err: %s", email.ID, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// claim marks a batch of due emails as sending and counts the attempt. Rows
+// are locked with SKIP LOCKED so several instances of the app never claim the
+// same email. A claim expires after sendClaimTimeout, so the emails of an
+// instance that died while sending are picked up again.
+func (s *Sender) claim(ctx context.Context) ([]OutboxEmail, error) {
+	var emails []OutboxEmail
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_attempt_at <= ?", []string{StatusPending, StatusSending}, now).
+			Order("id").
+			Limit(senderBatchSize).
+			Find(&emails).Error
+		if err != nil || len(emails) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(emails))
+		for i := range emails {
+			ids[i] = emails[i].ID
+			emails[i].Attempts++
+		}
+		return tx.Model(&OutboxEmail{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":          StatusSending,
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": now.Add(sendClaimTimeout),
+		}).Error
+	})
+	return emails, err
+}
+
+// redactBodies drops the bodies of an email that won't be sent again. They
+// may hold single-use links, which shouldn't outlive the delivery in the
+// outbox.
+func redactBodies(updates map[string]interface{}) {
+	updates["text_body"] = ""
+	updates["html_body"] = ""
+}
+
+func backoff(attempts int) time.Duration {
+	d := retryBackoff << attempts
+	if d > maxBackoff || d <= 0 {
+		return maxBackoff
+	}
+	return d
+}
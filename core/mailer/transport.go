@@ -0,0 +1,79 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v2/log"
+	"gopkg.in/gomail.v2"
+)
+
+// Transport delivers a rendered email.
+type Transport interface {
+	Send(email *OutboxEmail) error
+}
+
+type smtpTransport struct {
+	dialer *gomail.Dialer
+	from   string
+}
+
+// NewSMTPTransport sends emails through the SMTP server of dialer.
+func NewSMTPTransport(dialer *gomail.Dialer, from string) Transport {
+	return &smtpTransport{dialer: dialer, from: from}
+}
+
+func (t *smtpTransport) Send(email *OutboxEmail) error {
+	return t.dialer.DialAndSend(newMessage(t.from, email))
+}
+
+type fileTransport struct {
+	dir  string
+	from string
+}
+
+// NewFileTransport writes every email as an .eml file into dir instead of
+// sending it, for local development and tests.
+func NewFileTransport(dir, from string) Transport {
+	return &fileTransport{dir: dir, from: from}
+}
+
+func (t *fileTransport) Send(email *OutboxEmail) error {
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(t.dir, fmt.Sprintf("%d-%s.eml", email.ID, email.Template)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = newMessage(t.from, email).WriteTo(f)
+	return err
+}
+
+type logTransport struct {
+	logger log.AllLogger
+}
+
+// NewLogTransport only logs emails, for local development and tests.
+func NewLogTransport(logger log.AllLogger) Transport {
+	return &logTransport{logger: logger}
+}
+
+func (t *logTransport) Send(email *OutboxEmail) error {
+	t.logger.Infof("mailer: to: %s, subject: %s\n%s", email.To, email.Subject, email.TextBody)
+	return nil
+}
+
+func newMessage(from string, email *OutboxEmail) *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("From", from)
+	m.SetHeader("To", email.To)
+	m.SetHeader("Subject", email.Subject)
+	m.SetBody("text/plain", email.TextBody)
+	m.AddAlternative("text/html", email.HTMLBody)
+	return m
+}
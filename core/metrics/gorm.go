@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+var (
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "vezhguesi_db_query_duration_seconds",
+		Help: "Time of database queries made through GORM, by operation and table.",
+	}, []string{"operation", "table"})
+	dbQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vezhguesi_db_query_errors_total",
+		Help: "Failed database queries, record not found aside, by operation and table.",
+	}, []string{"operation", "table"})
+)
+
+const startKey = "metrics:start"
+
+// GormPlugin times every query made through a *gorm.DB. Raw queries have no
+// table label.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "metrics"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("gorm:create").Register("metrics:before_create", startQuery),
+		callbacks.Create().After("gorm:create").Register("metrics:after_create", finishQuery("create")),
+		callbacks.Query().Before("gorm:query").Register("metrics:before_query", startQuery),
+		callbacks.Query().After("gorm:query").Register("metrics:after_query", finishQuery("query")),
+		callbacks.Update().Before("gorm:update").Register("metrics:before_update", startQuery),
+		callbacks.Update().After("gorm:update").Register("metrics:after_update", finishQuery("update")),
+		callbacks.Delete().Before("gorm:delete").Register("metrics:before_delete", startQuery),
+		callbacks.Delete().After("gorm:delete").Register("metrics:after_delete", finishQuery("delete")),
+		callbacks.Row().Before("gorm:row").Register("metrics:before_row", startQuery),
+		callbacks.Row().After("gorm:row").Register("metrics:after_row", finishQuery("row")),
+		callbacks.Raw().Before("gorm:raw").Register("metrics:before_raw", startQuery),
+		callbacks.Raw().After("gorm:raw").Register("metrics:after_raw", finishQuery("raw")),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startQuery(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func finishQuery(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		start, _ := value.(time.Time)
+		table := db.Statement.Table
+
+		dbQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			dbQueryErrors.WithLabelValues(operation, table).Inc()
+		}
+	}
+}
+
+// RegisterDBStats exposes the connection pool of sqlDB. Call it once, for
+// the database of the app.
+func RegisterDBStats(sqlDB *sql.DB) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "vezhguesi_db_connections_open",
+		Help: "Open database connections, in use or idle.",
+	}, func() float64 {
+		return float64(sqlDB.Stats().OpenConnections)
+	})
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "vezhguesi_db_connections_in_use",
+		Help: "Database connections in use.",
+	}, func() float64 {
+		return float64(sqlDB.Stats().InUse)
+	})
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "vezhguesi_db_connections_wait_seconds",
+		Help: "Time waited for a free database connection since start.",
+	}, func() float64 {
+		return sqlDB.Stats().WaitDuration.Seconds()
+	})
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"vezhguesi/core/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vezhguesi_http_requests_total",
+		Help: "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "vezhguesi_http_request_duration_seconds",
+		Help: "Time to handle HTTP requests, by method and route.",
+	}, []string{"method", "route"})
+
+	upstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vezhguesi_upstream_requests_total",
+		Help: "Requests to other services, by service, endpoint and outcome: 2xx, 3xx, 4xx, 5xx or error.",
+	}, []string{"service", "endpoint", "outcome"})
+	upstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "vezhguesi_upstream_request_duration_seconds",
+		Help: "Time until the response headers of requests to other services, by service and endpoint.",
+	}, []string{"service", "endpoint"})
+)
+
+// unmatchedRoute labels requests that matched no route, so scanners
+// requesting random paths do not create series.
+const unmatchedRoute = "unmatched"
+
+// Middleware counts and times requests by their route template, e.g.
+// /api/reports/:id.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		if err := c.Next(); err != nil {
+			middleware.HandleError(c, err)
+		}
+
+		route := middleware.RoutePath(c)
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Method()
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Response().StatusCode())).Inc()
+		httpRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		return nil
+	}
+}
+
+// NewTransport wraps base, http.DefaultTransport when nil, to count and time
+// the requests made to service. Endpoints are labelled by URL path, so it is
+// only fit for services whose paths carry no IDs.
+func NewTransport(service string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{service: service, base: base}
+}
+
+type transport struct {
+	service string
+	base    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	endpoint := req.URL.Path
+
+	upstreamRequestDuration.WithLabelValues(t.service, endpoint).Observe(time.Since(start).Seconds())
+	outcome := "error"
+	if err == nil {
+		outcome = strconv.Itoa(resp.StatusCode/100) + "xx"
+	}
+	upstreamRequests.WithLabelValues(t.service, endpoint, outcome).Inc()
+	return resp, err
+}
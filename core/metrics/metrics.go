@@ -0,0 +1,51 @@
+// Package metrics exposes the app's Prometheus metrics. Metrics are created
+// as package variables with promauto, which adds them to the default
+// registry. Label values must come from a small fixed set: never user input,
+// IDs or raw URLs.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// handlerCacheTTL is how long a gathering of the metrics is served again,
+// so frequent scrapes do not each walk every series.
+const handlerCacheTTL = 5 * time.Second
+
+// Handler serves the metrics of the default registry in the Prometheus text
+// format.
+func Handler() fiber.Handler {
+	gatherer := &cachedGatherer{gatherer: prometheus.DefaultGatherer, ttl: handlerCacheTTL}
+	return adaptor.HTTPHandler(promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+}
+
+// cachedGatherer returns the same gathering of gatherer until it is ttl old.
+type cachedGatherer struct {
+	gatherer prometheus.Gatherer
+	ttl      time.Duration
+
+	mu         sync.Mutex
+	families   []*dto.MetricFamily
+	gatheredAt time.Time
+}
+
+func (g *cachedGatherer) Gather() ([]*dto.MetricFamily, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.families == nil || time.Since(g.gatheredAt) >= g.ttl {
+		families, err := g.gatherer.Gather()
+		if err != nil {
+			return nil, err
+		}
+		g.families, g.gatheredAt = families, time.Now()
+	}
+	return g.families, nil
+}
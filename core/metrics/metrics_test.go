@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCachedGatherer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_total", Help: "Test counter."})
+	registry.MustRegister(counter)
+
+	gatherer := &cachedGatherer{gatherer: registry, ttl: time.Hour}
+	counter.Inc()
+	if n, err := testutil.GatherAndCount(gatherer, "test_total"); err != nil || n != 1 {
+		t.Fatalf("GatherAndCount = %d, %v, want 1, nil", n, err)
+	}
+
+	// Within the ttl the first gathering is served again
+	counter.Inc()
+	expected := "# HELP test_total Test counter.\n# TYPE test_total counter\ntest_total 1\n"
+	if err := testutil.GatherAndCompare(gatherer, strings.NewReader(expected), "test_total"); err != nil {
+		t.Error(err)
+	}
+
+	gatherer.ttl = 0
+	expected = "# HELP test_total Test counter.\n# TYPE test_total counter\ntest_total 2\n"
+	if err := testutil.GatherAndCompare(gatherer, strings.NewReader(expected), "test_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	app := fiber.New()
+	app.Get("/metrics", Handler())
+	httpRequests.WithLabelValues(http.MethodGet, "/api/reports/:id", "200").Inc()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `vezhguesi_http_requests_total{method="GET",route="/api/reports/:id",status="200"} 1`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("metrics lack %q:\n%s", want, body)
+	}
+}
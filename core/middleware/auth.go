@@ -2,68 +2,170 @@ package middleware
 
 import (
 	"errors"
-	"net/http"
+	"log/slog"
 	"strings"
-	"time"
 
+	"vezhguesi/core/apperr"
 	session "vezhguesi/core/authentication"
+	"vezhguesi/core/logging"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 	"gorm.io/gorm"
 )
 
-func Authentication(secretKey string) fiber.Handler {
+const (
+	principalKey     = "principal"
+	requiredScopeKey = "requiredScope"
+)
+
+var (
+	ErrMissingToken       = apperr.New(apperr.Unauthenticated, "missing_token", "missing or invalid token")
+	ErrInvalidToken       = apperr.New(apperr.Unauthenticated, "invalid_token", "invalid token")
+	ErrSessionExpired     = apperr.New(apperr.Unauthenticated, "session_expired", "session expired")
+	ErrInvalidAPIKey      = apperr.New(apperr.Unauthenticated, "invalid_api_key", "invalid API key")
+	ErrAPIKeyNotAccepted  = apperr.New(apperr.Forbidden, "api_key_not_accepted", "API keys are not accepted on this route")
+	ErrAPIKeyMissingScope = apperr.New(apperr.Forbidden, "api_key_missing_scope", "API key is missing a required scope")
+	ErrRoleRequired       = apperr.New(apperr.Forbidden, "role_required", "forbidden")
+	ErrImpersonating      = apperr.New(apperr.Forbidden, "impersonation_not_allowed", "not allowed while impersonating a user")
+	ErrPrincipalNotFound  = apperr.New(apperr.Unauthenticated, "unauthenticated", "unauthenticated")
+)
+
+// Authentication authenticates the Authorization header, either a
+// "Bearer <access token>" whose session must still be live or an
+// "ApiKey <key>", and stores the resulting *session.Principal in the request
+// locals. API keys are only accepted on routes marked with RequireScope.
+func Authentication(db *gorm.DB, secretKey string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).SendString("Missing or invalid token")
+			return ErrMissingToken
+		}
+
+		if apiKey, ok := strings.CutPrefix(authHeader, "ApiKey "); ok {
+			scope, _ := c.Locals(requiredScopeKey).(string)
+			if scope == "" {
+				return ErrAPIKeyNotAccepted
+			}
+
+			principal, err := session.ResolveAPIKey(db.WithContext(c.UserContext()), apiKey)
+			if err != nil {
+				return ErrInvalidAPIKey.Wrap(err)
+			}
+			if !principal.HasScope(scope) {
+				return ErrAPIKeyMissingScope.WithDetail("scope", scope)
+			}
+
+			setPrincipal(c, principal)
+			return c.Next()
 		}
 
 		// Remove "Bearer " prefix if present
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		claims := jwt.MapClaims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.New("unexpected signing method")
+			}
 			return []byte(secretKey), nil
 		})
 		if err != nil || !token.Valid {
-			return c.Status(fiber.StatusUnauthorized).SendString("Invalid token")
+			return ErrInvalidToken
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			c.Locals("userID", claims["userId"])
-			return c.Next()
-		} else {
-			return c.Status(fiber.StatusUnauthorized).SendString("Invalid token")
+		userID, okUser := claims["userId"].(float64)
+		sessionID, okSession := claims["sid"].(float64)
+		if !okUser || !okSession {
+			return ErrInvalidToken
+		}
+
+		principal, err := session.ResolvePrincipal(db.WithContext(c.UserContext()), int(userID), uint(sessionID))
+		if err != nil {
+			return ErrSessionExpired.Wrap(err)
+		}
+
+		setPrincipal(c, principal)
+		return c.Next()
+	}
+}
+
+// setPrincipal stores the caller of the request, and adds their IDs to the
+// logs of the request.
+func setPrincipal(c *fiber.Ctx, principal *session.Principal) {
+	c.Locals(principalKey, principal)
+
+	attrs := []slog.Attr{slog.Int("user_id", principal.UserID)}
+	if principal.OrgID != nil {
+		attrs = append(attrs, slog.Int("org_id", *principal.OrgID))
+	}
+	if principal.APIKeyID != 0 {
+		attrs = append(attrs, slog.Uint64("api_key_id", uint64(principal.APIKeyID)))
+	}
+	if principal.ImpersonatorID != 0 {
+		attrs = append(attrs, slog.Int("impersonator_id", principal.ImpersonatorID))
+	}
+	c.SetUserContext(logging.With(c.UserContext(), attrs...))
+}
+
+// RequireScope marks a route as reachable with an API key holding scope. It
+// must run before the Authentication middleware.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(requiredScopeKey, scope)
+		return c.Next()
+	}
+}
+
+// RequireRole only lets principals with the given global user role through.
+// It must run after the Authentication middleware.
+func RequireRole(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, err := CtxPrincipal(c)
+		if err != nil {
+			return err
+		}
+		if principal.Role != role {
+			return ErrRoleRequired
 		}
+		return c.Next()
 	}
 }
 
+// DenyImpersonation rejects impersonation sessions, for routes changing the
+// user's credentials or account that an admin must not act on in their name.
+// It must run after the Authentication middleware.
+func DenyImpersonation(c *fiber.Ctx) error {
+	principal, err := CtxPrincipal(c)
+	if err != nil {
+		return err
+	}
+	if principal.ImpersonatorID != 0 {
+		return ErrImpersonating
+	}
+	return c.Next()
+}
+
+func CtxPrincipal(c *fiber.Ctx) (*session.Principal, error) {
+	principal, ok := c.Locals(principalKey).(*session.Principal)
+	if !ok || principal == nil {
+		return nil, ErrPrincipalNotFound
+	}
+	return principal, nil
+}
+
 func CtxUserID(c *fiber.Ctx) (int, error) {
-	userID, ok := c.Locals("userID").(float64) // JWT claims are often float64
-	if !ok {
-		return 0, errors.New("user ID not found in context")
+	principal, err := CtxPrincipal(c)
+	if err != nil {
+		return 0, ErrPrincipalNotFound
 	}
-	return int(userID), nil
+	return principal.UserID, nil
 }
 
-func SessionMiddleware(db *gorm.DB) fiber.Handler {
-    return func(c *fiber.Ctx) error {
-        sessionToken := c.Get("Authorization")
-        if sessionToken == "" {
-            return c.Status(http.StatusUnauthorized).SendString("Unauthorized")
-        }
-
-        var session session.Session
-        if err := db.Where("session_token = ?", sessionToken).First(&session).Error; err != nil {
-            return c.Status(http.StatusUnauthorized).SendString("Unauthorized")
-        }
-
-        if session.ExpiresAt.Before(time.Now()) {
-            db.Delete(&session)
-            return c.Status(http.StatusUnauthorized).SendString("Session expired")
-        }
-
-        return c.Next()
-    }
-}
\ No newline at end of file
+func CtxSessionID(c *fiber.Ctx) (uint, error) {
+	principal, err := CtxPrincipal(c)
+	if err != nil || principal.SessionID == 0 {
+		return 0, ErrPrincipalNotFound.WithMessage("not signed in with a session")
+	}
+	return principal.SessionID, nil
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vezhguesi/core/apperr"
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/logging"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDenyImpersonation(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *session.Principal
+		status    int
+	}{
+		{"own session", &session.Principal{UserID: 1}, http.StatusOK},
+		{"impersonation", &session.Principal{UserID: 1, ImpersonatorID: 2}, http.StatusForbidden},
+		{"no principal", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		app := fiber.New(fiber.Config{ErrorHandler: apperr.Handler(logging.Logger("http"))})
+		app.Put("/users/me/password", func(c *fiber.Ctx) error {
+			if tt.principal != nil {
+				c.Locals(principalKey, tt.principal)
+			}
+			return c.Next()
+		}, DenyImpersonation, func(c *fiber.Ctx) error {
+			return c.SendStatus(http.StatusOK)
+		})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodPut, "/users/me/password", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"vezhguesi/core/logging"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLength bounds the request IDs taken from callers
+const maxRequestIDLength = 128
+
+// RequestLogger gives every request an ID, taken from the X-Request-ID
+// header or generated and echoed back in it, adds it to the logs of the
+// request through c.UserContext() and logs the request once it is done.
+// Requests to quietPaths, e.g. probes, are only logged at debug.
+func RequestLogger(quietPaths ...string) fiber.Handler {
+	logger := logging.Slog("http")
+	quiet := make(map[string]bool, len(quietPaths))
+	for _, path := range quietPaths {
+		quiet[path] = true
+	}
+
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(fiber.HeaderXRequestID)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+		c.Set(fiber.HeaderXRequestID, requestID)
+		c.SetUserContext(logging.With(c.UserContext(), slog.String("request_id", requestID)))
+
+		start := time.Now()
+		if err := c.Next(); err != nil {
+			HandleError(c, err)
+		}
+
+		level := slog.LevelInfo
+		if quiet[c.Path()] {
+			level = slog.LevelDebug
+		}
+		// The user context now also holds the caller set by Authentication
+		logger.LogAttrs(c.UserContext(), level, "request",
+			slog.String("method", c.Method()),
+			slog.String("route", RoutePath(c)),
+			slog.String("path", c.Path()),
+			slog.Int("status", c.Response().StatusCode()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", c.IP()),
+		)
+		return nil
+	}
+}
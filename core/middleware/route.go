@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const unmatchedKey = "route_unmatched"
+
+// HandleError passes the error returned by the next handlers to the app's
+// error handler, as the logger middleware of fiber does, so middleware can
+// see the final status. Middleware calling it returns nil afterwards.
+func HandleError(c *fiber.Ctx, err error) {
+	// fiber reports requests no route matched with this error
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) && fiberErr.Code == fiber.StatusNotFound && strings.HasPrefix(fiberErr.Message, "Cannot ") {
+		c.Locals(unmatchedKey, true)
+	}
+	if err := c.App().ErrorHandler(c, err); err != nil {
+		_ = c.SendStatus(fiber.StatusInternalServerError)
+	}
+}
+
+// RoutePath is the template of the route that handled the request, e.g.
+// /api/reports/:id, or "" when no route matched. It is only known once the
+// next handlers returned and their error went through HandleError.
+func RoutePath(c *fiber.Ctx) string {
+	if unmatched, _ := c.Locals(unmatchedKey).(bool); unmatched {
+		return ""
+	}
+	return c.Route().Path
+}
@@ -0,0 +1,29 @@
+package scheduler
+
+import (
+	"time"
+
+	"vezhguesi/core/audit"
+)
+
+type JobStatus struct {
+	Name           string     `json:"name"`
+	Schedule       string     `json:"schedule"`
+	NextRunAt      time.Time  `json:"nextRunAt"`
+	TriggeredAt    *time.Time `json:"triggeredAt"`
+	LastStatus     string     `json:"lastStatus,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	LastInstance   string     `json:"lastInstance,omitempty"`
+	LastStartedAt  *time.Time `json:"lastStartedAt"`
+	LastFinishedAt *time.Time `json:"lastFinishedAt"`
+	LastDurationMs int64      `json:"lastDurationMs"`
+}
+
+type ListJobsResponse struct {
+	Jobs []JobStatus `json:"jobs"`
+}
+
+type TriggerJobRequest struct {
+	Name  string      `json:"-"`
+	Actor audit.Actor `json:"-"`
+}
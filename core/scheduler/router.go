@@ -0,0 +1,14 @@
+package scheduler
+
+import (
+	"vezhguesi/core/middleware"
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterRoutes(router fiber.Router, schedulerHttpApi SchedulerHTTPTransport, authMiddleware func(c *fiber.Ctx) error) {
+	adminRoutes := router.Group("/admin", authMiddleware, middleware.RequireRole(helper.AdminRoleName))
+	adminRoutes.Get("/jobs", schedulerHttpApi.ListJobs)
+	adminRoutes.Post("/jobs/:name/trigger", schedulerHttpApi.TriggerJob)
+}
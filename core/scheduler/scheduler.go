@@ -0,0 +1,218 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"os"
+	"time"
+
+	"vezhguesi/core/logging"
+	"vezhguesi/core/tracing"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	jobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vezhguesi_job_runs_total",
+		Help: "Scheduled job runs on this instance, by job and status: succeeded or failed.",
+	}, []string{"job", "status"})
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vezhguesi_job_duration_seconds",
+		Help:    "Time of scheduled job runs on this instance, by job.",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	}, []string{"job"})
+)
+
+// Job is a named task run on a schedule. Only one instance of the app runs a
+// job at a time.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// Run does the work. Its context is cancelled on shutdown, a job that
+	// cannot stop halfway may ignore it and finish first.
+	Run func(ctx context.Context) error
+}
+
+// Scheduler runs the registered jobs when they are due. Every instance of the
+// app may run a scheduler: each job is guarded by a Postgres advisory lock
+// and its next run time is kept in scheduled_jobs, so whichever instance
+// looks first runs it and the others skip it.
+type Scheduler struct {
+	db           *gorm.DB
+	logger       log.AllLogger
+	pollInterval time.Duration
+	// instance identifies this process in the job status
+	instance string
+	jobs     []Job
+}
+
+func NewScheduler(db *gorm.DB, logger log.AllLogger, pollInterval time.Duration) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		db:           db,
+		logger:       logger,
+		pollInterval: pollInterval,
+		instance:     fmt.Sprintf("%s/%d", host, os.Getpid()),
+	}
+}
+
+// Register adds a job and records it in scheduled_jobs. The first run is one
+// schedule step from now, and again whenever the schedule changes.
+func (s *Scheduler) Register(job Job) error {
+	if _, ok := s.Job(job.Name); ok {
+		return fmt.Errorf("job %s is already registered", job.Name)
+	}
+
+	row := ScheduledJob{
+		Name:      job.Name,
+		Schedule:  job.Schedule.String(),
+		NextRunAt: job.Schedule.Next(time.Now()),
+	}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"schedule":    gorm.Expr("excluded.schedule"),
+			"next_run_at": gorm.Expr(`CASE WHEN "scheduled_jobs"."schedule" = excluded.schedule THEN "scheduled_jobs"."next_run_at" ELSE excluded.next_run_at END`),
+			"updated_at":  gorm.Expr("excluded.updated_at"),
+		}),
+	}).Create(&row).Error
+	if err != nil {
+		return fmt.Errorf("failed to register job %s: %w", job.Name, err)
+	}
+
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+// Job finds a registered job by name.
+func (s *Scheduler) Job(name string) (Job, bool) {
+	for _, job := range s.jobs {
+		if job.Name == name {
+			return job, true
+		}
+	}
+	return Job{}, false
+}
+
+// Jobs lists the registered jobs in registration order.
+func (s *Scheduler) Jobs() []Job {
+	return s.jobs
+}
+
+// Run looks for due and triggered jobs every poll interval until ctx is
+// cancelled. Jobs run one after another; a running job is waited for before
+// returning.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, job := range s.jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				if err := s.runIfDue(ctx, job); err != nil {
+					s.logger.Errorf("func: Scheduler.Run, operation: s.runIfDue, job: %s, err: %s", job.Name, err.Error())
+				}
+			}
+		}
+	}
+}
+
+// runIfDue runs job if it is due or triggered and no other instance holds
+// its lock. The bookkeeping does not use ctx, so a job finishing during
+// shutdown is still recorded and the lock released.
+func (s *Scheduler) runIfDue(ctx context.Context, job Job) error {
+	return s.db.Connection(func(conn *gorm.DB) error {
+		var locked bool
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?)", lockKey(job.Name)).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(?)", lockKey(job.Name)).Error; err != nil {
+				s.logger.Errorf("func: Scheduler.runIfDue, operation: pg_advisory_unlock, job: %s, err: %s", job.Name, err.Error())
+			}
+		}()
+
+		// Read under the lock, an instance that just ran the job has
+		// already moved next_run_at
+		var row ScheduledJob
+		if err := conn.First(&row, "name = ?", job.Name).Error; err != nil {
+			return err
+		}
+		startedAt := time.Now()
+		if row.TriggeredAt == nil && row.NextRunAt.After(startedAt) {
+			return nil
+		}
+
+		err := conn.Model(&row).Updates(map[string]interface{}{
+			"last_status":     StatusRunning,
+			"last_error":      "",
+			"last_instance":   s.instance,
+			"last_started_at": startedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		// The logs and queries of the run carry the job and its trace
+		jobCtx, span := tracing.Tracer().Start(logging.With(ctx, slog.String("job", job.Name)), "job "+job.Name)
+		logger := s.logger.WithContext(jobCtx)
+		logger.Infof("func: Scheduler.runIfDue, job: %s, started", job.Name)
+		runErr := s.run(jobCtx, job)
+		tracing.End(span, runErr)
+		finishedAt := time.Now()
+		jobDuration.WithLabelValues(job.Name).Observe(finishedAt.Sub(startedAt).Seconds())
+
+		status := StatusSucceeded
+		updates := map[string]interface{}{
+			"last_finished_at": finishedAt,
+			"last_duration_ms": finishedAt.Sub(startedAt).Milliseconds(),
+			"next_run_at":      job.Schedule.Next(finishedAt),
+			// A trigger that came in while running gets its own run
+			"triggered_at": gorm.Expr("CASE WHEN triggered_at <= ? THEN NULL ELSE triggered_at END", startedAt),
+		}
+		if runErr != nil {
+			logger.Errorf("func: Scheduler.runIfDue, operation: job.Run, job: %s, err: %s", job.Name, runErr.Error())
+			status = StatusFailed
+			updates["last_error"] = runErr.Error()
+		} else {
+			logger.Infof("func: Scheduler.runIfDue, job: %s, finished in %s", job.Name, finishedAt.Sub(startedAt))
+		}
+		updates["last_status"] = status
+		jobRuns.WithLabelValues(job.Name, status).Inc()
+		return conn.Model(&row).Updates(updates).Error
+	})
+}
+
+// run calls the job, turning a panic into an error so it does not take the
+// app down.
+func (s *Scheduler) run(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// lockKey is the advisory lock of a job, derived from its name.
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("scheduler:" + name))
+	return int64(h.Sum64())
+}
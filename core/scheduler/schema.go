@@ -0,0 +1,27 @@
+package scheduler
+
+import "time"
+
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// ScheduledJob is the state of a registered job shared by every instance.
+// LastStatus stays "running" if the instance running the job died, until
+// another instance runs it.
+type ScheduledJob struct {
+	Name      string    `gorm:"primaryKey"`
+	Schedule  string    `gorm:"not null"`
+	NextRunAt time.Time `gorm:"not null"`
+	// TriggeredAt is set by a manual trigger and cleared by the run it causes
+	TriggeredAt    *time.Time
+	LastStatus     string
+	LastError      string
+	LastInstance   string
+	LastStartedAt  *time.Time
+	LastFinishedAt *time.Time
+	LastDurationMs int64
+	UpdatedAt      time.Time
+}
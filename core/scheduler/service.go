@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"time"
+
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
+
+	"github.com/gofiber/fiber/v2/log"
+	"gorm.io/gorm"
+)
+
+var ErrJobNotFound = apperr.New(apperr.NotFound, "job_not_found", "job does not exist")
+
+type schedulerApi struct {
+	db        *gorm.DB
+	logger    log.AllLogger
+	scheduler *Scheduler
+}
+
+type SchedulerAPI interface {
+	ListJobs() (res *ListJobsResponse, err error)
+	TriggerJob(req *TriggerJobRequest) (res *JobStatus, err error)
+}
+
+func NewSchedulerAPI(db *gorm.DB, logger log.AllLogger, scheduler *Scheduler) SchedulerAPI {
+	return &schedulerApi{
+		db:        db,
+		logger:    logger,
+		scheduler: scheduler,
+	}
+}
+
+// @Summary      	List Scheduled Jobs
+// @Description		Admins only. Lists the registered jobs with their schedule, next run and the outcome of the last run.
+// @Tags			Scheduler
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Success			200					{object}	ListJobsResponse
+// @Router			/api/admin/jobs	[GET]
+func (s *schedulerApi) ListJobs() (res *ListJobsResponse, err error) {
+	var rows []ScheduledJob
+	if err := s.db.Order("name").Find(&rows).Error; err != nil {
+		s.logger.Errorf("func: ListJobs, operation: s.db.Find, err: %s", err.Error())
+		return nil, err
+	}
+	byName := make(map[string]ScheduledJob, len(rows))
+	for _, row := range rows {
+		byName[row.Name] = row
+	}
+
+	// Rows of jobs no longer registered are left out
+	res = &ListJobsResponse{Jobs: []JobStatus{}}
+	for _, job := range s.scheduler.Jobs() {
+		if row, ok := byName[job.Name]; ok {
+			res.Jobs = append(res.Jobs, toJobStatus(row))
+		}
+	}
+	return res, nil
+}
+
+// @Summary      	Trigger Scheduled Job
+// @Description		Admins only. Asks for the job to run as soon as possible, on whichever instance picks it up first. The next scheduled run is counted from the end of this one.
+// @Tags			Scheduler
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			name			path		string	true	"Job name"
+// @Success			202					{object}	JobStatus
+// @Router			/api/admin/jobs/{name}/trigger	[POST]
+func (s *schedulerApi) TriggerJob(req *TriggerJobRequest) (res *JobStatus, err error) {
+	if _, ok := s.scheduler.Job(req.Name); !ok {
+		return nil, ErrJobNotFound
+	}
+
+	var row ScheduledJob
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&row, "name = ?", req.Name).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(&row).Update("triggered_at", now).Error; err != nil {
+			return err
+		}
+		row.TriggeredAt = &now
+
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionJobTriggered,
+			TargetType: audit.TargetJob,
+			TargetID:   req.Name,
+		})
+	})
+	if err != nil {
+		s.logger.Errorf("func: TriggerJob, operation: s.db.Transaction, job: %s, err: %s", req.Name, err.Error())
+		return nil, err
+	}
+
+	status := toJobStatus(row)
+	return &status, nil
+}
+
+func toJobStatus(row ScheduledJob) JobStatus {
+	return JobStatus{
+		Name:           row.Name,
+		Schedule:       row.Schedule,
+		NextRunAt:      row.NextRunAt,
+		TriggeredAt:    row.TriggeredAt,
+		LastStatus:     row.LastStatus,
+		LastError:      row.LastError,
+		LastInstance:   row.LastInstance,
+		LastStartedAt:  row.LastStartedAt,
+		LastFinishedAt: row.LastFinishedAt,
+		LastDurationMs: row.LastDurationMs,
+	}
+}
@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"vezhguesi/core/audit"
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SchedulerHTTPTransport interface {
+	ListJobs(c *fiber.Ctx) error
+	TriggerJob(c *fiber.Ctx) error
+}
+
+type schedulerHttpTransport struct {
+	schedulerAPI SchedulerAPI
+}
+
+func NewSchedulerHTTPTransport(schedulerAPI SchedulerAPI) SchedulerHTTPTransport {
+	return &schedulerHttpTransport{schedulerAPI: schedulerAPI}
+}
+
+func (s *schedulerHttpTransport) ListJobs(c *fiber.Ctx) error {
+	resp, err := s.schedulerAPI.ListJobs()
+	if err != nil {
+		return helper.HTTPError(c, err, "ListJobs.schedulerAPI.ListJobs")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *schedulerHttpTransport) TriggerJob(c *fiber.Ctx) error {
+	req := &TriggerJobRequest{
+		Name:  c.Params("name"),
+		Actor: audit.ActorFrom(c),
+	}
+
+	resp, err := s.schedulerAPI.TriggerJob(req)
+	if err != nil {
+		return helper.HTTPError(c, err, "TriggerJob.schedulerAPI.TriggerJob")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(resp)
+}
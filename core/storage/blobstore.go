@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"vezhguesi/core/apperr"
+)
+
+var (
+	ErrNotFound         = apperr.New(apperr.NotFound, "blob_not_found", "blob not found")
+	ErrInvalidKey       = apperr.New(apperr.Invalid, "invalid_blob_key", "invalid blob key")
+	ErrInvalidSignature = apperr.New(apperr.Forbidden, "invalid_signature", "invalid or expired signature")
+)
+
+// BlobStore stores binary objects under slash separated keys, e.g.
+// "avatars/12/ab34/128.jpg". Objects are private; clients download them
+// through SignedURL.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// DeletePrefix deletes every object whose key starts with prefix
+	DeletePrefix(ctx context.Context, prefix string) error
+	// SignedURL returns a URL that downloads key without further
+	// authentication until ttl has passed
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+// validKey rejects keys that are empty, absolute or that could escape the
+// store, e.g. with "..".
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalStoreSignedURL(t *testing.T) {
+	const secret = "app-secret"
+	store := NewLocalStore(t.TempDir(), "https://api.example.com/api/files/", secret)
+
+	signed, err := store.SignedURL("avatars/1/photo.png", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(signed, "https://api.example.com/api/files/avatars/1/photo.png?") {
+		t.Errorf("SignedURL = %s", signed)
+	}
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+
+	if err := store.Verify("avatars/1/photo.png", expires, signature); err != nil {
+		t.Errorf("Verify of the signed URL: %v", err)
+	}
+	if err := store.Verify("avatars/2/photo.png", expires, signature); err == nil {
+		t.Error("signature of another key was accepted")
+	}
+	if err := store.Verify("avatars/1/photo.png", "1", store.sign("avatars/1/photo.png", "1")); err == nil {
+		t.Error("expired signature was accepted")
+	}
+
+	// The app secret itself doesn't sign URLs
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("avatars/1/photo.png\n" + expires))
+	if err := store.Verify("avatars/1/photo.png", expires, hex.EncodeToString(mac.Sum(nil))); err == nil {
+		t.Error("signature made with the app secret was accepted")
+	}
+}
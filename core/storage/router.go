@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"errors"
+	"mime"
+	"path"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterRoutes serves the objects of a LocalStore at /files/<key> to
+// requests carrying a valid signature from SignedURL.
+func RegisterRoutes(router fiber.Router, store *LocalStore) {
+	router.Get("/files/*", func(c *fiber.Ctx) error {
+		key := c.Params("*")
+		if err := store.Verify(key, c.Query("expires"), c.Query("signature")); err != nil {
+			return err
+		}
+
+		body, err := store.Get(c.Context(), key)
+		if errors.Is(err, ErrInvalidKey) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+			c.Set(fiber.HeaderContentType, contentType)
+		}
+		c.Set(fiber.HeaderCacheControl, "private, max-age=3600")
+		return c.SendStream(body)
+	})
+}
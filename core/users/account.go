@@ -0,0 +1,229 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/mailer"
+	"vezhguesi/core/validation"
+	"vezhguesi/helper"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const changeEmailTokenTTL = 24 * time.Hour
+
+// @Summary      	UpdatePassword
+// @Description		Validates the current password and the new password, updates the password in DB, logs the user out of every other session and notifies the user by email.
+// @Tags			Users
+// @Accept			json
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			PasswordUpdateRequest	body		PasswordUpdateRequest	true	"PasswordUpdateRequest"
+// @Success			200								{object}	StatusResponse
+// @Router			/api/users/me/password		[PUT]
+func (s *userApi) UpdatePassword(ctx context.Context, req *PasswordUpdateRequest) (res *StatusResponse, err error) {
+	db := s.db.WithContext(ctx)
+
+	req.CurrentPassword = strings.TrimSpace(req.CurrentPassword)
+	req.NewPassword = strings.TrimSpace(req.NewPassword)
+	req.ConfirmNewPassword = strings.TrimSpace(req.ConfirmNewPassword)
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return nil, apperr.New(apperr.Invalid, "password_unchanged", "new password must be different from the current password")
+	}
+
+	var user User
+	if err := db.Where("id = ? AND deleted_at IS NULL", req.UserID).First(&user).Error; err != nil {
+		return nil, helper.ErrNotFound
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		return nil, apperr.New(apperr.Invalid, "invalid_password", "invalid current password")
+	}
+
+	pwh, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password")
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", string(pwh)).Error; err != nil {
+			s.logger.Errorf("func: UpdatePassword, operation: tx.Update(password), err: %s", err.Error())
+			return err
+		}
+
+		err := audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionPasswordChanged,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return mailer.Enqueue(tx, mailer.Message{
+			To:       user.Email,
+			Template: mailer.TemplatePasswordChanged,
+			Locale:   user.Locale,
+			Data:     map[string]string{"Name": user.FirstName, "Link": s.uiAppUrl + "/forgot-password"},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the current device logged in, every other one has to log in again
+	if err := s.tokens.RevokeAllSessions(user.ID, req.SessionID); err != nil {
+		s.logger.Errorf("func: UpdatePassword, operation: s.tokens.RevokeAllSessions, err: %s", err.Error())
+		return nil, err
+	}
+
+	return &StatusResponse{
+		Status: true,
+	}, nil
+}
+
+// @Summary      	UpdateEmail
+// @Description		Validates the password and the new email, sends a confirmation link to the new email and a notice to the current one. The email is only changed once the link is confirmed.
+// @Tags			Users
+// @Accept			json
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			EmailUpdateRequest	body		EmailUpdateRequest	true	"EmailUpdateRequest"
+// @Success			200								{object}	StatusResponse
+// @Router			/api/users/me/email		[POST]
+func (s *userApi) UpdateEmail(ctx context.Context, req *EmailUpdateRequest) (res *StatusResponse, err error) {
+	db := s.db.WithContext(ctx)
+
+	req.NewEmail = strings.TrimSpace(strings.ToLower(req.NewEmail))
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+
+	var user User
+	if err := db.Where("id = ? AND deleted_at IS NULL", req.UserID).First(&user).Error; err != nil {
+		return nil, helper.ErrNotFound
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return nil, apperr.New(apperr.Invalid, "invalid_password", "invalid password")
+	}
+	if req.NewEmail == user.Email {
+		return nil, apperr.New(apperr.Invalid, "email_unchanged", "new email must be different from the current email")
+	}
+	if s.emailTaken(db, req.NewEmail) {
+		return nil, apperr.New(apperr.Conflict, "email_in_use", "email already in use")
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		t, err := session.IssueOneTimeToken(tx, session.PurposeChangeEmail, user.ID, req.NewEmail, "", changeEmailTokenTTL)
+		if err != nil {
+			s.logger.Errorf("func: UpdateEmail, operation: session.IssueOneTimeToken, err: %s", err.Error())
+			return fmt.Errorf("failed to generate token")
+		}
+
+		err = audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionEmailChangeRequested,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			After:      map[string]string{"newEmail": req.NewEmail},
+		})
+		if err != nil {
+			return err
+		}
+
+		err = mailer.Enqueue(tx, mailer.Message{
+			To:       req.NewEmail,
+			Template: mailer.TemplateEmailChangeConfirm,
+			Locale:   user.Locale,
+			Data:     map[string]string{"Name": user.FirstName, "Link": s.uiAppUrl + "/confirm-email/" + t},
+		})
+		if err != nil {
+			return err
+		}
+
+		return mailer.Enqueue(tx, mailer.Message{
+			To:       user.Email,
+			Template: mailer.TemplateEmailChangeNotice,
+			Locale:   user.Locale,
+			Data:     map[string]string{"Name": user.FirstName, "NewEmail": req.NewEmail},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatusResponse{
+		Status: true,
+	}, nil
+}
+
+// @Summary      	ConfirmEmail
+// @Description		Redeems the single-use email change token in param and replaces the user's email with the confirmed one.
+// @Tags			Users
+// @Produce			json
+// @Param			token				path		string			true	"Token"
+// @Success			200								{object}	StatusResponse
+// @Router			/api/users/email/confirm/{token}		[POST]
+func (s *userApi) ConfirmEmail(ctx context.Context, req *EmailConfirmRequest) (res *StatusResponse, err error) {
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" {
+		return nil, apperr.New(apperr.Invalid, "missing_token", "token is required")
+	}
+
+	var userID int
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		token, err := session.ConsumeOneTimeToken(tx, session.PurposeChangeEmail, req.Token)
+		if err != nil {
+			return err
+		}
+		if s.emailTaken(tx, token.Email) {
+			return apperr.New(apperr.Conflict, "email_in_use", "email already in use")
+		}
+
+		userID = token.UserID
+		var oldEmail string
+		tx.Model(&User{}).Select("email").Where("id = ?", token.UserID).Scan(&oldEmail)
+
+		result := tx.Model(&User{}).
+			Where("id = ? AND deleted_at IS NULL", token.UserID).
+			Updates(map[string]interface{}{"email": token.Email, "verified_email": true})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return helper.ErrNotFound
+		}
+
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionEmailChanged,
+			TargetType: audit.TargetUser,
+			TargetID:   token.UserID,
+			Before:     map[string]string{"email": oldEmail},
+			After:      map[string]string{"email": token.Email},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	session.ForgetUser(userID)
+
+	return &StatusResponse{
+		Status: true,
+	}, nil
+}
+
+func (s *userApi) emailTaken(db *gorm.DB, email string) bool {
+	var count int64
+	db.Model(&User{}).Where("email = ?", email).Count(&count)
+	return count > 0
+}
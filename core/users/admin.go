@@ -0,0 +1,491 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/mailer"
+	"vezhguesi/core/validation"
+	"vezhguesi/helper"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultUsersPageSize = 50
+	maxUsersPageSize     = 200
+	// forcedResetTokenTTL is longer than a self-service reset, the user
+	// didn't ask for the email and may not read it right away
+	forcedResetTokenTTL = 24 * time.Hour
+)
+
+// @Summary      	Find Users
+// @Description		Admins only. Searches users by email, username or name, filtered by role and status (active, inactive or deleted), newest first.
+// @Tags			Admin
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			q				query		string	false	"Search"
+// @Param			role			query		string	false	"Global role"
+// @Param			status			query		string	false	"active, inactive or deleted"
+// @Param			page			query		int		false	"Page, starting at 1"
+// @Param			pageSize		query		int		false	"Page size, max 200"
+// @Success			200					{object}	FindUsersResponse
+// @Router			/api/admin/users	[GET]
+func (s *userApi) FindUsers(ctx context.Context, req *FindUsersRequest) (res *FindUsersResponse, err error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.PageSize < 1 {
+		req.PageSize = defaultUsersPageSize
+	}
+	if req.PageSize > maxUsersPageSize {
+		req.PageSize = maxUsersPageSize
+	}
+
+	query := s.db.WithContext(ctx).Model(&User{})
+	switch req.Status {
+	case "active":
+		query = query.Where("deleted_at IS NULL AND active = ?", true)
+	case "inactive":
+		query = query.Where("deleted_at IS NULL AND active = ?", false)
+	case "deleted":
+		query = query.Where("deleted_at IS NOT NULL")
+	default:
+		query = query.Where("deleted_at IS NULL")
+	}
+	if req.Role != "" {
+		query = query.Where("role = ?", req.Role)
+	}
+	if q := strings.TrimSpace(req.Query); q != "" {
+		like := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(email) LIKE ? OR LOWER(username) LIKE ? OR LOWER(first_name) LIKE ? OR LOWER(last_name) LIKE ?", like, like, like, like)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var users []User
+	err = query.Order("created_at DESC, id DESC").
+		Offset((req.Page - 1) * req.PageSize).
+		Limit(req.PageSize).
+		Find(&users).Error
+	if err != nil {
+		s.logger.Errorf("func: FindUsers, operation: query.Find(&users), err: %s", err.Error())
+		return nil, err
+	}
+
+	res = &FindUsersResponse{
+		Users:    make([]AdminUserResponse, 0, len(users)),
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	}
+	for _, user := range users {
+		entry := AdminUserResponse{
+			ID:            user.ID,
+			Email:         user.Email,
+			FirstName:     user.FirstName,
+			LastName:      user.LastName,
+			Role:          user.Role,
+			Active:        user.Active,
+			VerifiedEmail: user.VerifiedEmail,
+			Locale:        user.Locale,
+			CreatedAt:     user.CreatedAt,
+			DeletedAt:     user.DeletedAt,
+		}
+		if user.Username != nil {
+			entry.Username = *user.Username
+		}
+		res.Users = append(res.Users, entry)
+	}
+
+	return res, nil
+}
+
+// @Summary      	Set User Active
+// @Description		Admins only. Activates or deactivates a user. Deactivating logs the user out of every session and disables their API keys.
+// @Tags			Admin
+// @Accept			json
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			userId			path		int		true	"User ID"
+// @Param			SetActiveRequest	body		SetActiveRequest	true	"SetActiveRequest"
+// @Success			200					{object}	StatusResponse
+// @Router			/api/admin/users/{userId}/active	[PUT]
+func (s *userApi) SetUserActive(ctx context.Context, req *SetActiveRequest) (res *StatusResponse, err error) {
+	if req.UserID == req.AdminID && !req.Active {
+		return nil, apperr.New(apperr.Forbidden, "self_deactivate_forbidden", "admins can't deactivate their own account")
+	}
+
+	user, err := s.adminTarget(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Active == req.Active {
+		return &StatusResponse{Status: true}, nil
+	}
+
+	action := audit.ActionUserActivated
+	if !req.Active {
+		action = audit.ActionUserDeactivated
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("active", req.Active).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     action,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Before:     map[string]bool{"active": user.Active},
+			After:      map[string]bool{"active": req.Active},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.Active {
+		s.revokeSessions(user.ID, "SetUserActive")
+	}
+	session.ForgetUser(user.ID)
+
+	return &StatusResponse{Status: true}, nil
+}
+
+// @Summary      	Delete User
+// @Description		Admins only. Soft deletes a user by setting DeletedAt, deactivating the account and logging the user out of every session.
+// @Tags			Admin
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			userId			path		int		true	"User ID"
+// @Success			200					{object}	StatusResponse
+// @Router			/api/admin/users/{userId}	[DELETE]
+func (s *userApi) DeleteUser(ctx context.Context, req *AdminUserRequest) (res *StatusResponse, err error) {
+	if req.UserID == req.AdminID {
+		return nil, apperr.New(apperr.Forbidden, "self_delete_forbidden", "admins can't delete their own account")
+	}
+
+	user, err := s.adminTarget(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Updates(map[string]interface{}{
+			"deleted_at": now,
+			"active":     false,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionUserDeleted,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Before:     map[string]interface{}{"active": user.Active, "deletedAt": nil},
+			After:      map[string]interface{}{"active": false, "deletedAt": now},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.revokeSessions(user.ID, "DeleteUser")
+	session.ForgetUser(user.ID)
+
+	return &StatusResponse{Status: true}, nil
+}
+
+// @Summary      	Restore User
+// @Description		Admins only. Restores a soft deleted user. The account stays deactivated until an admin activates it.
+// @Tags			Admin
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			userId			path		int		true	"User ID"
+// @Success			200					{object}	StatusResponse
+// @Router			/api/admin/users/{userId}/restore	[POST]
+func (s *userApi) RestoreUser(ctx context.Context, req *AdminUserRequest) (res *StatusResponse, err error) {
+	db := s.db.WithContext(ctx)
+
+	var user User
+	if err := db.Where("id = ? AND deleted_at IS NOT NULL", req.UserID).First(&user).Error; err != nil {
+		return nil, helper.ErrNotFound
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionUserRestored,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Before:     map[string]interface{}{"deletedAt": user.DeletedAt},
+			After:      map[string]interface{}{"deletedAt": nil},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatusResponse{Status: true}, nil
+}
+
+// @Summary      	Force Password Reset
+// @Description		Admins only. Clears the user's password, logs them out of every session and emails them a password reset link. The user can't log in with a password until they set a new one.
+// @Tags			Admin
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			userId			path		int		true	"User ID"
+// @Success			200					{object}	StatusResponse
+// @Router			/api/admin/users/{userId}/password-reset	[POST]
+func (s *userApi) ForcePasswordReset(ctx context.Context, req *AdminUserRequest) (res *StatusResponse, err error) {
+	user, err := s.adminTarget(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("password", "").Error; err != nil {
+			return err
+		}
+
+		t, err := session.IssueOneTimeToken(tx, session.PurposeResetPassword, user.ID, user.Email, "", forcedResetTokenTTL)
+		if err != nil {
+			s.logger.Errorf("func: ForcePasswordReset, operation: session.IssueOneTimeToken, err: %s", err.Error())
+			return fmt.Errorf("failed to generate token")
+		}
+
+		err = audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionPasswordResetForced,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return mailer.Enqueue(tx, mailer.Message{
+			To:       user.Email,
+			Template: mailer.TemplateResetPassword,
+			Locale:   user.Locale,
+			Data:     map[string]string{"Name": user.FirstName, "Link": s.uiAppUrl + "/reset-password/" + t},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.revokeSessions(user.ID, "ForcePasswordReset")
+
+	return &StatusResponse{Status: true}, nil
+}
+
+// @Summary      	Set User Role
+// @Description		Admins only. Changes the global role of a user to user or admin.
+// @Tags			Admin
+// @Accept			json
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			userId			path		int		true	"User ID"
+// @Param			SetRoleRequest	body		SetRoleRequest	true	"SetRoleRequest"
+// @Success			200					{object}	StatusResponse
+// @Router			/api/admin/users/{userId}/role	[PUT]
+func (s *userApi) SetUserRole(ctx context.Context, req *SetRoleRequest) (res *StatusResponse, err error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	if req.UserID == req.AdminID {
+		return nil, apperr.New(apperr.Forbidden, "self_role_change_forbidden", "admins can't change their own role")
+	}
+
+	user, err := s.adminTarget(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == req.Role {
+		return &StatusResponse{Status: true}, nil
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("role", req.Role).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionUserRoleChanged,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Before:     map[string]string{"role": user.Role},
+			After:      map[string]string{"role": req.Role},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	session.ForgetUser(user.ID)
+
+	return &StatusResponse{Status: true}, nil
+}
+
+// CreateAdmin creates an active, verified admin, or promotes the user with
+// the email when there is one. It bootstraps the first admin from the
+// command line and has no HTTP route.
+func (s *userApi) CreateAdmin(ctx context.Context, req *CreateAdminRequest) (res *CreateAdminResponse, err error) {
+	db := s.db.WithContext(ctx)
+
+	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+	req.Username = strings.TrimSpace(req.Username)
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+
+	var user User
+	err = db.Where("email = ?", req.Email).First(&user).Error
+	if err == nil {
+		if user.Role != helper.AdminRoleName {
+			_, err := s.SetUserRole(ctx, &SetRoleRequest{
+				AdminUserRequest: AdminUserRequest{UserID: user.ID, Actor: req.Actor},
+				Role:             helper.AdminRoleName,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &CreateAdminResponse{ID: user.ID}, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	if req.Password == "" {
+		return nil, apperr.New(apperr.Invalid, "missing_password", "password is required for a new user")
+	}
+	hashedPw, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password")
+	}
+
+	user = User{
+		Email:         req.Email,
+		Password:      string(hashedPw),
+		FirstName:     req.FirstName,
+		LastName:      req.LastName,
+		Active:        true,
+		VerifiedEmail: true,
+		Role:          helper.AdminRoleName,
+		Locale:        mailer.DefaultLocale,
+	}
+	if req.Username != "" {
+		user.Username = &req.Username
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("UpdatedAt").Create(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionAdminCreated,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			After:      map[string]interface{}{"email": user.Email, "username": user.Username, "role": user.Role},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateAdminResponse{ID: user.ID, Created: true}, nil
+}
+
+// @Summary      	Impersonate User
+// @Description		Admins only. Starts a short, non-refreshable session as an active, non-admin user for support. Everything done in the session is audited with the admin as impersonator.
+// @Tags			Admin
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			userId			path		int		true	"User ID"
+// @Success			200					{object}	ImpersonateResponse
+// @Router			/api/admin/users/{userId}/impersonate	[POST]
+func (s *userApi) Impersonate(ctx context.Context, req *ImpersonateRequest) (res *ImpersonateResponse, err error) {
+	if req.UserID == req.AdminID {
+		return nil, apperr.New(apperr.Invalid, "self_impersonation", "invalid user, admins can't impersonate themselves")
+	}
+
+	user, err := s.adminTarget(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		return nil, apperr.New(apperr.Forbidden, "user_inactive", "user is not active")
+	}
+	if user.Role == helper.AdminRoleName {
+		return nil, apperr.New(apperr.Forbidden, "admin_impersonation_forbidden", "admins can't be impersonated")
+	}
+
+	pair, err := s.tokens.StartImpersonation(user.ID, req.AdminID, session.DeviceInfo{
+		Name:      "impersonation",
+		UserAgent: req.UserAgent,
+		IP:        req.IP,
+	})
+	if err != nil {
+		s.logger.Errorf("func: Impersonate, operation: s.tokens.StartImpersonation, err: %s", err.Error())
+		return nil, err
+	}
+
+	err = audit.Record(s.db.WithContext(ctx), audit.Event{
+		Actor:      req.Actor,
+		Action:     audit.ActionImpersonationStarted,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		After:      map[string]uint{"sessionId": pair.SessionID},
+	})
+	if err != nil {
+		// Don't hand out a session that isn't on the audit trail
+		s.tokens.RevokeSession(user.ID, pair.SessionID)
+		return nil, err
+	}
+
+	return &ImpersonateResponse{
+		AccessToken: pair.AccessToken,
+		ExpiresIn:   pair.ExpiresIn,
+		SessionID:   pair.SessionID,
+	}, nil
+}
+
+// adminTarget loads a user that isn't deleted for an admin action.
+func (s *userApi) adminTarget(ctx context.Context, userID int) (*User, error) {
+	if userID == 0 {
+		return nil, helper.ErrMissingId
+	}
+
+	var user User
+	if err := s.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", userID).First(&user).Error; err != nil {
+		return nil, helper.ErrNotFound
+	}
+
+	return &user, nil
+}
+
+func (s *userApi) revokeSessions(userID int, fn string) {
+	if err := s.tokens.RevokeAllSessions(userID, 0); err != nil {
+		s.logger.Errorf("func: %s, operation: s.tokens.RevokeAllSessions, err: %s", fn, err.Error())
+	}
+}
@@ -0,0 +1,290 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+	"strconv"
+	"time"
+
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/storage"
+	"vezhguesi/helper"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxAvatarBytes is the largest avatar upload accepted
+	MaxAvatarBytes = 5 * 1024 * 1024
+	// maxAvatarPixels bounds the decoded image so a small file can't expand
+	// into a huge bitmap
+	maxAvatarPixels = 4096 * 4096
+	minAvatarSide   = 32
+	avatarQuality   = 85
+	// DefaultAvatarSize is the size linked from user responses
+	DefaultAvatarSize = 256
+	avatarURLTTL      = time.Hour
+)
+
+// AvatarSizes are the square sizes, in pixels, every avatar is stored in.
+var AvatarSizes = []int{64, 128, 256, 512}
+
+var avatarContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// AvatarURL returns a signed download URL of the avatar stored under key in
+// the given size, or "" when the user has no avatar.
+func AvatarURL(blobs storage.BlobStore, key string, size int) string {
+	if key == "" || blobs == nil {
+		return ""
+	}
+	url, err := blobs.SignedURL(avatarObjectKey(key, size), avatarURLTTL)
+	if err != nil {
+		return ""
+	}
+	return url
+}
+
+func avatarObjectKey(key string, size int) string {
+	return fmt.Sprintf("%s/%d.jpg", key, size)
+}
+
+// @Summary      	Upload Avatar
+// @Description		Uploads a JPEG, PNG or GIF avatar of up to 5 MB as multipart field "avatar". The type is detected from the content, the image is cropped to a square and stored in every size of AvatarSizes, replacing the previous avatar.
+// @Tags			Users
+// @Accept			mpfd
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			avatar		formData	file	true	"Avatar image"
+// @Success			200								{object}	AvatarResponse
+// @Router			/api/users/me/avatar		[PUT]
+func (s *userApi) UploadAvatar(ctx context.Context, req *AvatarUploadRequest) (res *AvatarResponse, err error) {
+	db := s.db.WithContext(ctx)
+
+	if req.UserID == 0 {
+		return nil, apperr.New(apperr.Invalid, "missing_user_id", "user ID is required")
+	}
+	if len(req.Image) == 0 {
+		return nil, apperr.New(apperr.Invalid, "missing_avatar", "avatar is required")
+	}
+	if len(req.Image) > MaxAvatarBytes {
+		return nil, apperr.Newf(apperr.Invalid, "avatar_too_large", "invalid avatar, the file is larger than %d MB", MaxAvatarBytes/1024/1024)
+	}
+	contentType := http.DetectContentType(req.Image)
+	if !avatarContentTypes[contentType] {
+		return nil, apperr.New(apperr.Invalid, "invalid_avatar_type", "invalid avatar, only JPEG, PNG and GIF images are accepted")
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(req.Image))
+	if err != nil {
+		return nil, apperr.New(apperr.Invalid, "invalid_avatar", "invalid avatar, the image can't be read")
+	}
+	if config.Width*config.Height > maxAvatarPixels {
+		return nil, apperr.New(apperr.Invalid, "avatar_too_large", "invalid avatar, the image is larger than 4096x4096 pixels")
+	}
+	if config.Width < minAvatarSide || config.Height < minAvatarSide {
+		return nil, apperr.Newf(apperr.Invalid, "avatar_too_small", "invalid avatar, the image is smaller than %dx%d pixels", minAvatarSide, minAvatarSide)
+	}
+	img, _, err := image.Decode(bytes.NewReader(req.Image))
+	if err != nil {
+		return nil, apperr.New(apperr.Invalid, "invalid_avatar", "invalid avatar, the image can't be read")
+	}
+
+	var user User
+	if err := db.Where("id = ? AND deleted_at IS NULL", req.UserID).First(&user).Error; err != nil {
+		return nil, helper.ErrNotFound
+	}
+
+	suffix, err := session.RandomToken(9)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("avatars/%d/%s", user.ID, suffix)
+
+	square := squareCrop(img)
+	for _, size := range AvatarSizes {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resize(square, size), &jpeg.Options{Quality: avatarQuality}); err != nil {
+			return nil, err
+		}
+		if err := s.blobs.Put(ctx, avatarObjectKey(key, size), &buf, int64(buf.Len()), "image/jpeg"); err != nil {
+			s.logger.Errorf("func: UploadAvatar, operation: s.blobs.Put, err: %s", err.Error())
+			s.blobs.DeletePrefix(ctx, key+"/")
+			return nil, fmt.Errorf("failed to store avatar")
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("avatar_img_key", key).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionAvatarUpdated,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Before:     map[string]string{"avatarImgKey": user.AvatarImgKey},
+			After:      map[string]string{"avatarImgKey": key},
+		})
+	})
+	if err != nil {
+		s.blobs.DeletePrefix(ctx, key+"/")
+		return nil, err
+	}
+
+	s.deleteAvatarObjects(ctx, user.AvatarImgKey)
+
+	return s.avatarResponse(key), nil
+}
+
+// @Summary      	Delete Avatar
+// @Description		Removes the avatar of the user.
+// @Tags			Users
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Success			200								{object}	StatusResponse
+// @Router			/api/users/me/avatar		[DELETE]
+func (s *userApi) DeleteAvatar(ctx context.Context, req *AvatarRequest) (res *StatusResponse, err error) {
+	db := s.db.WithContext(ctx)
+
+	var user User
+	if err := db.Where("id = ? AND deleted_at IS NULL", req.UserID).First(&user).Error; err != nil {
+		return nil, helper.ErrNotFound
+	}
+	if user.AvatarImgKey == "" {
+		return &StatusResponse{Status: true}, nil
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("avatar_img_key", "").Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Event{
+			Actor:      req.Actor,
+			Action:     audit.ActionAvatarDeleted,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Before:     map[string]string{"avatarImgKey": user.AvatarImgKey},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.deleteAvatarObjects(ctx, user.AvatarImgKey)
+
+	return &StatusResponse{Status: true}, nil
+}
+
+// @Summary      	Get Avatar
+// @Description		Returns signed download URLs of the user's avatar in every size. The URLs expire after an hour.
+// @Tags			Users
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
+// @Param			userId  path int true "User ID"
+// @Success			200								{object}	AvatarResponse
+// @Router			/api/users/{userId}/avatar		[GET]
+func (s *userApi) GetAvatar(ctx context.Context, req *AvatarRequest) (res *AvatarResponse, err error) {
+	var user User
+	if err := s.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", req.UserID).First(&user).Error; err != nil {
+		return nil, helper.ErrNotFound
+	}
+	if user.AvatarImgKey == "" {
+		return nil, helper.ErrNotFound
+	}
+
+	return s.avatarResponse(user.AvatarImgKey), nil
+}
+
+func (s *userApi) avatarResponse(key string) *AvatarResponse {
+	res := &AvatarResponse{
+		AvatarImgUrl: AvatarURL(s.blobs, key, DefaultAvatarSize),
+		Sizes:        make(map[string]string, len(AvatarSizes)),
+		ExpiresIn:    int64(avatarURLTTL.Seconds()),
+	}
+	for _, size := range AvatarSizes {
+		res.Sizes[strconv.Itoa(size)] = AvatarURL(s.blobs, key, size)
+	}
+	return res
+}
+
+func (s *userApi) deleteAvatarObjects(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	if err := s.blobs.DeletePrefix(ctx, key+"/"); err != nil {
+		s.logger.Errorf("func: deleteAvatarObjects, operation: s.blobs.DeletePrefix, err: %s", err.Error())
+	}
+}
+
+// squareCrop returns the centered square of img on a white background, so
+// transparent images don't turn black as JPEG.
+func squareCrop(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	origin := image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2)
+
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, origin, draw.Over)
+	return dst
+}
+
+// resize scales the square src to size x size. Each destination pixel is the
+// average of the source pixels it covers, or the nearest one when enlarging.
+func resize(src *image.RGBA, size int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	side := src.Bounds().Dx()
+
+	for y := 0; y < size; y++ {
+		y0 := y * side / size
+		y1 := (y + 1) * side / size
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < size; x++ {
+			x0 := x * side / size
+			x1 := (x + 1) * side / size
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+
+			d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4]
+			d[0] = uint8(r / n)
+			d[1] = uint8(g / n)
+			d[2] = uint8(b / n)
+			d[3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
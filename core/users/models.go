@@ -2,6 +2,7 @@ package users
 
 import (
 	"time"
+	"vezhguesi/core/audit"
 )
 
 type SignupRequest struct {
@@ -50,41 +51,110 @@ type IDResponse struct {
 	ID int `json:"id"`
 }
 
-type UserResponse struct {
-	ID           int            `json:"id"`
-	FirstName    string         `json:"firstName"`
-	LastName     string         `json:"lastName"`
-	Username     string         `json:"username"`
-	Email        string         `json:"email"`
-	Status       string         `json:"status"`
-	AvatarImgKey string         `json:"avatarImgUrl"`
-	CreatedAt    time.Time      `json:"createdAt"`
-	UpdatedAt    time.Time      `json:"updatedAt"`
-}
-
 type FindUserByID struct {
 	UserID int `json:"-"`
+	// ViewerID and ViewerRole are the signed in user asking for the user
+	ViewerID   int    `json:"-"`
+	ViewerRole string `json:"-"`
 }
 
 type PasswordUpdateRequest struct {
-	UserID             int    `json:"-"`
+	UserID             int    `json:"-" validate:"required"`
+	SessionID          uint   `json:"-"`
 	// Mode               string `json:"mode"`
-	CurrentPassword    string `json:"currentPassword"`
-	NewPassword        string `json:"newPassword"`
-	ConfirmNewPassword string `json:"confirmNewPassword"`
+	CurrentPassword    string `json:"currentPassword" validate:"required"`
+	NewPassword        string `json:"newPassword" validate:"required,password"`
+	ConfirmNewPassword string `json:"confirmNewPassword" validate:"required,eqfield=NewPassword"`
+	Actor              audit.Actor `json:"-"`
 }
 
 type EmailUpdateRequest struct {
-	UserID   int    `json:"-"`
-	NewEmail string `json:"newEmail"`
+	UserID   int    `json:"-" validate:"required"`
+	NewEmail string `json:"newEmail" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required"`
+	Actor    audit.Actor `json:"-"`
 }
 
-type FindRequest struct {
-	UserID int `json:"-"`
+type EmailConfirmRequest struct {
+	Token string      `json:"-"`
+	Actor audit.Actor `json:"-"`
+}
+
+type FindUsersRequest struct {
+	// Query matches email, username, first and last name
+	Query    string `query:"q"`
+	Role     string `query:"role" validate:"omitempty,oneof=user admin"`
+	// Status is active, inactive or deleted. Deleted users are only listed
+	// with status deleted.
+	Status   string `query:"status" validate:"omitempty,oneof=active inactive deleted"`
+	Page     int    `query:"page"`
+	PageSize int    `query:"pageSize"`
+}
+
+type AdminUserResponse struct {
+	ID            int        `json:"id"`
+	Email         string     `json:"email"`
+	Username      string     `json:"username"`
+	FirstName     string     `json:"firstName"`
+	LastName      string     `json:"lastName"`
+	Role          string     `json:"role"`
+	Active        bool       `json:"active"`
+	VerifiedEmail bool       `json:"verifiedEmail"`
+	Locale        string     `json:"locale"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	DeletedAt     *time.Time `json:"deletedAt"`
+}
+
+type FindUsersResponse struct {
+	Users    []AdminUserResponse `json:"users"`
+	Total    int64               `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"pageSize"`
+}
+
+type AdminUserRequest struct {
+	UserID int         `json:"-"`
+	// AdminID is the admin performing the action
+	AdminID int         `json:"-"`
+	Actor   audit.Actor `json:"-"`
+}
+
+type SetActiveRequest struct {
+	AdminUserRequest
+	Active bool `json:"active"`
+}
+
+type SetRoleRequest struct {
+	AdminUserRequest
+	Role string `json:"role" validate:"required,oneof=user admin"`
+}
+
+// CreateAdminRequest is used by the create-admin command. Password is only
+// needed when no user has the email yet.
+type CreateAdminRequest struct {
+	Email     string      `json:"email" validate:"required,email,max=255"`
+	Username  string      `json:"username" validate:"max=50"`
+	FirstName string      `json:"firstName" validate:"max=100"`
+	LastName  string      `json:"lastName" validate:"max=100"`
+	Password  string      `json:"password" validate:"omitempty,password"`
+	Actor     audit.Actor `json:"-"`
+}
+
+type CreateAdminResponse struct {
+	ID      int  `json:"id"`
+	Created bool `json:"created"`
+}
+
+type ImpersonateRequest struct {
+	AdminUserRequest
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
 }
 
-type FindResponse struct {
-	Users []User `json:"users"`
+type ImpersonateResponse struct {
+	AccessToken string `json:"accessToken"`
+	ExpiresIn   int64  `json:"expiresIn"`
+	SessionID   uint   `json:"sessionId"`
 }
 
 type FindByIDResponse struct {
@@ -98,3 +168,22 @@ type FindByIDResponse struct {
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
+
+type AvatarUploadRequest struct {
+	UserID int         `json:"-"`
+	Image  []byte      `json:"-"`
+	Actor  audit.Actor `json:"-"`
+}
+
+type AvatarRequest struct {
+	UserID int         `json:"-"`
+	Actor  audit.Actor `json:"-"`
+}
+
+type AvatarResponse struct {
+	// AvatarImgUrl is the URL of the DefaultAvatarSize image
+	AvatarImgUrl string `json:"avatarImgUrl"`
+	// Sizes maps each size in pixels to its URL
+	Sizes     map[string]string `json:"sizes"`
+	ExpiresIn int64             `json:"expiresIn"`
+}
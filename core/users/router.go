@@ -1,13 +1,33 @@
 package users
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"vezhguesi/core/middleware"
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 func RegisterRoutes(router fiber.Router, userHttpApi UserHTTPTransport, authMiddleware func(c *fiber.Ctx) error) {
 	userRoutes := router.Group("/users")
 	// public routes
-	userRoutes.Get("", userHttpApi.GetUsers)
+	userRoutes.Post("/email/confirm/:token", userHttpApi.ConfirmEmail)
 
 	// Protected with auth middleware
 	userRoutes.Get("/user-data", authMiddleware, userHttpApi.GetUserData)
+	userRoutes.Put("/me/password", authMiddleware, middleware.DenyImpersonation, userHttpApi.UpdatePassword)
+	userRoutes.Post("/me/email", authMiddleware, middleware.DenyImpersonation, userHttpApi.UpdateEmail)
+	userRoutes.Put("/me/avatar", authMiddleware, userHttpApi.UploadAvatar)
+	userRoutes.Delete("/me/avatar", authMiddleware, userHttpApi.DeleteAvatar)
 	userRoutes.Get("/:userId", authMiddleware, userHttpApi.GetUserByID)
+	userRoutes.Get("/:userId/avatar", authMiddleware, userHttpApi.GetAvatar)
+
+	// Admin only
+	adminRoutes := router.Group("/admin/users", authMiddleware, middleware.RequireRole(helper.AdminRoleName))
+	adminRoutes.Get("", userHttpApi.FindUsers)
+	adminRoutes.Put("/:userId/active", userHttpApi.SetUserActive)
+	adminRoutes.Delete("/:userId", userHttpApi.DeleteUser)
+	adminRoutes.Post("/:userId/restore", userHttpApi.RestoreUser)
+	adminRoutes.Post("/:userId/password-reset", userHttpApi.ForcePasswordReset)
+	adminRoutes.Put("/:userId/role", userHttpApi.SetUserRole)
+	adminRoutes.Post("/:userId/impersonate", middleware.DenyImpersonation, userHttpApi.Impersonate)
 }
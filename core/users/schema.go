@@ -19,6 +19,7 @@ type User struct {
 	Phone         string
 	VerifiedEmail bool
 	Role          string
+	Locale        string `gorm:"not null;default:'en'"`
 	CreatedAt     time.Time
 	UpdatedAt     *time.Time
 	DeletedAt     *time.Time
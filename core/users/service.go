@@ -1,67 +1,52 @@
 package users
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
+	"vezhguesi/core/apperr"
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/storage"
+	"vezhguesi/helper"
+
 	"github.com/gofiber/fiber/v2/log"
-	"gopkg.in/gomail.v2" // Import gomail
 	"gorm.io/gorm"
 )
 
+// ErrUserNotFound is returned for user IDs that don't exist
+var ErrUserNotFound = apperr.New(apperr.NotFound, "user_not_found", "user does not exist")
+
 type userApi struct {
 	db *gorm.DB
 	secretKey string
-	mailDialer *gomail.Dialer // Use gomail Dialer
 	uiAppUrl string
 	logger log.AllLogger
+	tokens session.TokenIssuer
+	blobs storage.BlobStore
 }
 
 type UserAPI interface{
-	GetUsers(req *FindRequest) (*[]UserResponse, error)
-	GetUserByID(req *FindUserByID) (*FindByIDResponse, error)
-	GetUserData(req *FindUserByID) (*UserData, error)
+	GetUserByID(ctx context.Context, req *FindUserByID) (*FindByIDResponse, error)
+	GetUserData(ctx context.Context, req *FindUserByID) (*UserData, error)
+	UpdatePassword(ctx context.Context, req *PasswordUpdateRequest) (*StatusResponse, error)
+	UpdateEmail(ctx context.Context, req *EmailUpdateRequest) (*StatusResponse, error)
+	ConfirmEmail(ctx context.Context, req *EmailConfirmRequest) (*StatusResponse, error)
+	FindUsers(ctx context.Context, req *FindUsersRequest) (*FindUsersResponse, error)
+	SetUserActive(ctx context.Context, req *SetActiveRequest) (*StatusResponse, error)
+	DeleteUser(ctx context.Context, req *AdminUserRequest) (*StatusResponse, error)
+	RestoreUser(ctx context.Context, req *AdminUserRequest) (*StatusResponse, error)
+	ForcePasswordReset(ctx context.Context, req *AdminUserRequest) (*StatusResponse, error)
+	SetUserRole(ctx context.Context, req *SetRoleRequest) (*StatusResponse, error)
+	Impersonate(ctx context.Context, req *ImpersonateRequest) (*ImpersonateResponse, error)
+	CreateAdmin(ctx context.Context, req *CreateAdminRequest) (*CreateAdminResponse, error)
+	UploadAvatar(ctx context.Context, req *AvatarUploadRequest) (*AvatarResponse, error)
+	DeleteAvatar(ctx context.Context, req *AvatarRequest) (*StatusResponse, error)
+	GetAvatar(ctx context.Context, req *AvatarRequest) (*AvatarResponse, error)
 }
 
-func NewUserAPI(db *gorm.DB, secretKey string, dialer *gomail.Dialer, uiAppUrl string, logger log.AllLogger) UserAPI {
-	return &userApi{db: db, secretKey: secretKey, mailDialer: dialer, uiAppUrl: uiAppUrl, logger: logger}
-}
-
-
-// @Summary      	GetUsers
-// @Description
-// @Tags			Users
-// @Produce			json
-// @Success			200								{object}	[]UserResponse
-// @Router			/api/users		[GET]
-func (s *userApi) GetUsers(req *FindRequest) (*[]UserResponse, error) {
-	var users []User
-
-	// Fetch all users from the database
-	if err := s.db.Find(&users).Error; err != nil {
-		s.logger.Errorf("Error fetching users: %v", err)
-		return nil, err
-	}
-
-	s.logger.Infof("Number of users found: %d", len(users))
-
-	var userResponses []UserResponse
-	for _, user := range users {
-		status := "inactive"
-		if user.Active {
-			status = "active"
-		} 
-
-		userResponses = append(userResponses, UserResponse{
-			ID: user.ID,
-			FirstName: user.FirstName,
-			LastName: user.LastName,
-			Username: *user.Username,
-			Email: user.Email,
-			Status: status,
-		})
-	}
-
-	return &userResponses, nil
+func NewUserAPI(db *gorm.DB, secretKey string, uiAppUrl string, logger log.AllLogger, tokens session.TokenIssuer, blobs storage.BlobStore) UserAPI {
+	return &userApi{db: db, secretKey: secretKey, uiAppUrl: uiAppUrl, logger: logger, tokens: tokens, blobs: blobs}
 }
 
 
@@ -73,26 +58,61 @@ func (s *userApi) GetUsers(req *FindRequest) (*[]UserResponse, error) {
 // @Param			userId  path int true "User ID"
 // @Success			200								{object}	FindByIDResponse
 // @Router			/api/users/{userId}		[GET]
-func (s *userApi) GetUserByID(req *FindUserByID) (res *FindByIDResponse, err error) {
+func (s *userApi) GetUserByID(ctx context.Context, req *FindUserByID) (res *FindByIDResponse, err error) {
+	visible, err := s.canView(ctx, req)
+	if err != nil {
+		s.logger.Errorf("func: GetUserByID, operation: s.canView, err: %s", err.Error())
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if !visible {
+		return nil, ErrUserNotFound
+	}
+
 	var user User
-	if err := s.db.First(&user, req.UserID).Error; err != nil {
+	err = s.db.WithContext(ctx).First(&user, req.UserID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
 		s.logger.Errorf("Error fetching user by ID: %v", err)
-		return nil, err
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
 	}
 
 	var response = &FindByIDResponse{
 		ID: user.ID,
 		FirstName: user.FirstName,
 		LastName: user.LastName,
-		Username: *user.Username,
 		Email: user.Email,
 		Status: user.Status,
-		AvatarImgKey: user.AvatarImgKey,
+		AvatarImgKey: AvatarURL(s.blobs, user.AvatarImgKey, DefaultAvatarSize),
+	}
+	if user.Username != nil {
+		response.Username = *user.Username
 	}
 
 	return response, nil
 }
 
+// canView reports whether the viewer may see the user: themselves, admins
+// and members of an org the user belongs to. Other users are reported as not
+// found so user IDs can't be probed.
+func (s *userApi) canView(ctx context.Context, req *FindUserByID) (bool, error) {
+	if req.ViewerID == req.UserID || req.ViewerRole == helper.AdminRoleName {
+		return true, nil
+	}
+
+	var shared int64
+	err := s.db.WithContext(ctx).Table("user_org_roles AS viewer").
+		Joins("JOIN user_org_roles AS member ON member.org_id = viewer.org_id AND member.deleted_at IS NULL").
+		Where("viewer.user_id = ? AND member.user_id = ? AND viewer.deleted_at IS NULL", req.ViewerID, req.UserID).
+		Count(&shared).Error
+	if err != nil {
+		return false, err
+	}
+
+	return shared > 0, nil
+}
+
 // @Summary      	GetUserData
 // @Description
 // @Tags			Users
@@ -100,24 +120,30 @@ func (s *userApi) GetUserByID(req *FindUserByID) (res *FindByIDResponse, err err
 // @Param			Authorization  header string true "Authorization Key (e.g Bearer key)"
 // @Success			200								{object}	UserData
 // @Router			/api/users/user-data		[GET]
-func (s *userApi) GetUserData(req *FindUserByID) (res *UserData, err error) {
+func (s *userApi) GetUserData(ctx context.Context, req *FindUserByID) (res *UserData, err error) {
 	if req.UserID == 0 {
-		return nil, fmt.Errorf("user ID is required")
+		return nil, apperr.New(apperr.Invalid, "missing_user_id", "user ID is required")
 	}
 
 	var user User
-	result := s.db.First(&user, req.UserID)
+	result := s.db.WithContext(ctx).First(&user, req.UserID)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, fmt.Errorf("failed to fetch user: %w", result.Error)
 	}
 
 	var userData = &UserData{
 		ID: user.ID,
 		FirstName: user.FirstName,
 		LastName: user.LastName,
-		Username: *user.Username,
 		Email: user.Email,
 		Role: user.Role,
+		AvatarImgUrl: AvatarURL(s.blobs, user.AvatarImgKey, DefaultAvatarSize),
+	}
+	if user.Username != nil {
+		userData.Username = *user.Username
 	}
 
 	return userData, nil
@@ -1,16 +1,32 @@
 package users
 
 import (
+	"io"
 	"strconv"
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
 	"vezhguesi/core/middleware"
+	"vezhguesi/helper"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type UserHTTPTransport interface {
-	GetUsers(c *fiber.Ctx) error
 	GetUserByID(c *fiber.Ctx) error
 	GetUserData(c *fiber.Ctx) error
+	UpdatePassword(c *fiber.Ctx) error
+	UpdateEmail(c *fiber.Ctx) error
+	ConfirmEmail(c *fiber.Ctx) error
+	FindUsers(c *fiber.Ctx) error
+	SetUserActive(c *fiber.Ctx) error
+	DeleteUser(c *fiber.Ctx) error
+	RestoreUser(c *fiber.Ctx) error
+	ForcePasswordReset(c *fiber.Ctx) error
+	SetUserRole(c *fiber.Ctx) error
+	Impersonate(c *fiber.Ctx) error
+	UploadAvatar(c *fiber.Ctx) error
+	DeleteAvatar(c *fiber.Ctx) error
+	GetAvatar(c *fiber.Ctx) error
 }
 
 type userHttpTransport struct {
@@ -22,51 +38,289 @@ func NewUserHTTPTransport(userAPI UserAPI) UserHTTPTransport {
 }
 
 
-func (s *userHttpTransport) GetUsers(c *fiber.Ctx) error {
-	req := &FindRequest{}
+func (s *userHttpTransport) GetUserByID(c *fiber.Ctx) error {
+	req := &FindUserByID{}
+	userIdParamStr := c.Params("userId")
+	userId, err := strconv.Atoi(userIdParamStr)
+	if err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidArgument.Wrap(err), "GetUserByID.strconv.Atoi")
+	}
+	req.UserID = userId
 	if err := c.QueryParser(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, helper.ErrInvalidQuery.Wrap(err), "GetUserByID.c.QueryParser")
+	}
+	principal, err := middleware.CtxPrincipal(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "GetUserByID.middleware.CtxPrincipal")
 	}
+	req.ViewerID = principal.UserID
+	req.ViewerRole = principal.Role
 
-	resp, err := s.userAPI.GetUsers(req)
+	resp, err := s.userAPI.GetUserByID(c.UserContext(), req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, err, "GetUserByID.userAPI.GetUserByID")
 	}
 
 	return c.JSON(resp)
 }
 
-func (s *userHttpTransport) GetUserByID(c *fiber.Ctx) error {
+func (s *userHttpTransport) GetUserData(c *fiber.Ctx) error {
 	req := &FindUserByID{}
-	userIdParamStr := c.Params("userId")
-	userId, err := strconv.Atoi(userIdParamStr)
+	userId, err := middleware.CtxUserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, err, "GetUserData.middleware.CtxUserID")
 	}
 	req.UserID = userId
+
+	resp, err := s.userAPI.GetUserData(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "GetUserData.userAPI.GetUserData")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *userHttpTransport) UpdatePassword(c *fiber.Ctx) error {
+	req := &PasswordUpdateRequest{}
+	principal, err := middleware.CtxPrincipal(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "UpdatePassword.middleware.CtxPrincipal")
+	}
+	if err := c.BodyParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "UpdatePassword.c.BodyParser")
+	}
+	req.UserID = principal.UserID
+	req.SessionID = principal.SessionID
+	req.Actor = audit.ActorFrom(c)
+
+	resp, err := s.userAPI.UpdatePassword(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "UpdatePassword.userAPI.UpdatePassword")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *userHttpTransport) UpdateEmail(c *fiber.Ctx) error {
+	req := &EmailUpdateRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "UpdateEmail.middleware.CtxUserID")
+	}
+	if err := c.BodyParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "UpdateEmail.c.BodyParser")
+	}
+	req.UserID = userId
+	req.Actor = audit.ActorFrom(c)
+
+	resp, err := s.userAPI.UpdateEmail(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "UpdateEmail.userAPI.UpdateEmail")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *userHttpTransport) ConfirmEmail(c *fiber.Ctx) error {
+	req := &EmailConfirmRequest{}
+	req.Token = c.Params("token")
+	req.Actor = audit.ActorFrom(c)
+
+	resp, err := s.userAPI.ConfirmEmail(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "ConfirmEmail.userAPI.ConfirmEmail")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *userHttpTransport) FindUsers(c *fiber.Ctx) error {
+	req := &FindUsersRequest{}
 	if err := c.QueryParser(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, helper.ErrInvalidArgument, "FindUsers.c.QueryParser")
 	}
 
-	resp, err := s.userAPI.GetUserByID(req)
+	resp, err := s.userAPI.FindUsers(c.UserContext(), req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, err, "FindUsers.userAPI.FindUsers")
 	}
 
 	return c.JSON(resp)
 }
 
-func (s *userHttpTransport) GetUserData(c *fiber.Ctx) error {
-	req := &FindUserByID{}
+func (s *userHttpTransport) SetUserActive(c *fiber.Ctx) error {
+	req := &SetActiveRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "SetUserActive.c.BodyParser")
+	}
+	if err := adminUserRequest(c, &req.AdminUserRequest); err != nil {
+		return helper.HTTPError(c, err, "SetUserActive.adminUserRequest")
+	}
+
+	resp, err := s.userAPI.SetUserActive(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "SetUserActive.userAPI.SetUserActive")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *userHttpTransport) DeleteUser(c *fiber.Ctx) error {
+	req := &AdminUserRequest{}
+	if err := adminUserRequest(c, req); err != nil {
+		return helper.HTTPError(c, err, "DeleteUser.adminUserRequest")
+	}
+
+	resp, err := s.userAPI.DeleteUser(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "DeleteUser.userAPI.DeleteUser")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *userHttpTransport) RestoreUser(c *fiber.Ctx) error {
+	req := &AdminUserRequest{}
+	if err := adminUserRequest(c, req); err != nil {
+		return helper.HTTPError(c, err, "RestoreUser.adminUserRequest")
+	}
+
+	resp, err := s.userAPI.RestoreUser(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "RestoreUser.userAPI.RestoreUser")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *userHttpTransport) ForcePasswordReset(c *fiber.Ctx) error {
+	req := &AdminUserRequest{}
+	if err := adminUserRequest(c, req); err != nil {
+		return helper.HTTPError(c, err, "ForcePasswordReset.adminUserRequest")
+	}
+
+	resp, err := s.userAPI.ForcePasswordReset(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "ForcePasswordReset.userAPI.ForcePasswordReset")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *userHttpTransport) SetUserRole(c *fiber.Ctx) error {
+	req := &SetRoleRequest{}
+	if err := c.BodyParser(req); err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidBody.Wrap(err), "SetUserRole.c.BodyParser")
+	}
+	if err := adminUserRequest(c, &req.AdminUserRequest); err != nil {
+		return helper.HTTPError(c, err, "SetUserRole.adminUserRequest")
+	}
+
+	resp, err := s.userAPI.SetUserRole(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "SetUserRole.userAPI.SetUserRole")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *userHttpTransport) Impersonate(c *fiber.Ctx) error {
+	req := &ImpersonateRequest{}
+	if err := adminUserRequest(c, &req.AdminUserRequest); err != nil {
+		return helper.HTTPError(c, err, "Impersonate.adminUserRequest")
+	}
+	req.UserAgent = c.Get(fiber.HeaderUserAgent)
+	req.IP = c.IP()
+
+	resp, err := s.userAPI.Impersonate(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "Impersonate.userAPI.Impersonate")
+	}
+
+	return c.JSON(resp)
+}
+
+// adminUserRequest fills the target user from the path and the admin from
+// the authenticated principal.
+func adminUserRequest(c *fiber.Ctx, req *AdminUserRequest) error {
+	adminId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return err
+	}
+	userId, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return helper.ErrInvalidArgument
+	}
+	req.UserID = userId
+	req.AdminID = adminId
+	req.Actor = audit.ActorFrom(c)
+
+	return nil
+}
+
+func (s *userHttpTransport) UploadAvatar(c *fiber.Ctx) error {
+	req := &AvatarUploadRequest{}
+	userId, err := middleware.CtxUserID(c)
+	if err != nil {
+		return helper.HTTPError(c, err, "UploadAvatar.middleware.CtxUserID")
+	}
+	file, err := c.FormFile("avatar")
+	if err != nil {
+		return helper.HTTPError(c, apperr.New(apperr.Invalid, "missing_avatar", "invalid avatar, multipart field avatar is required"), "UploadAvatar.c.FormFile")
+	}
+	if file.Size > MaxAvatarBytes {
+		return helper.HTTPError(c, apperr.Newf(apperr.Invalid, "avatar_too_large", "invalid avatar, the file is larger than %d MB", MaxAvatarBytes/1024/1024), "UploadAvatar.file.Size")
+	}
+	f, err := file.Open()
+	if err != nil {
+		return helper.HTTPError(c, err, "UploadAvatar.file.Open")
+	}
+	defer f.Close()
+	// Read one byte over the limit so the service can reject oversized files
+	image, err := io.ReadAll(io.LimitReader(f, MaxAvatarBytes+1))
+	if err != nil {
+		return helper.HTTPError(c, err, "UploadAvatar.io.ReadAll")
+	}
+	req.UserID = userId
+	req.Image = image
+	req.Actor = audit.ActorFrom(c)
+
+	resp, err := s.userAPI.UploadAvatar(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "UploadAvatar.userAPI.UploadAvatar")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *userHttpTransport) DeleteAvatar(c *fiber.Ctx) error {
+	req := &AvatarRequest{}
 	userId, err := middleware.CtxUserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, err, "DeleteAvatar.middleware.CtxUserID")
+	}
+	req.UserID = userId
+	req.Actor = audit.ActorFrom(c)
+
+	resp, err := s.userAPI.DeleteAvatar(c.UserContext(), req)
+	if err != nil {
+		return helper.HTTPError(c, err, "DeleteAvatar.userAPI.DeleteAvatar")
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *userHttpTransport) GetAvatar(c *fiber.Ctx) error {
+	req := &AvatarRequest{}
+	userId, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return helper.HTTPError(c, helper.ErrInvalidArgument, "GetAvatar.strconv.Atoi")
 	}
 	req.UserID = userId
 
-	resp, err := s.userAPI.GetUserData(req)
+	resp, err := s.userAPI.GetAvatar(c.UserContext(), req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return helper.HTTPError(c, err, "GetAvatar.userAPI.GetAvatar")
 	}
 
 	return c.JSON(resp)
@@ -0,0 +1,191 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MinPasswordLength and MaxPasswordLength bound the "password" rule;
+	// bcrypt ignores everything after 72 bytes
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+func init() {
+	Register("required", required)
+	Register("email", email)
+	Register("slug", slug)
+	Register("password", password)
+	Register("min", minRule)
+	Register("max", maxRule)
+	Register("oneof", oneOf)
+	Register("eqfield", eqField)
+	Register("gtfield", gtField)
+	Register("gtefield", gteField)
+}
+
+func required(f Field) string {
+	if isZero(f.Value) {
+		return "is required"
+	}
+	return ""
+}
+
+func email(f Field) string {
+	address, err := mail.ParseAddress(f.Value.String())
+	// ParseAddress also accepts "Name <address>", only bare addresses are
+	// valid here
+	if err != nil || address.Address != strings.TrimSpace(f.Value.String()) {
+		return "must be a valid email address"
+	}
+	return ""
+}
+
+func slug(f Field) string {
+	if !slugPattern.MatchString(f.Value.String()) {
+		return "must contain only lower case letters, digits and single hyphens"
+	}
+	return ""
+}
+
+// password requires MinPasswordLength to MaxPasswordLength bytes with at
+// least one letter and one digit.
+func password(f Field) string {
+	value := f.Value.String()
+	var letter, digit bool
+	for _, r := range value {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	switch {
+	case utf8.RuneCountInString(value) < MinPasswordLength:
+		return fmt.Sprintf("must be at least %d characters", MinPasswordLength)
+	case len(value) > MaxPasswordLength:
+		return fmt.Sprintf("must be at most %d bytes", MaxPasswordLength)
+	case !letter || !digit:
+		return "must contain at least one letter and one digit"
+	}
+	return ""
+}
+
+// minRule and maxRule bound the length of strings and slices and the value of
+// numbers.
+func minRule(f Field) string {
+	limit := mustParam(f)
+	size, unit := measure(f.Value)
+	if size < limit {
+		return fmt.Sprintf("must be at least %s%s", strconv.FormatFloat(limit, 'f', -1, 64), unit)
+	}
+	return ""
+}
+
+func maxRule(f Field) string {
+	limit := mustParam(f)
+	size, unit := measure(f.Value)
+	if size > limit {
+		return fmt.Sprintf("must be at most %s%s", strconv.FormatFloat(limit, 'f', -1, 64), unit)
+	}
+	return ""
+}
+
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	}
+	panic(fmt.Sprintf("validation: can't measure %s", v.Kind()))
+}
+
+func mustParam(f Field) float64 {
+	limit, err := strconv.ParseFloat(f.Param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: invalid parameter %q on %s", f.Param, f.Name))
+	}
+	return limit
+}
+
+// oneOf takes the allowed values separated by spaces, e.g. "oneof=en sq".
+func oneOf(f Field) string {
+	allowed := strings.Fields(f.Param)
+	value := fmt.Sprint(f.Value.Interface())
+	for _, a := range allowed {
+		if value == a {
+			return ""
+		}
+	}
+	return "must be one of " + strings.Join(allowed, ", ")
+}
+
+func eqField(f Field) string {
+	other, name := sibling(f)
+	if !reflect.DeepEqual(f.Value.Interface(), other.Interface()) {
+		return "must match " + name
+	}
+	return ""
+}
+
+// gtField and gteField compare times or numbers with another field, e.g.
+// `validate:"gtefield=StartDate"` on the end of a date range. They pass
+// while the other field is unset, leave that to its own rules.
+func gtField(f Field) string {
+	other, name := sibling(f)
+	if isZero(other) {
+		return ""
+	}
+	if compare(f.Value, other) <= 0 {
+		return "must be after " + name
+	}
+	return ""
+}
+
+func gteField(f Field) string {
+	other, name := sibling(f)
+	if isZero(other) {
+		return ""
+	}
+	if compare(f.Value, other) < 0 {
+		return "must not be before " + name
+	}
+	return ""
+}
+
+func sibling(f Field) (reflect.Value, string) {
+	sf, ok := f.Parent.Type().FieldByName(f.Param)
+	if !ok {
+		panic(fmt.Sprintf("validation: %s refers to unknown field %q", f.Name, f.Param))
+	}
+	return f.Parent.FieldByIndex(sf.Index), fieldName(sf)
+}
+
+func compare(a, b reflect.Value) int {
+	if ta, ok := a.Interface().(time.Time); ok {
+		return ta.Compare(b.Interface().(time.Time))
+	}
+	x, _ := measure(a)
+	y, _ := measure(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,176 @@
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"vezhguesi/core/apperr"
+)
+
+// ErrValidation is returned by Struct. Its "fields" detail lists a
+// FieldError for every failing rule.
+var ErrValidation = apperr.New(apperr.Invalid, "validation_failed", "invalid request")
+
+// FieldError reports one failed rule. Field is the JSON path of the field,
+// e.g. "entities[0].name".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Field is the value a Rule checks along with the struct holding it, so rules
+// can compare fields.
+type Field struct {
+	Name   string
+	Value  reflect.Value
+	Parent reflect.Value
+	Param  string
+}
+
+// Rule checks a field and returns what's wrong with it, or "" when it is
+// valid. The message is prefixed with the field name.
+type Rule func(f Field) string
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]Rule{}
+)
+
+// Register adds a rule usable in validate tags. Rules are registered at
+// init, registering a name twice replaces the rule.
+func Register(name string, rule Rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = rule
+}
+
+func lookup(name string) (Rule, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	rule, ok := rules[name]
+	return rule, ok
+}
+
+// Struct validates v, a struct or a pointer to one, against the
+// `validate:"rule,rule=param"` tags of its fields, descending into nested
+// structs and slices of structs. A field tagged "omitempty" is only checked
+// when it is set. Every failure is reported, not just the first.
+func Struct(v any) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ErrValidation.WithMessage("invalid request, the body is empty")
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validation: Struct called with %s", value.Kind()))
+	}
+
+	var fields []FieldError
+	validateStruct(value, "", &fields)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(fields))
+	for i, f := range fields {
+		messages[i] = f.Message
+	}
+	return ErrValidation.
+		WithMessage("invalid request, "+strings.Join(messages, ", ")).
+		WithDetail("fields", fields)
+}
+
+func validateStruct(value reflect.Value, prefix string, fields *[]FieldError) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := prefix + fieldName(sf)
+		fv := value.Field(i)
+
+		if tag := sf.Tag.Get("validate"); tag != "" && tag != "-" {
+			validateField(Field{Name: name, Value: fv, Parent: value}, tag, fields)
+		}
+		validateNested(fv, name, fields)
+	}
+}
+
+func validateNested(value reflect.Value, name string, fields *[]FieldError) {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if !value.IsNil() {
+			validateNested(value.Elem(), name, fields)
+		}
+	case reflect.Struct:
+		// time.Time and similar values have no tagged fields to check
+		if value.Type() != reflect.TypeOf(time.Time{}) {
+			validateStruct(value, name+".", fields)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			validateNested(value.Index(i), fmt.Sprintf("%s[%d]", name, i), fields)
+		}
+	}
+}
+
+func validateField(f Field, tag string, fields *[]FieldError) {
+	names := strings.Split(tag, ",")
+	for _, name := range names {
+		if name == "omitempty" && isZero(f.Value) {
+			return
+		}
+	}
+
+	for _, name := range names {
+		if name == "omitempty" {
+			continue
+		}
+		name, f.Param, _ = strings.Cut(name, "=")
+		rule, ok := lookup(name)
+		if !ok {
+			panic(fmt.Sprintf("validation: unknown rule %q on %s", name, f.Name))
+		}
+		if message := rule(f); message != "" {
+			*fields = append(*fields, FieldError{Field: f.Name, Rule: name, Message: f.Name + " " + message})
+			// Later rules usually depend on earlier ones, e.g. email on
+			// required, so only the first failure is reported per field
+			return
+		}
+	}
+}
+
+// fieldName is the JSON, query parameter or YAML name of a field, or its Go
+// name with a lower case first letter for fields hidden from clients, e.g.
+// "userID" for UserID.
+func fieldName(sf reflect.StructField) string {
+	for _, key := range []string{"json", "query", "yaml"} {
+		name, _, _ := strings.Cut(sf.Tag.Get(key), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	runes := []rune(sf.Name)
+	for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+func isZero(v reflect.Value) bool {
+	if v.Kind() == reflect.String {
+		return strings.TrimSpace(v.String()) == ""
+	}
+	return v.IsZero()
+}
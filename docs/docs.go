@@ -51,7 +51,7 @@ const docTemplate = `{
         },
         "/api/auth/forgot-password": {
             "post": {
-                "description": "Sends email with reset password link to user.",
+                "description": "Sends email with reset password link to user. Succeeds for unknown emails too.",
                 "consumes": [
                     "application/json"
                 ],
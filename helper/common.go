@@ -7,4 +7,7 @@ const (
 	ManagerRoleName = "manager"
 	PartnerRoleName = "partner"
 	MemberRoleName  = "member"
-)
\ No newline at end of file
+
+	// UserRoleName is the global role of users that aren't admins
+	UserRoleName = "user"
+)
@@ -1,19 +1,27 @@
 package helper
 
-import "errors"
+import (
+	"errors"
+
+	"vezhguesi/core/apperr"
+)
 
 var (
-	ErrNotFound        = errors.New("not found")
-	ErrDuplicateEntry  = errors.New("duplicate entry")
-	ErrInvalidArgument = errors.New("invalid argument")
-	ErrMissingId       = errors.New("missing id")
-	ErrMissingToken    = errors.New("missing token")
-	ErrParsingValue    = errors.New("error parsing value")
+	ErrNotFound        = apperr.New(apperr.NotFound, "not_found", "not found")
+	ErrDuplicateEntry  = apperr.New(apperr.Conflict, "duplicate_entry", "duplicate entry")
+	ErrInvalidArgument = apperr.New(apperr.Invalid, "invalid_argument", "invalid argument")
+	ErrMissingId       = apperr.New(apperr.Invalid, "missing_id", "missing id")
+	ErrMissingToken    = apperr.New(apperr.Invalid, "missing_token", "missing token")
+	ErrParsingValue    = apperr.New(apperr.Invalid, "parsing_value", "error parsing value")
+	ErrInvalidBody     = apperr.New(apperr.Invalid, "invalid_body", "invalid request body")
+	ErrInvalidQuery    = apperr.New(apperr.Invalid, "invalid_query", "invalid query parameters")
+	ErrUnauthenticated = apperr.New(apperr.Unauthenticated, "unauthenticated", "unauthenticated")
+	ErrForbidden       = apperr.New(apperr.Forbidden, "forbidden", "forbidden")
 )
 
-func InvalidRequest(err string) error {
-	ErrInvalidArgument = errors.New(err)
-	return ErrInvalidArgument
+// InvalidRequest returns an invalid argument error with the given message.
+func InvalidRequest(message string) error {
+	return ErrInvalidArgument.WithMessage(message)
 }
 
 func str2err(err string) error {
@@ -0,0 +1,18 @@
+package articles
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	articlesSynced = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "vezhguesi_articles_synced",
+		Help:    "Articles received from the article server per successful sync.",
+		Buckets: []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000},
+	})
+	entityTagsSaved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vezhguesi_entity_tags_saved_total",
+		Help: "Article to entity links saved, by source: sync, as articles are stored, or retag.",
+	}, []string{"source"})
+)
@@ -0,0 +1,14 @@
+package articles
+
+import (
+	session "vezhguesi/core/authentication"
+	"vezhguesi/core/middleware"
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterRoutes(router fiber.Router, serverHttpApi ServerHTTPTransport, authMiddleware func(c *fiber.Ctx) error) {
+	articlesRoutes := router.Group("/articles")
+	articlesRoutes.Post("/sync", middleware.RequireScope(session.ScopeArticlesSync), authMiddleware, middleware.RequireRole(helper.AdminRoleName), serverHttpApi.SyncArticles)
+}
@@ -2,58 +2,90 @@ package articles
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"time"
 
 	analysesvc "vezhguesi/app/analyses"
 	articlesvc "vezhguesi/app/articles"
 	entitiesvc "vezhguesi/app/entities"
+	usagesvc "vezhguesi/app/usage"
+	"vezhguesi/core/apperr"
+	"vezhguesi/core/audit"
+	"vezhguesi/core/config"
+	"vezhguesi/core/metrics"
+	"vezhguesi/core/tracing"
 
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/lib/pq"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// reanalyzeBatchSize is how many articles are sent to the analysis server
+// per request when reanalyzing
+const reanalyzeBatchSize = 50
+
+// storeBatchSize is how many fetched articles are stored, or stored
+// articles tagged, per transaction
+const storeBatchSize = 100
+
+// ErrServerUnavailable wraps failed calls to the article and analysis server
+var ErrServerUnavailable = apperr.New(apperr.Unavailable, "analysis_server_unavailable", "the analysis server is unavailable")
+
 type serverApi struct {
 	db *gorm.DB
 	logger log.AllLogger
+	usageApi usagesvc.UsageAPI
+	cfg config.Analysis
+	// client counts and times the calls to the server on /metrics, and
+	// traces those made with a request context
+	client *http.Client
 }
 
 type ServerAPI interface {
 	FetchArticles() ([]articlesvc.Article, error)
-	AnalyzeArticles(articleIds *[]int) (res *AnalyzeArticlesResponse, err error)
-	GetAnalyzes(req []string) (res *GetAnalyzesResponse, err error)
-	FetchAndStoreArticles() error
-	FetchArticlesByEntity(entityName []string) ([]articlesvc.Article, error)
+	AnalyzeArticles(ctx context.Context, payer usagesvc.Payer, articleIds *[]int) (res *AnalyzeArticlesResponse, err error)
+	GetAnalyzes(ctx context.Context, payer usagesvc.Payer, req []string) (res *GetAnalyzesResponse, err error)
+	FetchAndStoreArticles(ctx context.Context) error
+	SyncArticles(ctx context.Context, actor audit.Actor) error
+	FetchArticlesByEntity(ctx context.Context, entityName []string) ([]articlesvc.Article, error)
+	ReanalyzeArticles(ctx context.Context, since time.Time) (int, error)
+	TagArticleEntities(ctx context.Context) (int, error)
 }
 
-func NewServerAPI(db *gorm.DB, logger log.AllLogger) ServerAPI {
-	return &serverApi{db: db, logger: logger}
+func NewServerAPI(db *gorm.DB, logger log.AllLogger, usageApi usagesvc.UsageAPI, cfg config.Analysis) ServerAPI {
+	return &serverApi{
+		db:       db,
+		logger:   logger,
+		usageApi: usageApi,
+		cfg:      cfg,
+		client:   &http.Client{Transport: tracing.NewTransport(metrics.NewTransport("analysis_server", nil))},
+	}
 }
 
 func (s *serverApi) FetchArticles() ([]articlesvc.Article, error) {
 	// Make an HTTP GET request to fetch the articles data
-	resp, err := http.Get(fmt.Sprintf("%s:%s/articles", os.Getenv("SERVER_URL"), os.Getenv("SERVER_ARTICLES_PORT")))
+	resp, err := s.client.Get(s.cfg.ArticlesURL()+"/articles")
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch articles: %v", err)
+		return nil, ErrServerUnavailable.Wrap(fmt.Errorf("failed to fetch articles: %v", err))
 	}
 	defer resp.Body.Close()
 	var resArticles []articlesvc.Article
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch articles: status code %d", resp.StatusCode)
+		return nil, ErrServerUnavailable.Wrap(fmt.Errorf("failed to fetch articles: status code %d", resp.StatusCode))
 	}
 
 	// Decode the JSON response
 	var articles []Articles
 	if err := json.NewDecoder(resp.Body).Decode(&articles); err != nil {
-		return nil, fmt.Errorf("failed to decode articles data: %v", err)
+		return nil, ErrServerUnavailable.Wrap(fmt.Errorf("failed to decode articles data: %v", err))
 	}
 	for _, article := range articles {
 		// Parse the time strings
@@ -110,12 +142,14 @@ func (s *serverApi) FetchArticles() ([]articlesvc.Article, error) {
 	return resArticles, nil
 }
 
-func (s *serverApi) AnalyzeArticles(articleIds *[]int) (res *AnalyzeArticlesResponse, err error) {
+// AnalyzeArticles returns the analyses of the articles, sending those not
+// analyzed yet to the analysis server. The call is billed to payer.
+func (s *serverApi) AnalyzeArticles(ctx context.Context, payer usagesvc.Payer, articleIds *[]int) (res *AnalyzeArticlesResponse, err error) {
 	// Check which articles we already have analyses for
 	var existingAnalyses []analysesvc.Analysis
 	var uncachedArticleIds []int
 	
-	if err := s.db.Where("article_id = ANY(?)", pq.Array(*articleIds)).Find(&existingAnalyses).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("article_id = ANY(?)", pq.Array(*articleIds)).Find(&existingAnalyses).Error; err != nil {
 		return nil, fmt.Errorf("failed to query existing analyses: %v", err)
 	}
 
@@ -136,6 +170,17 @@ func (s *serverApi) AnalyzeArticles(articleIds *[]int) (res *AnalyzeArticlesResp
 		return s.buildAnalysisResponse(existingAnalyses), nil
 	}
 
+	return s.analyze(ctx, payer, uncachedArticleIds)
+}
+
+// analyze sends the articles to the analysis server and stores the results,
+// replacing earlier analyses of the same articles.
+func (s *serverApi) analyze(ctx context.Context, payer usagesvc.Payer, uncachedArticleIds []int) (*AnalyzeArticlesResponse, error) {
+	// Every upstream call is billable, so check the quota before making it
+	if err := s.usageApi.CheckQuota(ctx, &usagesvc.CheckQuotaRequest{Payer: payer, Kind: usagesvc.KindAnalysisCall}); err != nil {
+		return nil, err
+	}
+
 	// Request analysis only for uncached articles
 	payload := map[string]interface{}{
 		"article_id": uncachedArticleIds,
@@ -148,32 +193,39 @@ func (s *serverApi) AnalyzeArticles(articleIds *[]int) (res *AnalyzeArticlesResp
 	}
 
 	// Create a new HTTP request
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s:%s/analyze-batch", os.Getenv("SERVER_URL"), os.Getenv("SERVER_ANALYSIS_PORT")), bytes.NewBuffer(articleIdsJSON))
+	req, err := http.NewRequestWithContext(ctx, "POST", s.cfg.AnalysisURL()+"/analyze-batch", bytes.NewBuffer(articleIdsJSON))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
 	// Set the content type and authorization headers
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-KEY", os.Getenv("SERVER_API_KEY"))
+	req.Header.Set("X-API-KEY", s.cfg.APIKey.Value())
 
 	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to analyze articles: %v", err)
+		return nil, ErrServerUnavailable.Wrap(fmt.Errorf("failed to analyze articles: %v", err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to analyze articles: status code %d", resp.StatusCode)
+		return nil, ErrServerUnavailable.Wrap(fmt.Errorf("failed to analyze articles: status code %d", resp.StatusCode))
+	}
+
+	// Only calls the server answered are billed
+	if err := s.usageApi.RecordAnalysisUsage(ctx, &usagesvc.RecordAnalysisUsageRequest{
+		UserID:           payer.UserID,
+		ArticlesAnalyzed: len(uncachedArticleIds),
+	}); err != nil {
+		s.logger.Errorf("Failed to record analysis usage: %v", err)
 	}
 
 	// Attempt to decode the JSON response
 	var response AnalyzeArticlesResponse
 
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode analyzed articles data: %v", err)
+		return nil, ErrServerUnavailable.Wrap(fmt.Errorf("failed to decode analyzed articles data: %v", err))
 	}
 
 	// Store new analyses in database
@@ -188,7 +240,11 @@ func (s *serverApi) AnalyzeArticles(articleIds *[]int) (res *AnalyzeArticlesResp
 			Topics:        string(topicsJSON),
 		}
 
-		if err := s.db.Create(&analysis).Error; err != nil {
+		err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "article_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"article_summary", "entities", "topics", "updated_at"}),
+		}).Create(&analysis).Error
+		if err != nil {
 			s.logger.Errorf("Failed to cache analysis: %v", err)
 			// Continue even if caching fails
 		}
@@ -226,12 +282,19 @@ func (s *serverApi) buildAnalysisResponse(analyses []analysesvc.Analysis) *Analy
 	}
 }
 
-func (s *serverApi) GetAnalyzes(req []string) (res *GetAnalyzesResponse, err error) {
+// GetAnalyzes searches the analysis server for the articles mentioning the
+// terms. The search is billed to payer as an analysis call.
+func (s *serverApi) GetAnalyzes(ctx context.Context, payer usagesvc.Payer, req []string) (res *GetAnalyzesResponse, err error) {
+	logger := s.logger.WithContext(ctx)
 	// Log the request
-	s.logger.Infof("GetAnalyzes called with terms: %v", req)
+	logger.Infof("GetAnalyzes called with terms: %v", req)
 
-	baseUrl := fmt.Sprintf("%s:%s/search", os.Getenv("SERVER_URL"), os.Getenv("SERVER_ANALYSIS_PORT"))
-	s.logger.Infof("Using base URL: %s", baseUrl)
+	if err := s.usageApi.CheckQuota(ctx, &usagesvc.CheckQuotaRequest{Payer: payer, Kind: usagesvc.KindAnalysisCall}); err != nil {
+		return nil, err
+	}
+
+	baseUrl := s.cfg.AnalysisURL()+"/search"
+	logger.Debugf("Using base URL: %s", baseUrl)
 
 	u, err := url.Parse(baseUrl)
 	if err != nil {
@@ -244,38 +307,46 @@ func (s *serverApi) GetAnalyzes(req []string) (res *GetAnalyzesResponse, err err
 	}
 	u.RawQuery = query.Encode()
 
-	s.logger.Infof("Making request to: %s", u.String())
+	logger.Debugf("Making request to: %s", u.String())
 
-	request, err := http.NewRequest("GET", u.String(), nil)
+	request, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
-	request.Header.Set("X-API-Key", os.Getenv("SERVER_API_KEY"))
+	request.Header.Set("X-API-Key", s.cfg.APIKey.Value())
 
-	client := &http.Client{}
-	resp, err := client.Do(request)
+	resp, err := s.client.Do(request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send GET request: %v", err)
+		return nil, ErrServerUnavailable.Wrap(fmt.Errorf("failed to send GET request: %v", err))
 	}
 	defer resp.Body.Close()
 
 	// Log the response status
-	s.logger.Infof("Got response with status: %d", resp.StatusCode)
+	logger.Debugf("Got response with status: %d", resp.StatusCode)
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		s.logger.Errorf("Error response body: %s", string(bodyBytes))
-		return nil, fmt.Errorf("failed to get analyzes: status code %d", resp.StatusCode)
+		logger.Errorf("Error response body: %s", string(bodyBytes))
+		return nil, ErrServerUnavailable.Wrap(fmt.Errorf("failed to get analyzes: status code %d", resp.StatusCode))
 	}
 
 	var response GetAnalyzesResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode analyzed articles data: %v", err)
+		return nil, ErrServerUnavailable.Wrap(fmt.Errorf("failed to decode analyzed articles data: %v", err))
 	}
 
 	// Log the response data
-	s.logger.Infof("Got response with %d articles", len(response.Results.Articles))
+	logger.Infof("Got response with %d articles of %d analyzed", len(response.Results.Articles), response.Results.TotalArticles)
+
+	// Only answered calls are billed, for the articles the server analyzed
+	// rather than the ones returned
+	if err := s.usageApi.RecordAnalysisUsage(ctx, &usagesvc.RecordAnalysisUsageRequest{
+		UserID:           payer.UserID,
+		ArticlesAnalyzed: response.Results.TotalArticles,
+	}); err != nil {
+		logger.Errorf("Failed to record analysis usage: %v", err)
+	}
 
 	return &response, nil
 }
@@ -289,9 +360,11 @@ func marshalToJson(data interface{}) string {
 	return string(jsonData)
 }
 
-func (s *serverApi) FetchArticlesByEntity(entityNames []string) ([]articlesvc.Article, error) {
+func (s *serverApi) FetchArticlesByEntity(ctx context.Context, entityNames []string) ([]articlesvc.Article, error) {
+	db := s.db.WithContext(ctx)
+
 	// Parse the base URL
-	u, err := url.Parse(fmt.Sprintf("%s:%s/articles/search", os.Getenv("SERVER_URL"), os.Getenv("SERVER_ARTICLES_PORT")))
+	u, err := url.Parse(s.cfg.ArticlesURL()+"/articles/search")
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse URL: %v", err)
 	}
@@ -307,9 +380,13 @@ func (s *serverApi) FetchArticlesByEntity(entityNames []string) ([]articlesvc.Ar
 	s.logger.Infof("Fetching articles from: %s", u.String())
 
 	// Make the request
-	resp, err := http.Get(u.String())
+	request, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch articles: %v", err)
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	resp, err := s.client.Do(request)
+	if err != nil {
+		return nil, ErrServerUnavailable.Wrap(fmt.Errorf("failed to fetch articles: %v", err))
 	}
 	defer resp.Body.Close()
 
@@ -317,15 +394,15 @@ func (s *serverApi) FetchArticlesByEntity(entityNames []string) ([]articlesvc.Ar
 		bodyBytes, err := io.ReadAll(resp.Body)
 		if err != nil {
 			s.logger.Errorf("Failed to read error response body: %v", err)
-			return nil, fmt.Errorf("Server error response: %s", string(bodyBytes))
+			return nil, ErrServerUnavailable.Wrap(fmt.Errorf("Server error response: %s", string(bodyBytes)))
 		}
-		return nil, fmt.Errorf("Server error response: %s", string(bodyBytes))
+		return nil, ErrServerUnavailable.Wrap(fmt.Errorf("Server error response: %s", string(bodyBytes)))
 	}
 
 	// Decode response
 	var articles []Articles
 	if err := json.NewDecoder(resp.Body).Decode(&articles); err != nil {
-		return nil, fmt.Errorf("failed to decode articles: %v", err)
+		return nil, ErrServerUnavailable.Wrap(fmt.Errorf("failed to decode articles: %v", err))
 	}
 
 	// Convert to articlesvc.Article format
@@ -343,10 +420,10 @@ func (s *serverApi) FetchArticlesByEntity(entityNames []string) ([]articlesvc.Ar
 
 		// Handle URL
 		var url URL
-		if err := s.db.Where("path = ?", article.URL).First(&url).Error; err != nil {
+		if err := db.Where("path = ?", article.URL).First(&url).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				url = URL{Path: article.URL}
-				if err := s.db.Create(&url).Error; err != nil {
+				if err := db.Create(&url).Error; err != nil {
 					return nil, fmt.Errorf("failed to create URL: %v", err)
 				}
 			} else {
@@ -366,7 +443,7 @@ func (s *serverApi) FetchArticlesByEntity(entityNames []string) ([]articlesvc.Ar
 		}
 
 		// Save article if it doesn't exist
-		if err := s.db.Where("id = ?", article.ID).FirstOrCreate(&newArticle).Error; err != nil {
+		if err := db.Where("id = ?", article.ID).FirstOrCreate(&newArticle).Error; err != nil {
 			return nil, fmt.Errorf("failed to save article: %v", err)
 		}
 
@@ -376,95 +453,220 @@ func (s *serverApi) FetchArticlesByEntity(entityNames []string) ([]articlesvc.Ar
 	return result, nil
 }
 
-func (s *serverApi) FetchAndStoreArticles() error {
+// SyncArticles runs FetchAndStoreArticles on behalf of actor and audits it.
+func (s *serverApi) SyncArticles(ctx context.Context, actor audit.Actor) error {
+	if err := s.FetchAndStoreArticles(ctx); err != nil {
+		return err
+	}
+
+	err := audit.Record(s.db.WithContext(ctx), audit.Event{Actor: actor, Action: audit.ActionArticlesSynced})
+	if err != nil {
+		s.logger.Errorf("func: SyncArticles, operation: audit.Record, err: %s", err.Error())
+	}
+
+	return nil
+}
+
+// FetchAndStoreArticles fetches the articles from the article server and
+// stores them with their entity links, a batch per transaction. When ctx is
+// cancelled it stops between batches: a started batch is stored, the stored
+// ones are kept and the next sync stores the rest.
+func (s *serverApi) FetchAndStoreArticles(ctx context.Context) error {
 	// Fetch articles from external service
-	resp, err := http.Get(fmt.Sprintf("%s:%s/articles", os.Getenv("SERVER_URL"), os.Getenv("SERVER_ARTICLES_PORT")))
+	req, err := http.NewRequestWithContext(ctx, "GET", s.cfg.ArticlesURL()+"/articles", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	resp, err := s.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to fetch articles: %v", err)
+		return ErrServerUnavailable.Wrap(fmt.Errorf("failed to fetch articles: %v", err))
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return ErrServerUnavailable.Wrap(fmt.Errorf("failed to fetch articles: status code %d", resp.StatusCode))
+	}
+
 	var articles []Articles
 	if err := json.NewDecoder(resp.Body).Decode(&articles); err != nil {
-		return fmt.Errorf("failed to decode articles: %v", err)
+		return ErrServerUnavailable.Wrap(fmt.Errorf("failed to decode articles: %v", err))
 	}
 
-	// Begin transaction
-	tx := s.db.Begin()
-
 	// Get all existing entities for matching
 	var entities []entitiesvc.Entity
-	if err := tx.Find(&entities).Error; err != nil {
-		tx.Rollback()
+	if err := s.db.WithContext(ctx).Find(&entities).Error; err != nil {
 		return fmt.Errorf("failed to fetch entities: %v", err)
 	}
 
-	for _, article := range articles {
-		// First, handle the URL
-		var url URL
-		if err := tx.Where("path = ?", article.URL).First(&url).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				url = URL{Path: article.URL}
-				if err := tx.Create(&url).Error; err != nil {
-					tx.Rollback()
-					return fmt.Errorf("failed to create URL: %v", err)
+	db := s.db.WithContext(context.WithoutCancel(ctx))
+	tagged := 0
+	for start := 0; start < len(articles); start += storeBatchSize {
+		if err := ctx.Err(); err != nil {
+			entityTagsSaved.WithLabelValues("sync").Add(float64(tagged))
+			return fmt.Errorf("stopped after storing %d/%d articles: %w", start, len(articles), err)
+		}
+
+		batch := articles[start:min(start+storeBatchSize, len(articles))]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, article := range batch {
+				created, err := storeArticle(tx, article, entities)
+				if err != nil {
+					return err
 				}
-			} else {
-				tx.Rollback()
-				return fmt.Errorf("failed to query URL: %v", err)
+				tagged += created
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
+	}
 
-		// Parse dates
-		publishedDate, err := time.Parse("2006-01-02T15:04:05.999999", article.PublishedDate)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to parse published date: %v", err)
+	articlesSynced.Observe(float64(len(articles)))
+	entityTagsSaved.WithLabelValues("sync").Add(float64(tagged))
+	return nil
+}
+
+// storeArticle saves the article and its URL and links it to the entities
+// it mentions. It returns how many links were created.
+func storeArticle(tx *gorm.DB, article Articles, entities []entitiesvc.Entity) (int, error) {
+	// First, handle the URL
+	var url URL
+	if err := tx.Where("path = ?", article.URL).First(&url).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return 0, fmt.Errorf("failed to query URL: %v", err)
 		}
-		scrapedAt, err := time.Parse("2006-01-02T15:04:05.999999", article.ScrapedAt)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to parse scraped at date: %v", err)
+		url = URL{Path: article.URL}
+		if err := tx.Create(&url).Error; err != nil {
+			return 0, fmt.Errorf("failed to create URL: %v", err)
 		}
+	}
 
-		dbArticle := articlesvc.Article{
-			ID:            article.ID,
-			ConfigID:      article.ConfigID,
-			URLID:         url.ID,
-			Title:         article.Title,
-			Content:       article.Content,
-			PublishedDate: publishedDate,
-			ScrapedAt:     scrapedAt,
+	// Parse dates
+	publishedDate, err := time.Parse("2006-01-02T15:04:05.999999", article.PublishedDate)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse published date: %v", err)
+	}
+	scrapedAt, err := time.Parse("2006-01-02T15:04:05.999999", article.ScrapedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse scraped at date: %v", err)
+	}
+
+	dbArticle := articlesvc.Article{
+		ID:            article.ID,
+		ConfigID:      article.ConfigID,
+		URLID:         url.ID,
+		Title:         article.Title,
+		Content:       article.Content,
+		PublishedDate: publishedDate,
+		ScrapedAt:     scrapedAt,
+	}
+
+	// Upsert article
+	if err := tx.Save(&dbArticle).Error; err != nil {
+		return 0, fmt.Errorf("failed to save article: %v", err)
+	}
+
+	// Check content for entity mentions
+	return tagArticle(tx, dbArticle, entities)
+}
+
+// ReanalyzeArticles analyzes the articles scraped since the given time
+// again, replacing their cached analyses as the new ones are stored. It
+// returns how many articles were sent for analysis.
+func (s *serverApi) ReanalyzeArticles(ctx context.Context, since time.Time) (int, error) {
+	var articleIDs []int
+	if err := s.db.WithContext(ctx).Model(&articlesvc.Article{}).Where("scraped_at >= ?", since).Order("id").Pluck("id", &articleIDs).Error; err != nil {
+		return 0, fmt.Errorf("failed to query articles: %v", err)
+	}
+
+	for start := 0; start < len(articleIDs); start += reanalyzeBatchSize {
+		batch := articleIDs[start:min(start+reanalyzeBatchSize, len(articleIDs))]
+		if _, err := s.analyze(ctx, usagesvc.JobPayer, batch); err != nil {
+			return start, err
 		}
+		s.logger.Infof("func: ReanalyzeArticles, analyzed: %d/%d", start+len(batch), len(articleIDs))
+	}
 
-		// Upsert article
-		if err := tx.Save(&dbArticle).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to save article: %v", err)
+	return len(articleIDs), nil
+}
+
+// TagArticleEntities links every stored article to the known entities
+// mentioned in its title or content, for entities added after the article
+// was synced. It returns how many links were created. Articles are tagged
+// storeBatchSize per transaction, and when ctx is cancelled it stops between
+// batches, keeping the links of the tagged ones.
+func (s *serverApi) TagArticleEntities(ctx context.Context) (created int, err error) {
+	var entities []entitiesvc.Entity
+	if err := s.db.WithContext(ctx).Find(&entities).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch entities: %w", err)
+	}
+	defer func() {
+		entityTagsSaved.WithLabelValues("retag").Add(float64(created))
+	}()
+
+	db := s.db.WithContext(context.WithoutCancel(ctx))
+	lastID := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return created, fmt.Errorf("stopped after linking %d article entities: %w", created, err)
 		}
 
-		// Check content for entity mentions
-		articleContent := strings.ToLower(article.Content)
-		articleTitle := strings.ToLower(article.Title)
-
-		for _, entity := range entities {
-			entityName := strings.ToLower(entity.Name)
-			// Check if entity is mentioned in title or content
-			if strings.Contains(articleContent, entityName) || strings.Contains(articleTitle, entityName) {
-				relation := articlesvc.ArticleEntity{
-					ArticleID:  article.ID,
-					EntityName: entity.Name,
-					// Default neutral sentiment until analyzed
-					SentimentScore: 0,
-					SentimentLabel: "neutral",
-				}
-				if err := tx.Save(&relation).Error; err != nil {
-					tx.Rollback()
-					return fmt.Errorf("failed to save entity relation: %v", err)
+		var articles []articlesvc.Article
+		err := s.db.WithContext(ctx).Where("id > ?", lastID).Order("id").Limit(storeBatchSize).Find(&articles).Error
+		if err != nil {
+			return created, fmt.Errorf("failed to fetch articles: %w", err)
+		}
+		if len(articles) == 0 {
+			return created, nil
+		}
+
+		batchCreated := 0
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, article := range articles {
+				n, err := tagArticle(tx, article, entities)
+				if err != nil {
+					return err
 				}
+				batchCreated += n
 			}
+			return nil
+		})
+		if err != nil {
+			return created, err
+		}
+		created += batchCreated
+		lastID = articles[len(articles)-1].ID
+	}
+}
+
+// tagArticle links the article to the entities mentioned in its title or
+// content. Existing links keep their sentiment. It returns how many links
+// were created.
+func tagArticle(tx *gorm.DB, article articlesvc.Article, entities []entitiesvc.Entity) (int, error) {
+	content := strings.ToLower(article.Content)
+	title := strings.ToLower(article.Title)
+
+	created := 0
+	for _, entity := range entities {
+		entityName := strings.ToLower(entity.Name)
+		if !strings.Contains(content, entityName) && !strings.Contains(title, entityName) {
+			continue
+		}
+
+		relation := articlesvc.ArticleEntity{
+			ArticleID:  article.ID,
+			EntityName: entity.Name,
+			// Default neutral sentiment until analyzed
+			SentimentScore: 0,
+			SentimentLabel: "neutral",
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&relation)
+		if result.Error != nil {
+			return created, fmt.Errorf("failed to save entity relation: %v", result.Error)
 		}
+		created += int(result.RowsAffected)
 	}
 
-	return tx.Commit().Error
+	return created, nil
 }
@@ -0,0 +1,35 @@
+package articles
+
+import (
+	"vezhguesi/core/audit"
+	"vezhguesi/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ServerHTTPTransport interface {
+	SyncArticles(c *fiber.Ctx) error
+}
+
+type serverHttpTransport struct {
+	serverAPI ServerAPI
+}
+
+func NewServerHTTPTransport(serverAPI ServerAPI) ServerHTTPTransport {
+	return &serverHttpTransport{serverAPI: serverAPI}
+}
+
+// @Summary      	Sync Articles
+// @Description		Fetches the latest articles from the analysis server and stores the new ones. Admins only; accepts API keys with the articles:sync scope.
+// @Tags			Articles
+// @Produce			json
+// @Param			Authorization  header string true "Authorization Key (e.g Bearer key or ApiKey key)"
+// @Success			200					{object}	map[string]bool
+// @Router			/api/articles/sync	[POST]
+func (s *serverHttpTransport) SyncArticles(c *fiber.Ctx) error {
+	if err := s.serverAPI.SyncArticles(c.UserContext(), audit.ActorFrom(c)); err != nil {
+		return helper.HTTPError(c, err, "SyncArticles.serverAPI.SyncArticles")
+	}
+
+	return c.JSON(fiber.Map{"status": true})
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	entitysvc "vezhguesi/app/entities"
+	orgsvc "vezhguesi/app/orgs"
+	privacysvc "vezhguesi/app/privacy"
+	reportsvc "vezhguesi/app/reports"
+	usagesvc "vezhguesi/app/usage"
+	apikeysvc "vezhguesi/core/apikeys"
+	"vezhguesi/core/apperr"
+	auditsvc "vezhguesi/core/audit"
+	session "vezhguesi/core/authentication"
+	authsvc "vezhguesi/core/authentication/auth"
+	"vezhguesi/core/authentication/oidc"
+	"vezhguesi/core/config"
+	"vezhguesi/core/db/migrations"
+	dbseeds "vezhguesi/core/db/seeds"
+	"vezhguesi/core/health"
+	"vezhguesi/core/lifecycle"
+	"vezhguesi/core/logging"
+	"vezhguesi/core/mailer"
+	"vezhguesi/core/metrics"
+	"vezhguesi/core/middleware"
+	"vezhguesi/core/scheduler"
+	"vezhguesi/core/storage"
+	"vezhguesi/core/tracing"
+	usersvc "vezhguesi/core/users"
+	_ "vezhguesi/docs" // Import the generated docs package
+	"vezhguesi/helper"
+	server "vezhguesi/sentiment-communication"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2/middleware/basicauth"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/swagger"
+)
+
+// articleFetchJob is the name of the scheduled article fetch.
+const articleFetchJob = "article-fetch"
+
+// loginAttemptRetention is how long login attempts are kept, well past the
+// window the login throttle looks at.
+const loginAttemptRetention = 24 * time.Hour
+
+// runServe runs the HTTP API along with the background email sender and
+// scheduled jobs until SIGINT or SIGTERM, then drains in-flight requests
+// and waits for the background work to finish. Pending migrations are
+// applied and the default roles seeded first, unless --migrate=false leaves
+// that to the migrate and seed commands.
+func runServe(env *environment, args []string) error {
+	fs := newFlagSet("serve")
+	port := fs.Int("port", env.cfg.HTTP.Port, "port to listen on")
+	migrate := fs.Bool("migrate", true, "apply pending migrations and seed before serving")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, cfg := env.db, env.cfg
+	secretKey := cfg.Auth.JWTSecretKey.Value()
+
+	migrator, err := migrations.NewMigrator(db, logging.Logger("migrations"))
+	if err != nil {
+		return err
+	}
+	if *migrate {
+		// Instances started together wait on each other through the
+		// migration lock
+		if _, err := migrator.Up(context.Background()); err != nil {
+			return err
+		}
+		dbseeds.SeedDefaultRolesAndPermissions(db)
+	}
+
+	app := fiber.New(fiber.Config{
+		BodyLimit:    20 * 1024 * 1024, // 20 MB in bytes
+		ErrorHandler: apperr.Handler(logging.Logger("http")),
+	})
+
+	// Trace, log, count and time every request. Probes and scrapes are only
+	// logged at debug
+	app.Use(tracing.Middleware())
+	app.Use(middleware.RequestLogger("/healthz", "/readyz", "/metrics"))
+	app.Use(metrics.Middleware())
+
+	// Configure CORS
+	app.Use(cors.New(cors.Config{
+		AllowOrigins: "*", // Change this to specific domains in production
+		AllowMethods: "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+	}))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello, World!")
+	})
+
+	authMiddleware := middleware.Authentication(db, secretKey)
+
+	// Serve the counts to Prometheus, which scrapes with an admin's API key
+	app.Get("/metrics", middleware.RequireScope(session.ScopeMetricsRead), authMiddleware, middleware.RequireRole(helper.AdminRoleName), metrics.Handler())
+
+	health.RegisterRoutes(app, health.NewHealthHTTPTransport(
+		health.NewHealthAPI(db, logging.Logger("health"), migrator, cfg.Analysis, cfg.OpenAI.APIKey.Value(), articleFetchJob),
+	), authMiddleware)
+
+	apisRouter := app.Group("/api")
+
+	if cfg.Swagger.Username != "" {
+		apisRouter.Get("/swagger/*", basicauth.New(basicauth.Config{
+			Users: map[string]string{
+				cfg.Swagger.Username: cfg.Swagger.Password.Value(),
+			},
+		}), swagger.HandlerDefault)
+	}
+
+	tokenIssuer := session.NewTokenIssuer(db, secretKey)
+	usageApi := usagesvc.NewUsageAPI(db, logging.Logger("usage"))
+	blobs, err := newBlobStore(cfg, apisRouter)
+	if err != nil {
+		return err
+	}
+	// API Services
+	userAPISvc := usersvc.NewUserHTTPTransport(
+		usersvc.NewUserAPI(db, secretKey, cfg.HTTP.UIAppURL, logging.Logger("users"), tokenIssuer, blobs),
+	)
+	authApiSvc := authsvc.NewAuthHTTPTransport(
+		authsvc.NewAuthApi(db, secretKey, cfg.HTTP.UIAppURL, logging.Logger("auth"), tokenIssuer, newOIDCClients(cfg.OIDC), blobs),
+	)
+	entityApiSvc := entitysvc.NewEntitiesHTTPTransport(
+		entitysvc.NewEntitiesAPI(db, logging.Logger("entities")),
+	)
+	reportApiSvc := reportsvc.NewReportsHTTPTransport(
+		reportsvc.NewReportsAPI(db, cfg.HTTP.UIAppURL, logging.Logger("reports"), entitysvc.NewEntitiesAPI(db, logging.Logger("entities")), server.NewServerAPI(db, logging.Logger("analysis"), usageApi, cfg.Analysis), usageApi, cfg.OpenAI.APIKey.Value()),
+	)
+	orgApiSvc := orgsvc.NewOrgHTTPTransport(
+		orgsvc.NewOrgAPI(db, logging.Logger("orgs"), cfg.HTTP.UIAppURL),
+		logging.Logger("orgs"),
+	)
+	usageApiSvc := usagesvc.NewUsageHTTPTransport(usageApi)
+	apiKeysApiSvc := apikeysvc.NewAPIKeysHTTPTransport(
+		apikeysvc.NewAPIKeysAPI(db, logging.Logger("apikeys")),
+	)
+	serverApiSvc := server.NewServerHTTPTransport(server.NewServerAPI(db, logging.Logger("analysis"), usageApi, cfg.Analysis))
+	privacyApiSvc := privacysvc.NewPrivacyHTTPTransport(
+		privacysvc.NewPrivacyAPI(db, logging.Logger("privacy"), blobs),
+	)
+	auditApiSvc := auditsvc.NewAuditHTTPTransport(
+		auditsvc.NewAuditAPI(db, logging.Logger("audit")),
+	)
+
+	// Register Routes
+	usersvc.RegisterRoutes(apisRouter, userAPISvc, authMiddleware)
+	authsvc.RegisterRoutes(apisRouter, authApiSvc, authMiddleware)
+	reportsvc.RegisterRoutes(apisRouter, reportApiSvc, authMiddleware)
+	entitysvc.RegisterRoutes(apisRouter, entityApiSvc, authMiddleware)
+	orgsvc.RegisterRoutes(apisRouter, orgApiSvc, authMiddleware)
+	usagesvc.RegisterRoutes(apisRouter, usageApiSvc, authMiddleware)
+	apikeysvc.RegisterRoutes(apisRouter, apiKeysApiSvc, authMiddleware)
+	server.RegisterRoutes(apisRouter, serverApiSvc, authMiddleware)
+	auditsvc.RegisterRoutes(apisRouter, auditApiSvc, authMiddleware)
+	privacysvc.RegisterRoutes(apisRouter, privacyApiSvc, authMiddleware)
+
+	// Fetch articles and prune login attempts on one instance at a time
+	jobs := scheduler.NewScheduler(db, logging.Logger("scheduler"), cfg.Scheduler.PollInterval)
+	fetchSchedule, err := scheduler.ParseSchedule(cfg.Scheduler.ArticleFetch)
+	if err != nil {
+		return err
+	}
+	fetchApi := server.NewServerAPI(db, logging.Logger("analysis"), usageApi, cfg.Analysis)
+	err = jobs.Register(scheduler.Job{
+		Name:     articleFetchJob,
+		Schedule: fetchSchedule,
+		// Stops between batches on shutdown, the next run stores the rest
+		Run: fetchApi.FetchAndStoreArticles,
+	})
+	if err != nil {
+		return err
+	}
+	cleanupSchedule, err := scheduler.ParseSchedule(cfg.Scheduler.LoginAttemptCleanup)
+	if err != nil {
+		return err
+	}
+	err = jobs.Register(scheduler.Job{
+		Name:     "login-attempt-cleanup",
+		Schedule: cleanupSchedule,
+		Run: func(ctx context.Context) error {
+			return session.PruneLoginAttempts(ctx, db, time.Now().Add(-loginAttemptRetention))
+		},
+	})
+	if err != nil {
+		return err
+	}
+	schedulerApiSvc := scheduler.NewSchedulerHTTPTransport(
+		scheduler.NewSchedulerAPI(db, logging.Logger("scheduler"), jobs),
+	)
+	scheduler.RegisterRoutes(apisRouter, schedulerApiSvc, authMiddleware)
+
+	manager := lifecycle.NewManager(logging.Logger("app"), cfg.HTTP.ShutdownTimeout)
+
+	// Deliver queued emails in the background
+	mailLogger := logging.Logger("mailer")
+	manager.Go("mailer", mailer.NewSender(db, newMailTransport(cfg.Mail, mailLogger), mailLogger).Run)
+
+	if cfg.Scheduler.Enabled {
+		manager.Go("scheduler", jobs.Run)
+	}
+
+	return manager.Run(func() error {
+		return app.Listen(fmt.Sprintf(`:%d`, *port))
+	}, app.ShutdownWithContext)
+}
+
+func newOIDCClients(providers []config.OIDCProvider) []oidc.Client {
+	var clients []oidc.Client
+	for _, p := range providers {
+		clients = append(clients, oidc.NewClient(oidc.Provider{
+			Name:         p.Name,
+			IssuerURL:    p.IssuerURL,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret.Value(),
+			RedirectURL:  p.RedirectURL,
+		}, nil))
+	}
+	return clients
+}
+
+// newMailTransport picks the email transport: "smtp" through the configured
+// server, "file" writing .eml files to the file dir, or "log".
+func newMailTransport(cfg config.Mail, logger log.AllLogger) mailer.Transport {
+	switch cfg.Transport {
+	case "file":
+		return mailer.NewFileTransport(cfg.FileDir, cfg.From)
+	case "log":
+		return mailer.NewLogTransport(logger)
+	default:
+		return mailer.NewSMTPTransport(newDialer(cfg), cfg.From)
+	}
+}
+
+// newBlobStore picks the object storage: "local" keeping files on disk,
+// served through signed URLs on the router, or "s3" for an S3 compatible
+// bucket.
+func newBlobStore(cfg *config.Config, router fiber.Router) (storage.BlobStore, error) {
+	if cfg.Blob.Store == "s3" {
+		return storage.NewS3Store(storage.S3Config{
+			Endpoint:        cfg.Blob.S3.Endpoint,
+			Region:          cfg.Blob.S3.Region,
+			Bucket:          cfg.Blob.S3.Bucket,
+			AccessKeyID:     cfg.Blob.S3.AccessKeyID,
+			SecretAccessKey: cfg.Blob.S3.SecretAccessKey.Value(),
+			PathStyle:       cfg.Blob.S3.PathStyle,
+		}, nil)
+	}
+
+	store := storage.NewLocalStore(cfg.Blob.LocalDir, cfg.HTTP.APIURL+"/api/files", cfg.Auth.JWTSecretKey.Value())
+	storage.RegisterRoutes(router, store)
+	return store, nil
+}